@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller"
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+	"kubevirt.io/hostpath-provisioner/controller/summary"
+	"kubevirt.io/hostpath-provisioner/controller/webhook"
+)
+
+const (
+	defaultProvisionerName = "kubevirt.io/hostpath-provisioner"
+	component              = "hostpath-provisioner-manager"
+	defaultConversionPort  = "8443"
+	defaultCertFile        = "/etc/manager/certs/tls.crt"
+	defaultKeyFile         = "/etc/manager/certs/tls.key"
+)
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func main() {
+	glog.InitFlags(nil)
+	flag.Parse()
+	flag.Set("logtostderr", "true")
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		glog.Fatalf("Failed to create config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create client: %v", err)
+	}
+	diskMonitorClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create DiskMonitor client: %v", err)
+	}
+
+	// DiskMonitors live in the namespace of the provisioner daemonset
+	namespace := os.Getenv("NAMESPACE")
+	provisionerName := getEnv("PROVISIONER_NAME", defaultProvisionerName)
+	strategy, err := placement.ParseStrategy(os.Getenv("PLACEMENT_STRATEGY"))
+	if err != nil {
+		glog.Fatalf("Invalid placement strategy: %v", err)
+	}
+	policy, err := capacity.ParsePolicy(os.Getenv("CAPACITY_MODE"), os.Getenv("CAPACITY_MIN_FREE"), os.Getenv("CAPACITY_OVERCOMMIT_RATIO"))
+	if err != nil {
+		glog.Fatalf("Invalid capacity policy: %v", err)
+	}
+	// MAINTENANCE_TAINTS and MAINTENANCE_LABEL_SELECTOR must match the provisioner
+	// daemonset, so that claims are not placed on nodes that would refuse them
+	maintenanceConfig, err := maintenance.ParseConfig(os.Getenv("MAINTENANCE_TAINTS"), os.Getenv("MAINTENANCE_LABEL_SELECTOR"))
+	if err != nil {
+		glog.Fatalf("Invalid maintenance configuration: %v", err)
+	}
+	// DISKMONITOR_STALE_AFTER is how old the heartbeat of a DiskMonitor may get
+	// before its node agent is considered gone and the DiskMonitor not ready
+	staleAfter := monitor_disk.DefaultStaleAfter
+	if value := os.Getenv("DISKMONITOR_STALE_AFTER"); value != "" {
+		if staleAfter, err = time.ParseDuration(value); err != nil || staleAfter <= 0 {
+			glog.Fatalf("Invalid DISKMONITOR_STALE_AFTER %q", value)
+		}
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(glog.Infof)
+	broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: clientset.CoreV1().Events(v1.NamespaceAll)})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		glog.Fatalf("Error getting hostname: %v", err)
+	}
+	// add a uniquifier so that two processes on the same host don't accidentally both become active
+	id := hostname + "_" + string(uuid.NewUUID())
+	lock, err := resourcelock.New("endpoints", namespace, component, clientset.CoreV1(), nil,
+		resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: recorder,
+		})
+	if err != nil {
+		glog.Fatalf("Error creating lock: %v", err)
+	}
+
+	run := func(ctx context.Context) {
+		informerFactory := informers.NewSharedInformerFactory(clientset, controller.DefaultResyncPeriod)
+		claimInformer := informerFactory.Core().V1().PersistentVolumeClaims()
+		classInformer := informerFactory.Storage().V1().StorageClasses()
+		nodeInformer := informerFactory.Core().V1().Nodes()
+		volumes, err := nodevolumes.New(informerFactory.Core().V1().PersistentVolumes().Informer())
+		if err != nil {
+			glog.Fatalf("Failed to index persistent volumes: %v", err)
+		}
+		groups, err := claimgroups.New(claimInformer.Informer(), volumes.Informer())
+		if err != nil {
+			glog.Fatalf("Failed to index claim groups: %v", err)
+		}
+		diskMonitorInformerFactory := diskmonitorinformers.NewSharedInformerFactoryWithOptions(diskMonitorClient, controller.DefaultResyncPeriod,
+			diskmonitorinformers.WithNamespace(namespace))
+		diskMonitorInformer := diskMonitorInformerFactory.DiskMonitor().V2().DiskMonitors()
+
+		capacities := monitor_disk.NodeCapacity(diskMonitorInformer.Lister(), namespace, policy)
+		admit := monitor_disk.NodeAdmission(diskMonitorInformer.Lister(), namespace, maintenanceConfig)
+		placer := placement.New(clientset, provisionerName, strategy, claimInformer, groups, classInformer.Lister(), nodeInformer.Lister(),
+			volumes, capacities, admit, recorder)
+		staleChecker := monitor_disk.NewStaleChecker(diskMonitorClient, namespace, diskMonitorInformer.Lister(), staleAfter)
+		aggregator := summary.NewAggregator(diskMonitorClient, namespace, provisionerName, diskMonitorInformer.Lister(), claimInformer.Lister(),
+			classInformer.Lister(), nodeInformer.Lister(), volumes, groups, capacities, admit)
+		// refresh the HostPathClusterSummary as soon as anything it is computed from changes
+		for _, informer := range []cache.SharedIndexInformer{diskMonitorInformer.Informer(), claimInformer.Informer(), classInformer.Informer(), volumes.Informer()} {
+			informer.AddEventHandler(aggregator.EventHandler())
+		}
+
+		informerFactory.Start(ctx.Done())
+		diskMonitorInformerFactory.Start(ctx.Done())
+		if !cache.WaitForCacheSync(ctx.Done(), claimInformer.Informer().HasSynced, classInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced, volumes.HasSynced, diskMonitorInformer.Informer().HasSynced) {
+			glog.Fatalf("Failed to sync informers")
+		}
+		go staleChecker.Run(monitor_disk.DefaultHeartbeatPeriod, ctx.Done())
+		go aggregator.Run(ctx.Done())
+		placer.Run(controller.DefaultThreadiness, ctx.Done())
+	}
+
+	// Every replica converts DiskMonitors between their API versions, not only
+	// the leader: the node agents can not read or write them without it
+	mux := http.NewServeMux()
+	mux.Handle("/convert", webhook.ServeConversion())
+	port := getEnv("CONVERSION_PORT", defaultConversionPort)
+	go func() {
+		glog.Infof("conversion webhook listening on port %s", port)
+		glog.Fatal(http.ListenAndServeTLS(":"+port, getEnv("TLS_CERT_FILE", defaultCertFile), getEnv("TLS_KEY_FILE", defaultKeyFile), mux))
+	}()
+
+	leaderelection.RunOrDie(context.TODO(), leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: controller.DefaultLeaseDuration,
+		RenewDeadline: controller.DefaultRenewDeadline,
+		RetryPeriod:   controller.DefaultRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: run,
+			OnStoppedLeading: func() {
+				glog.Fatalf("leaderelection lost")
+			},
+		},
+	})
+}
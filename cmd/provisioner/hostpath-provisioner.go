@@ -21,47 +21,117 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sys/unix"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/golang/glog"
 	"kubevirt.io/hostpath-provisioner/controller"
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/diskusage"
+	"kubevirt.io/hostpath-provisioner/controller/history"
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	"kubevirt.io/hostpath-provisioner/controller/metrics"
 	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/poolwatch"
+	"kubevirt.io/hostpath-provisioner/controller/preemption"
+	"kubevirt.io/hostpath-provisioner/controller/pressure"
+	"kubevirt.io/hostpath-provisioner/controller/storagecapacity"
+	"kubevirt.io/hostpath-provisioner/controller/trash"
+	"kubevirt.io/hostpath-provisioner/controller/warmpool"
 	"kubevirt.io/hostpath-provisioner/rpcNodeInfo"
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
-	diskv1 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	diskv2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/util"
 )
 
 const (
-	defaultProvisionerName  = "kubevirt.io/hostpath-provisioner"
-	annStorageProvisioner   = "volume.beta.kubernetes.io/storage-provisioner"
-	StorageClassName        = "kubevirt-hostpath-provisioner"
-	RESOURCE_ALREADY_EXISTS = "already exists"
+	defaultProvisionerName = "kubevirt.io/hostpath-provisioner"
+	annStorageProvisioner  = "volume.beta.kubernetes.io/storage-provisioner"
+	annProvisionedBy       = "pv.kubernetes.io/provisioned-by"
+	annIdentity            = "hostPathProvisionerIdentity"
+	StorageClassName       = "kubevirt-hostpath-provisioner"
 )
 
 var provisionerName string
 
 type hostPathProvisioner struct {
+	client          kubernetes.Interface
+	diskMonitors    versioned.Interface
+	volumes         *nodevolumes.Index
+	groups          *claimgroups.Groups
 	pvDir           string
 	identity        string
 	nodeName        string
 	namespace       string
 	ownerReferences string
 	useNamingPrefix bool
+	capacityPolicy  capacity.Policy
+	// nodes holds the Node of this provisioner, maintenance says when it takes no new volumes
+	nodes       corelisters.NodeLister
+	maintenance maintenance.Config
+	recorder    record.EventRecorder
+	// pressure, when set, reports a pool running low on the Node
+	pressure *pressure.Reporter
+	// preemptor, when set, deletes evictable claims of lower priority for claims that do not fit
+	preemptor *preemption.Preemptor
+	// pool names the pool of this node, it is the pool of volumes whose claim names none
+	pool string
+	// advertiser, when set, advertises the pool as a Node extended resource
+	advertiser *noderesources.Advertiser
+	// ledger holds the requests placed on the pool, including in flight claims
+	ledger *capacity.Ledger
+	// reconciler, when set, refreshes the DiskMonitor the scheduler extender and the manager read the capacity from
+	reconciler *monitor_disk.Reconciler
+	// publisher, when set, publishes the capacity as CSIStorageCapacity objects
+	publisher *storagecapacity.Publisher
+	// warmPool, when set, serves claims from pre-created directories and volumes
+	warmPool *warmpool.Pool
+	// trash, when set, keeps the directories of deleted volumes while the spec sets a trash retention
+	trash *trash.Trash
+	// history, when set, keeps the usage of the pool over time to forecast when it fills up
+	history *history.History
+	// usage, when set, holds the space the files of the volumes take on disk
+	usage *diskusage.Cache
+
+	// specMutex guards spec, the desired state of the pool read from the DiskMonitor of this node
+	specMutex sync.Mutex
+	spec      *diskv2.DiskMonitorSpec
 }
 
 // Common allocation units
@@ -75,7 +145,7 @@ const (
 var provisionerID string
 
 // NewHostPathProvisioner creates a new hostpath provisioner
-func NewHostPathProvisioner() controller.Provisioner {
+func NewHostPathProvisioner(client kubernetes.Interface, diskMonitors versioned.Interface, volumes *nodevolumes.Index, groups *claimgroups.Groups, nodes corelisters.NodeLister, recorder record.EventRecorder) *hostPathProvisioner {
 	useNamingPrefix := false
 	nodeName := os.Getenv("NODE_NAME")
 	if nodeName == "" {
@@ -93,25 +163,80 @@ func NewHostPathProvisioner() controller.Provisioner {
 	if strings.ToLower(os.Getenv("USE_NAMING_PREFIX")) == "true" {
 		useNamingPrefix = true
 	}
+	// CAPACITY_MODE, CAPACITY_MIN_FREE and CAPACITY_OVERCOMMIT_RATIO tune how much of
+	// the pool may be handed out, see the capacity package for the accepted values
+	capacityPolicy, err := capacity.ParsePolicy(os.Getenv("CAPACITY_MODE"), os.Getenv("CAPACITY_MIN_FREE"), os.Getenv("CAPACITY_OVERCOMMIT_RATIO"))
+	if err != nil {
+		glog.Fatalf("invalid capacity policy: %v", err)
+	}
+	// MAINTENANCE_TAINTS and MAINTENANCE_LABEL_SELECTOR put the node in maintenance
+	// besides cordoning it or annotating it with hostpath.kubevirt.io/maintenance
+	maintenanceConfig, err := maintenance.ParseConfig(os.Getenv("MAINTENANCE_TAINTS"), os.Getenv("MAINTENANCE_LABEL_SELECTOR"))
+	if err != nil {
+		glog.Fatalf("invalid maintenance configuration: %v", err)
+	}
+	// STORAGE_PRESSURE_FREE_THRESHOLD and STORAGE_PRESSURE_ALLOCATABLE_THRESHOLD enable the
+	// HostPathStoragePressure node condition, STORAGE_PRESSURE_TAINT adds a NoSchedule taint as well
+	pressureConfig, err := pressure.ParseConfig(os.Getenv("STORAGE_PRESSURE_FREE_THRESHOLD"), os.Getenv("STORAGE_PRESSURE_ALLOCATABLE_THRESHOLD"),
+		os.Getenv("STORAGE_PRESSURE_RECOVERY_MARGIN"), os.Getenv("STORAGE_PRESSURE_TAINT"))
+	if err != nil {
+		glog.Fatalf("invalid storage pressure configuration: %v", err)
+	}
+	var pressureReporter *pressure.Reporter
+	if pressureConfig.Enabled() {
+		pressureReporter = pressure.NewReporter(client, nodeName, pressureConfig)
+	}
+	// POOL_NAME names the pool in the extended resource and label advertised with
+	// ADVERTISE_NODE_RESOURCES=true
+	pool := os.Getenv("POOL_NAME")
+	if pool == "" {
+		pool = noderesources.DefaultPool
+	}
+	if err := noderesources.ValidatePool(pool); err != nil {
+		glog.Fatalf("invalid POOL_NAME: %v", err)
+	}
+	var advertiser *noderesources.Advertiser
+	if strings.ToLower(os.Getenv("ADVERTISE_NODE_RESOURCES")) == "true" {
+		advertiser = noderesources.NewAdvertiser(client, nodeName, pool)
+	}
 	glog.Infof("initiating kubevirt/hostpath-provisioner on node: %s\n", nodeName)
-	provisionerName = "kubevirt.io/hostpath-provisioner"
+	// PROVISIONER_NAME has to be a valid CSIDriver name with PUBLISH_STORAGE_CAPACITY=true
+	provisionerName = os.Getenv("PROVISIONER_NAME")
+	if provisionerName == "" {
+		provisionerName = defaultProvisionerName
+	}
 	return &hostPathProvisioner{
+		client:          client,
+		diskMonitors:    diskMonitors,
+		volumes:         volumes,
+		groups:          groups,
 		pvDir:           pvDir,
 		identity:        provisionerName,
 		nodeName:        nodeName,
 		useNamingPrefix: useNamingPrefix,
 		namespace:       nameSpace,
 		ownerReferences: ownerReferences,
+		capacityPolicy:  capacityPolicy,
+		nodes:           nodes,
+		maintenance:     maintenanceConfig,
+		recorder:        recorder,
+		pressure:        pressureReporter,
+		pool:            pool,
+		advertiser:      advertiser,
+		ledger:          capacity.NewLedger(),
+		trash:           trash.New(pvDir),
+		history:         history.New(pvDir),
 	}
 }
 
 var _ controller.Provisioner = &hostPathProvisioner{}
+var _ controller.CapacityReserver = &hostPathProvisioner{}
 
 func isCorrectNodeByBindingMode(annotations map[string]string, nodeName string, bindingMode storage.VolumeBindingMode) bool {
 	glog.Infof("isCorrectNodeByBindingMode mode: %s", string(bindingMode))
 	if _, ok := annotations["kubevirt.io/provisionOnNode"]; ok {
 		if isCorrectNode(annotations, nodeName, "kubevirt.io/provisionOnNode") {
-			annotations[annStorageProvisioner] = defaultProvisionerName
+			annotations[annStorageProvisioner] = provisionerName
 			return true
 		}
 		return false
@@ -138,102 +263,515 @@ func isCorrectNode(annotations map[string]string, nodeName string, annotationNam
 func (p *hostPathProvisioner) ShouldProvision(pvc *v1.PersistentVolumeClaim, bindingMode *storage.VolumeBindingMode) bool {
 	shouldProvision := isCorrectNodeByBindingMode(pvc.GetAnnotations(), p.nodeName, *bindingMode)
 
-	if shouldProvision {
-		pvCapacity, err := calculatePvCapacity(p.pvDir)
-		totalFree, _ := getFreeSpace(p.nodeName, pvCapacity)
-
-		if pvCapacity != nil && totalFree.Cmp(pvc.Spec.Resources.Requests[(v1.ResourceStorage)]) < 0 {
-			glog.Error("PVC request size larger than total possible PV size,totalFree = ", totalFree.String())
-			shouldProvision = false
-		} else if err != nil {
+	// a claim holding a reservation was admitted already and is being provisioned
+	if shouldProvision && !p.ledger.IsReserved(string(pvc.UID)) {
+		err := p.checkPool(pvc)
+		if err == nil {
+			err = p.checkAdmission(util.GetPersistentVolumeClaimClass(pvc))
+		}
+		if err != nil {
+			glog.Errorf("Refusing claim %s/%s: %v", pvc.Namespace, pvc.Name, err)
+			if isReschedulable(pvc.GetAnnotations()) {
+				// ProvisionExt hands the claim back to the scheduler
+				return true
+			}
+			if p.recorder != nil {
+				p.recorder.Event(pvc, v1.EventTypeWarning, admissionReason(err), err.Error())
+			}
+			return false
+		}
+		if p.ledger.Full() {
+			glog.Errorf("Unable to provision claim %s/%s: node %s holds as many volumes as its DiskMonitor allows", pvc.Namespace, pvc.Name, p.nodeName)
+			return isReschedulable(pvc.GetAnnotations())
+		}
+		requested, err := p.groupRequested(pvc)
+		if err != nil {
+			glog.Errorf("Unable to provision claim %s/%s on this node: %v", pvc.Namespace, pvc.Name, err)
+			return isReschedulable(pvc.GetAnnotations())
+		}
+		poolCapacity, err := p.currentCapacity()
+		if err != nil {
 			glog.Errorf("Unable to determine pvCapacity %v", err)
 			shouldProvision = false
+		} else if !poolCapacity.Fits(requested) {
+			glog.Errorf("PVC request size larger than total possible PV size, allocatable = %s", resource.NewQuantity(poolCapacity.Allocatable, resource.BinarySI).String())
+			// A claim the scheduler placed here is still provisioned, so the
+			// failure hands it back to the scheduler instead of leaving it stuck.
+			// A claim that can preempt evictable claims is provisioned to make room.
+			shouldProvision = isReschedulable(pvc.GetAnnotations()) || p.canPreempt(pvc, requested-poolCapacity.Allocatable)
 		}
 	}
 	return shouldProvision
 }
 
-func getExistPV() (*v1.PersistentVolumeList, error) {
-	pvs, err := getClientSet().CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{})
+// canPreempt returns whether evictable claims of lower priority than the claim free needed bytes on the pool.
+func (p *hostPathProvisioner) canPreempt(pvc *v1.PersistentVolumeClaim, needed int64) bool {
+	return p.preemptor != nil && p.preemptor.CanPreempt(pvc, needed)
+}
+
+// maintenanceReason returns why the node takes no new volumes, or "". The
+// manager skips the node for the same reasons when it places claims.
+func (p *hostPathProvisioner) maintenanceReason() string {
+	var node *v1.Node
+	if p.nodes != nil {
+		var err error
+		if node, err = p.nodes.Get(p.nodeName); err != nil {
+			glog.Errorf("Unable to get node %s: %v", p.nodeName, err)
+			node = nil
+		}
+	}
+	return monitor_disk.MaintenanceReason(p.maintenance, node, p.currentSpec())
+}
+
+// checkMaintenance returns a maintenance.Error while the node takes no new volumes.
+func (p *hostPathProvisioner) checkMaintenance() error {
+	if reason := p.maintenanceReason(); reason != "" {
+		return &maintenance.Error{Node: p.nodeName, Reason: reason}
+	}
+	return nil
+}
+
+// storageClassError is returned for claims of a storage class the DiskMonitor
+// of the node does not allow.
+type storageClassError struct {
+	Node  string
+	Class string
+}
+
+func (e *storageClassError) Error() string {
+	return fmt.Sprintf("node %s does not take claims of storage class %q", e.Node, e.Class)
+}
+
+// poolError is returned for claims naming a pool other than the pool of the node.
+type poolError struct {
+	Node     string
+	Pool     string
+	NodePool string
+}
+
+func (e *poolError) Error() string {
+	return fmt.Sprintf("claim of pool %q can not be placed on node %s of pool %q", e.Pool, e.Node, e.NodePool)
+}
+
+// checkPool returns a poolError when the claim names a pool the node does not
+// have. The default pool is on every node, whatever the pool of the node.
+func (p *hostPathProvisioner) checkPool(pvc *v1.PersistentVolumeClaim) error {
+	if pool, ok := pvc.Annotations[nodevolumes.AnnPool]; ok && pool != p.pool && pool != noderesources.DefaultPool {
+		return &poolError{Node: p.nodeName, Pool: pool, NodePool: p.pool}
+	}
+	return nil
+}
+
+// checkAdmission returns why the node takes no new volumes of the storage
+// class: a maintenance.Error or a storageClassError.
+func (p *hostPathProvisioner) checkAdmission(class string) error {
+	if err := p.checkMaintenance(); err != nil {
+		return err
+	}
+	if !monitor_disk.StorageClassAllowed(p.currentSpec(), class) {
+		return &storageClassError{Node: p.nodeName, Class: class}
+	}
+	return nil
+}
+
+// admissionReason returns the event reason of an error of checkAdmission.
+func admissionReason(err error) string {
+	if _, ok := err.(*storageClassError); ok {
+		return "StorageClassNotAllowed"
+	}
+	if _, ok := err.(*poolError); ok {
+		return "PoolMismatch"
+	}
+	return "NodeInMaintenance"
+}
+
+// currentSpec returns the spec last applied, it must not be modified.
+func (p *hostPathProvisioner) currentSpec() *diskv2.DiskMonitorSpec {
+	p.specMutex.Lock()
+	defer p.specMutex.Unlock()
+	if p.spec == nil {
+		return &diskv2.DiskMonitorSpec{}
+	}
+	return p.spec
+}
+
+// applySpec validates the spec of the DiskMonitor of this node and applies it
+// when it changed. An invalid spec is not applied, the previous one stays.
+func (p *hostPathProvisioner) applySpec(spec *diskv2.DiskMonitorSpec) error {
+	if err := monitor_disk.ValidateSpec(spec); err != nil {
+		return fmt.Errorf("invalid spec of DiskMonitor %s: %v", p.nodeName, err)
+	}
+	p.specMutex.Lock()
+	changed := p.spec == nil || !equality.Semantic.DeepEqual(*p.spec, *spec)
+	p.spec = spec.DeepCopy()
+	p.specMutex.Unlock()
+	if !changed {
+		return nil
+	}
+	glog.Infof("applying spec of DiskMonitor %s", p.nodeName)
+	maxVolumes := -1
+	if spec.MaxVolumes != nil {
+		maxVolumes = int(*spec.MaxVolumes)
+	}
+	p.ledger.SetMaxVolumes(maxVolumes)
+	p.capacityChanged()
+	return nil
+}
+
+// maintenanceCondition returns the DiskMonitor condition reflecting maintenance.
+func (p *hostPathProvisioner) maintenanceCondition() diskv2.DiskMonitorCondition {
+	if reason := p.maintenanceReason(); reason != "" {
+		return diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorMaintenance,
+			Status:  v1.ConditionTrue,
+			Reason:  "NodeInMaintenance",
+			Message: reason + ", new volumes are refused",
+		}
+	}
+	return diskv2.DiskMonitorCondition{
+		Type:    diskv2.DiskMonitorMaintenance,
+		Status:  v1.ConditionFalse,
+		Reason:  "NodeAvailable",
+		Message: "node takes new volumes",
+	}
+}
+
+// reportPressure updates the storage pressure condition and taint of the node
+// and returns whether the pool is under pressure.
+func (p *hostPathProvisioner) reportPressure(poolCapacity capacity.Capacity) bool {
+	if p.pressure == nil {
+		return false
+	}
+	var node *v1.Node
+	if p.nodes != nil {
+		node, _ = p.nodes.Get(p.nodeName)
+	}
+	underPressure, err := p.pressure.Report(node, poolCapacity)
 	if err != nil {
-		glog.Error("get exist pv err: ", err)
-		return &v1.PersistentVolumeList{}, err
+		glog.Errorf("Unable to report storage pressure of node %s: %v", p.nodeName, err)
+		return false
+	}
+	if underPressure {
+		glog.V(2).Infof("hostpath pool of node %s is under storage pressure", p.nodeName)
+	}
+	return underPressure
+}
+
+// capacityLowCondition returns the CapacityLow condition of the pool. With
+// storage pressure thresholds configured it follows the pressure of the pool,
+// otherwise it is true once nothing is allocatable anymore.
+func (p *hostPathProvisioner) capacityLowCondition(poolCapacity capacity.Capacity, underPressure bool) diskv2.DiskMonitorCondition {
+	free := resource.NewQuantity(poolCapacity.Available, resource.BinarySI).String()
+	allocatable := resource.NewQuantity(poolCapacity.Allocatable, resource.BinarySI).String()
+	message := fmt.Sprintf("pool has %s free and %s allocatable", free, allocatable)
+	if underPressure || (p.pressure == nil && poolCapacity.Allocatable <= 0) {
+		return diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorCapacityLow,
+			Status:  v1.ConditionTrue,
+			Reason:  "CapacityLow",
+			Message: message,
+		}
+	}
+	return diskv2.DiskMonitorCondition{
+		Type:    diskv2.DiskMonitorCapacityLow,
+		Status:  v1.ConditionFalse,
+		Reason:  "CapacityAvailable",
+		Message: message,
+	}
+}
+
+// advertiseResources updates the extended resource and the label of the pool on the node.
+func (p *hostPathProvisioner) advertiseResources(poolCapacity capacity.Capacity) {
+	if p.advertiser == nil {
+		return
+	}
+	var node *v1.Node
+	if p.nodes != nil {
+		node, _ = p.nodes.Get(p.nodeName)
+	}
+	if err := p.advertiser.Advertise(node, poolCapacity); err != nil {
+		glog.Errorf("Unable to advertise the resources of node %s: %v", p.nodeName, err)
 	}
-	return pvs, nil
 }
 
-func getFreeSpace(nodeName string, total *resource.Quantity) (*resource.Quantity, error) {
-	pvs, err := getExistPV()
+// groupRequested returns the capacity the claim needs on this node: its own
+// request plus those of the members of its co-location group that are not
+// provisioned or reserved yet. It fails when the group or anti-affinity
+// annotations of the claim do not allow this node.
+func (p *hostPathProvisioner) groupRequested(pvc *v1.PersistentVolumeClaim) (int64, error) {
+	members, err := p.groupMembers(pvc)
 	if err != nil {
+		return 0, err
+	}
+	requested := pvc.Spec.Resources.Requests.Storage().Value()
+	for _, member := range members {
+		requested += member.Size
+	}
+	return requested, nil
+}
+
+// groupMembers returns the requests of the members of the co-location group
+// of the claim, other than the claim, that are not provisioned or reserved yet.
+// It fails when the group or anti-affinity annotations of the claim do not
+// allow this node.
+func (p *hostPathProvisioner) groupMembers(pvc *v1.PersistentVolumeClaim) ([]capacity.Request, error) {
+	if p.groups == nil {
+		return nil, nil
+	}
+	constraint := p.groups.Constraint(pvc)
+	if err := constraint.Check(p.nodeName); err != nil {
 		return nil, err
 	}
-	if pvs != nil {
-		for _, pv := range pvs.Items {
-			if !isPVOnCurrentNode(nodeName, pv.Annotations["kubevirt.io/provisionOnNode"]) {
-				continue
-			}
-			if pv.Spec.StorageClassName == StorageClassName {
-				total.Sub(*pv.Spec.Capacity.Storage())
-			}
+	var members []capacity.Request
+	for _, member := range constraint.Pending {
+		if member.UID == pvc.UID || p.ledger.IsReserved(string(member.UID)) {
+			continue
 		}
+		members = append(members, capacity.Request{
+			ClaimUID: string(member.UID),
+			// the name Provision gives the PV of the member
+			PVName: member.Namespace + ".pvc-" + string(member.UID),
+			Size:   member.Spec.Resources.Requests.Storage().Value(),
+		})
 	}
-	return total, err
+	return members, nil
 }
 
-func isPVOnCurrentNode(nodeName, volumeNode string) bool {
-	if strings.Compare(nodeName, volumeNode) == 0 {
-		return true
+// isReschedulable returns whether the node of the claim was picked by the
+// scheduler and may be changed, as opposed to a kubevirt.io/provisionOnNode claim.
+func isReschedulable(annotations map[string]string) bool {
+	if _, ok := annotations["kubevirt.io/provisionOnNode"]; ok {
+		return false
 	}
-	return false
+	_, ok := annotations["volume.kubernetes.io/selected-node"]
+	return ok
 }
 
-func updateDiskRecords(args *monitor_disk.ModifyDiskArgs) error {
-	monitor, err := monitor_disk.Get(args.Namespace, args.CRName)
-	if err != nil && strings.Contains(fmt.Sprintf("%s", err), "not found") {
-		if err = createDiskMonitorCR(args.Namespace, args.OwnerReferences, args.CRName); err != nil {
-			return err
+// computeCapacity applies the capacity policy to the pool of this node given
+// the requests placed on it and those in flight, and keeps the headroom
+// reserved by the spec.
+func (p *hostPathProvisioner) computeCapacity(requested, inFlight int64) (capacity.Capacity, error) {
+	stats, err := capacity.Statfs(p.pvDir)
+	if err != nil {
+		return capacity.Capacity{}, err
+	}
+	poolCapacity := p.capacityPolicy.Compute(stats, requested, inFlight)
+	if headroom := p.currentSpec().ReservedHeadroom; headroom != nil {
+		poolCapacity.Allocatable -= headroom.Value()
+		if poolCapacity.Allocatable < 0 {
+			poolCapacity.Allocatable = 0
 		}
-	} else if err != nil {
-		glog.Error("get monitor disk err %v", err)
-		return err
 	}
+	recordCapacityMetrics(p.nodeName, poolCapacity)
+	return poolCapacity, nil
+}
+
+// currentCapacity returns the capacity of the pool given everything in the ledger.
+func (p *hostPathProvisioner) currentCapacity() (capacity.Capacity, error) {
+	return p.computeCapacity(p.ledger.Requested(), p.ledger.InFlight())
+}
+
+// capacityChanged refreshes the DiskMonitor after the ledger changed, so
+// the capacity it reports counts the reservations right away.
+func (p *hostPathProvisioner) capacityChanged() {
+	if p.reconciler != nil {
+		p.reconciler.Enqueue()
+	}
+	if p.publisher != nil {
+		p.publisher.Trigger()
+	}
+}
+
+// poolChanged refreshes the DiskMonitor and the warm pool after the
+// filesystem of the pool was resized or replaced. The advertised resources and
+// the metrics are refreshed along with the DiskMonitor.
+func (p *hostPathProvisioner) poolChanged() {
+	p.capacityChanged()
+	if p.warmPool != nil {
+		p.warmPool.Trigger()
+	}
+}
+
+// ConfirmReservation marks the capacity reserved for the claim as used by the stored PV.
+func (p *hostPathProvisioner) ConfirmReservation(claim *v1.PersistentVolumeClaim, volume *v1.PersistentVolume) {
+	glog.Infof("confirming reservation of claim %s/%s for pv %s", claim.Namespace, claim.Name, volume.Name)
+	p.ledger.Confirm(string(claim.UID))
+	p.capacityChanged()
+}
+
+// ReleaseReservation gives back the capacity reserved for the claim.
+func (p *hostPathProvisioner) ReleaseReservation(claimUID string) {
+	glog.Infof("releasing reservation of claim %s", claimUID)
+	p.ledger.Release(claimUID)
+	p.capacityChanged()
+}
+
+// rebuildLedger loads the PVs placed on this node into the ledger.
+func (p *hostPathProvisioner) rebuildLedger() {
+	p.ledger.Rebuild(nodeVolumes(p.nodePVs()))
+}
+
+func recordCapacityMetrics(nodeName string, c capacity.Capacity) {
+	metrics.CapacityTotalBytes.WithLabelValues(nodeName).Set(float64(c.Total))
+	metrics.CapacityAvailableBytes.WithLabelValues(nodeName).Set(float64(c.Available))
+	metrics.CapacityRequestedBytes.WithLabelValues(nodeName).Set(float64(c.Requested))
+	metrics.CapacityAllocatableBytes.WithLabelValues(nodeName).Set(float64(c.Allocatable))
+}
+
+// forecast records the usage of the pool in its history and returns the
+// trend fitted to it, nil until the history holds enough samples.
+func (p *hostPathProvisioner) forecast(poolCapacity capacity.Capacity) *diskv2.PoolForecast {
+	if p.history == nil {
+		return nil
+	}
+	sample := history.Sample{Time: time.Now(), Used: poolCapacity.Total - poolCapacity.Available, Requested: poolCapacity.Requested}
+	if _, err := p.history.Record(p.pool, sample); err != nil {
+		glog.Errorf("Failed to record the usage of pool %s: %v", p.pool, err)
+	}
+	forecast, ok := p.history.Forecast(p.pool, poolCapacity.Total)
+	recordForecastMetrics(p.nodeName, p.pool, forecast, ok)
+	if !ok {
+		return nil
+	}
+	const day = 24 * 60 * 60
+	poolForecast := &diskv2.PoolForecast{
+		Samples:               int32(forecast.Samples),
+		Since:                 metav1.NewTime(forecast.Since),
+		UsedGrowthPerDay:      *resource.NewQuantity(int64(forecast.UsedPerSecond*day), resource.BinarySI),
+		RequestedGrowthPerDay: *resource.NewQuantity(int64(forecast.RequestedPerSecond*day), resource.BinarySI),
+	}
+	if forecast.FullAt != nil {
+		fullAt := metav1.NewTime(*forecast.FullAt)
+		poolForecast.FullAt = &fullAt
+	}
+	if forecast.RequestsFullAt != nil {
+		requestsFullAt := metav1.NewTime(*forecast.RequestsFullAt)
+		poolForecast.RequestsFullAt = &requestsFullAt
+	}
+	return poolForecast
+}
+
+// recordForecastMetrics exports the forecast of the pool, the metrics of a
+// trend or date that is unknown are removed.
+func recordForecastMetrics(nodeName, pool string, forecast history.Forecast, ok bool) {
+	const day = 24 * 60 * 60
+	growth := map[*prometheus.GaugeVec]float64{
+		metrics.CapacityUsedGrowthBytesPerDay:      forecast.UsedPerSecond * day,
+		metrics.CapacityRequestedGrowthBytesPerDay: forecast.RequestedPerSecond * day,
+	}
+	for gauge, value := range growth {
+		if ok {
+			gauge.WithLabelValues(nodeName, pool).Set(value)
+		} else {
+			gauge.DeleteLabelValues(nodeName, pool)
+		}
+	}
+	dates := map[*prometheus.GaugeVec]*time.Time{
+		metrics.CapacityFullTimestampSeconds:         forecast.FullAt,
+		metrics.CapacityRequestsFullTimestampSeconds: forecast.RequestsFullAt,
+	}
+	for gauge, date := range dates {
+		if date != nil {
+			gauge.WithLabelValues(nodeName, pool).Set(float64(date.Unix()))
+		} else {
+			gauge.DeleteLabelValues(nodeName, pool)
+		}
+	}
+}
+
+// nodePVs returns the PVs of this provisioner placed on this node, of every
+// storage class and including warm volumes, read from the shared PV informer.
+func (p *hostPathProvisioner) nodePVs() []*v1.PersistentVolume {
+	var pvs []*v1.PersistentVolume
+	for _, pv := range p.volumes.ByNode(p.nodeName) {
+		if pv.Annotations[annIdentity] == p.identity {
+			pvs = append(pvs, pv)
+		}
+	}
+	return pvs
+}
+
+// nodeVolumes returns the capacity of the PVs, keyed by PV name.
+func nodeVolumes(pvs []*v1.PersistentVolume) map[string]int64 {
+	volumes := make(map[string]int64, len(pvs))
+	for _, pv := range pvs {
+		volumes[pv.Name] = pv.Spec.Capacity.Storage().Value()
+	}
+	return volumes
+}
+
+// updateDiskRecords records a provisioned volume in the DiskMonitor of this
+// node or drops a deleted one. Provisioning and deleting workers run
+// concurrently, so the change is applied to the stored DiskMonitor and
+// retried on conflicts; applying it twice has the same outcome.
+func (p *hostPathProvisioner) updateDiskRecords(args *monitor_disk.ModifyDiskArgs) error {
+	var modify func(status *diskv2.DiskMonitorStatus)
 	switch args.Operation {
 	case monitor_disk.OPERATE_UPDATE:
-		{
-			if monitor.Status.DiskInfo != nil {
-				monitor.Status.DiskInfo[diskv1.PVPath(args.Path)] = *args.DiskInfo
-			} else {
-				monitor.Status.DiskInfo = map[diskv1.PVPath]diskv1.DiskDetail{
-					diskv1.PVPath(args.Path): *args.DiskInfo,
-				}
-			}
-
-			monitor.Status.Required.Add(*args.Require)
-			_, err = monitor_disk.Update(args.Namespace, monitor)
-			if err != nil {
-				glog.Error("update operation update monitor disk info err %v", err)
-				return err
-			}
+		modify = func(status *diskv2.DiskMonitorStatus) {
+			monitor_disk.AddDisk(status, *args.Volume)
 		}
 	case monitor_disk.OPERATE_DELETE:
-		{
-			glog.Info("delete pv info", monitor.Status.DiskInfo[diskv1.PVPath(args.Path)])
-			delete(monitor.Status.DiskInfo, diskv1.PVPath(args.Path))
-			monitor.Status.Required.Sub(*args.Require)
-			_, err = monitor_disk.Update(args.Namespace, monitor)
-			if err != nil {
-				glog.Error("delete operation update monitor disk info err %v", err)
-				return err
-			}
+		glog.Info("delete pv info ", args.Path)
+		modify = func(status *diskv2.DiskMonitorStatus) {
+			monitor_disk.RemoveDisk(status, args.Path)
 		}
 	default:
 		defaultErr := fmt.Sprintf("invalid operation %s", args.Operation)
 		glog.Error(defaultErr)
 		return errors.New(defaultErr)
 	}
+	_, err := monitor_disk.ModifyStatus(p.diskMonitors, args.Namespace, args.CRName, modify)
+	if apierrors.IsNotFound(err) {
+		if err = p.createDiskMonitorCR(); err != nil && !apierrors.IsAlreadyExists(err) {
+			glog.Errorf("create monitor disk err: %v", err)
+			return err
+		}
+		_, err = monitor_disk.ModifyStatus(p.diskMonitors, args.Namespace, args.CRName, modify)
+	}
+	if err != nil {
+		glog.Errorf("%s operation update monitor disk info err: %v", args.Operation, err)
+	}
 	return err
 }
 
+// ProvisionExt creates the volume like Provision, and asks the controller to
+// reschedule the claim when the selected node does not have enough capacity.
+func (p *hostPathProvisioner) ProvisionExt(options controller.ProvisionOptions) (*v1.PersistentVolume, controller.ProvisioningState, error) {
+	pv, err := p.Provision(options)
+	if err == nil {
+		return pv, controller.ProvisioningFinished, nil
+	}
+	if capacityErr, ok := err.(*capacity.InsufficientCapacityError); ok && p.preemptor != nil {
+		// the claim stays on the node and is retried until the preempted volumes are gone
+		started, preemptErr := p.preemptor.Preempt(options.PVC, capacityErr.Requested-capacityErr.Allocatable)
+		if preemptErr != nil {
+			glog.Errorf("Unable to preempt claims for %s/%s: %v", options.PVC.Namespace, options.PVC.Name, preemptErr)
+		} else if started {
+			return nil, controller.ProvisioningInBackground, fmt.Errorf("node %s: %v, waiting for volumes to be deleted", p.nodeName, err)
+		}
+	}
+	if _, ok := err.(*capacity.InsufficientCapacityError); ok && isReschedulable(options.PVC.GetAnnotations()) {
+		return nil, controller.ProvisioningReschedule, fmt.Errorf("node %s: %v", p.nodeName, err)
+	}
+	if _, ok := err.(*claimgroups.ConstraintError); ok && isReschedulable(options.PVC.GetAnnotations()) {
+		return nil, controller.ProvisioningReschedule, err
+	}
+	if _, ok := err.(*maintenance.Error); ok && isReschedulable(options.PVC.GetAnnotations()) {
+		return nil, controller.ProvisioningReschedule, err
+	}
+	if _, ok := err.(*storageClassError); ok && isReschedulable(options.PVC.GetAnnotations()) {
+		return nil, controller.ProvisioningReschedule, err
+	}
+	if _, ok := err.(*poolError); ok && isReschedulable(options.PVC.GetAnnotations()) {
+		return nil, controller.ProvisioningReschedule, err
+	}
+	if _, ok := err.(*capacity.TooManyVolumesError); ok && isReschedulable(options.PVC.GetAnnotations()) {
+		return nil, controller.ProvisioningReschedule, fmt.Errorf("node %s: %v", p.nodeName, err)
+	}
+	return nil, controller.ProvisioningFinished, err
+}
+
 // Provision creates a storage asset and returns a PV object representing it.
 func (p *hostPathProvisioner) Provision(options controller.ProvisionOptions) (*v1.PersistentVolume, error) {
 	vPath := path.Join(p.pvDir, options.PVName)
@@ -243,59 +781,122 @@ func (p *hostPathProvisioner) Provision(options controller.ProvisionOptions) (*v
 	}
 
 	if pvCapacity != nil {
-		glog.Infof("creating backing directory: %v", vPath)
-		if err := os.MkdirAll(vPath, 0777); err != nil {
+		// the reservation is confirmed or released by the controller once the outcome is known
+		pvName := options.PVC.Namespace + "." + options.PVName
+		size := options.PVC.Spec.Resources.Requests.Storage().Value()
+		// the volume would sit in a pool other than the one the claim asked for
+		if err := p.checkPool(options.PVC); err != nil {
 			return nil, err
 		}
+		// claims admitted before the node went into maintenance may finish
+		if !p.ledger.IsReserved(string(options.PVC.UID)) {
+			if err := p.checkAdmission(util.GetPersistentVolumeClaimClass(options.PVC)); err != nil {
+				return nil, err
+			}
+		}
+		var permissions os.FileMode
+		value, hasPermissions := options.PVC.Annotations[nodevolumes.AnnPermissions]
+		if hasPermissions {
+			if permissions, err = nodevolumes.ParsePermissions(value); err != nil {
+				return nil, err
+			}
+		}
+		members, err := p.groupMembers(options.PVC)
+		if err != nil {
+			return nil, err
+		}
+		// the rest of the group is reserved along with the claim, so that no
+		// other claim is admitted into the room the group needs
+		claim := capacity.Request{ClaimUID: string(options.PVC.UID), PVName: pvName, Size: size}
+		if err := p.ledger.ReserveGroup(claim, members, capacity.DefaultGroupHold, p.computeCapacity); err != nil {
+			return nil, err
+		}
+		if p.warmPool != nil && p.warmPool.Take(options.StorageClass, vPath) {
+			glog.Infof("took warm backing directory: %v", vPath)
+		} else {
+			glog.Infof("creating backing directory: %v", vPath)
+			if err := os.MkdirAll(vPath, 0777); err != nil {
+				return nil, err
+			}
+		}
+		// the controller releases the reservation when provisioning fails,
+		// the directory must not be left behind either
+		removeDirectory := func() {
+			if removeErr := os.RemoveAll(vPath); removeErr != nil {
+				glog.Errorf("removing backing directory: %v, err: %v", vPath, removeErr)
+			}
+		}
+		if hasPermissions {
+			if err := os.Chmod(vPath, permissions); err != nil {
+				removeDirectory()
+				return nil, err
+			}
+		}
+		pv := p.newPersistentVolume(pvName, vPath, *options.PVC.Spec.Resources.Requests.Storage())
+		pool := p.pool
+		if value, ok := options.PVC.Annotations[nodevolumes.AnnPool]; ok {
+			pool = value
+			pv.Annotations[nodevolumes.AnnPool] = pool
+		}
+		now := metav1.Now()
 		var monitorArgs = monitor_disk.ModifyDiskArgs{
 			CRName:          p.nodeName,
 			Namespace:       p.namespace,
 			OwnerReferences: p.ownerReferences,
 			Path:            vPath,
 			Operation:       monitor_disk.OPERATE_UPDATE,
-			DiskInfo: &diskv1.DiskDetail{
-				diskv1.Detail{
-					"pvName":  options.PVName,
-					"require": options.PVC.Spec.Resources.Requests.Storage().String(),
-				},
+			Volume: &diskv2.Volume{
+				PersistentVolume: pvName,
+				Claim:            &diskv2.ClaimReference{Namespace: options.PVC.Namespace, Name: options.PVC.Name},
+				Pool:             pool,
+				Path:             vPath,
+				Requested:        *options.PVC.Spec.Resources.Requests.Storage(),
+				CreationTime:     &now,
+				Phase:            diskv2.VolumeProvisioning,
 			},
-			Require: options.PVC.Spec.Resources.Requests.Storage(),
 		}
-		if err = updateDiskRecords(&monitorArgs); err != nil {
+		if err = p.updateDiskRecords(&monitorArgs); err != nil {
+			removeDirectory()
 			return nil, err
 		}
-		pv := &v1.PersistentVolume{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: options.PVC.Namespace + "." + options.PVName,
-				Annotations: map[string]string{
-					"hostPathProvisionerIdentity": p.identity,
-					"kubevirt.io/provisionOnNode": p.nodeName,
-				},
+		return pv, nil
+	}
+	return nil, err
+}
+
+// newPersistentVolume returns the PV of a backing directory of this node.
+func (p *hostPathProvisioner) newPersistentVolume(name, vPath string, size resource.Quantity) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				annIdentity:                   p.identity,
+				"kubevirt.io/provisionOnNode": p.nodeName,
 			},
-			Spec: v1.PersistentVolumeSpec{
-				PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
-				AccessModes: []v1.PersistentVolumeAccessMode{
-					v1.ReadWriteOnce,
-				},
-				Capacity: v1.ResourceList{
-					v1.ResourceName(v1.ResourceStorage): *options.PVC.Spec.Resources.Requests.Storage(),
-				},
-				PersistentVolumeSource: v1.PersistentVolumeSource{
-					HostPath: &v1.HostPathVolumeSource{
-						Path: vPath,
-					},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			AccessModes: []v1.PersistentVolumeAccessMode{
+				v1.ReadWriteOnce,
+			},
+			Capacity: v1.ResourceList{
+				v1.ResourceName(v1.ResourceStorage): size,
+			},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: vPath,
 				},
-				NodeAffinity: &v1.VolumeNodeAffinity{
-					Required: &v1.NodeSelector{
-						NodeSelectorTerms: []v1.NodeSelectorTerm{
-							{
-								MatchExpressions: []v1.NodeSelectorRequirement{
-									{
-										Key:      "kubernetes.io/hostname",
-										Operator: v1.NodeSelectorOpIn,
-										Values: []string{
-											p.nodeName,
-										},
+			},
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{
+									Key:      "kubernetes.io/hostname",
+									Operator: v1.NodeSelectorOpIn,
+									Values: []string{
+										p.nodeName,
 									},
 								},
 							},
@@ -303,10 +904,97 @@ func (p *hostPathProvisioner) Provision(options controller.ProvisionOptions) (*v
 					},
 				},
 			},
+		},
+	}
+}
+
+// createWarmVolume creates a warm pool PV of the storage class on this node.
+// It is accounted for in the ledger like a provisioned volume.
+func (p *hostPathProvisioner) createWarmVolume(class *storage.StorageClass, size resource.Quantity) (*v1.PersistentVolume, error) {
+	if err := p.checkAdmission(class.Name); err != nil {
+		return nil, err
+	}
+	name := "hostpath-warm-" + utilrand.String(10)
+	vPath := path.Join(p.pvDir, name)
+	reservation := "warm/" + name
+	if err := p.ledger.Reserve(reservation, name, size.Value(), p.computeCapacity); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(vPath, 0777); err != nil {
+		p.ledger.Release(reservation)
+		return nil, err
+	}
+	pv := p.newPersistentVolume(name, vPath, size)
+	pv.Labels = map[string]string{warmpool.LabelWarmPool: "true"}
+	// lets the controller delete the volume once its claim is gone
+	pv.Annotations[annProvisionedBy] = provisionerName
+	pv.Spec.StorageClassName = class.Name
+	pv.Spec.MountOptions = class.MountOptions
+	if class.ReclaimPolicy != nil {
+		pv.Spec.PersistentVolumeReclaimPolicy = *class.ReclaimPolicy
+	}
+	created, err := p.client.CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{})
+	if err != nil {
+		p.ledger.Release(reservation)
+		if removeErr := os.RemoveAll(vPath); removeErr != nil {
+			glog.Errorf("removing backing directory: %v, err: %v", vPath, removeErr)
 		}
-		return pv, nil
+		return nil, err
+	}
+	p.ledger.Confirm(reservation)
+	p.capacityChanged()
+	return created, nil
+}
+
+// handOverWarmVolume applies to a warm volume the PV controller bound to a
+// claim what Provision applies to the volume of a claim: the permissions of
+// the directory and the pool stamp. The binding can not be undone, a claim
+// whose pool or group the node does not satisfy gets a warning event.
+func (p *hostPathProvisioner) handOverWarmVolume(pv *v1.PersistentVolume) error {
+	ref := pv.Spec.ClaimRef
+	claim, err := p.client.CoreV1().PersistentVolumeClaims(ref.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || err == nil && claim.UID != ref.UID {
+		// the volume is released along with the claim
+		return nil
+	} else if err != nil {
+		return err
+	}
+	updated := pv.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	if value, ok := claim.Annotations[nodevolumes.AnnPermissions]; ok {
+		if permissions, err := nodevolumes.ParsePermissions(value); err != nil {
+			p.warnWarmVolume(claim, pv, "InvalidPermissions", err)
+		} else if err := os.Chmod(pv.Spec.HostPath.Path, permissions); err != nil {
+			return err
+		}
+	}
+	if err := p.checkPool(claim); err != nil {
+		p.warnWarmVolume(claim, pv, admissionReason(err), err)
+	} else if value, ok := claim.Annotations[nodevolumes.AnnPool]; ok {
+		updated.Annotations[nodevolumes.AnnPool] = value
+	}
+	if p.groups != nil {
+		if err := p.groups.Constraint(claim).Check(p.nodeName); err != nil {
+			p.warnWarmVolume(claim, pv, "GroupMismatch", err)
+		}
+	}
+	updated.Annotations[warmpool.AnnClaimed] = string(claim.UID)
+	if _, err := p.client.CoreV1().PersistentVolumes().Update(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	glog.Infof("handed warm volume %s over to claim %s/%s", pv.Name, claim.Namespace, claim.Name)
+	return nil
+}
+
+// warnWarmVolume records that the warm volume bound to the claim does not
+// satisfy it.
+func (p *hostPathProvisioner) warnWarmVolume(claim *v1.PersistentVolumeClaim, pv *v1.PersistentVolume, reason string, err error) {
+	glog.Errorf("warm volume %s bound to claim %s/%s: %v", pv.Name, claim.Namespace, claim.Name, err)
+	if p.recorder != nil {
+		p.recorder.Eventf(claim, v1.EventTypeWarning, reason, "warm volume %s bound by the PV controller: %v", pv.Name, err)
 	}
-	return nil, err
 }
 
 func (p *hostPathProvisioner) GetNodeName() string {
@@ -322,7 +1010,7 @@ func (p *hostPathProvisioner) GetOwnerReferences() string {
 // Delete removes the storage asset that was created by Provision represented
 // by the given PV.
 func (p *hostPathProvisioner) Delete(volume *v1.PersistentVolume) error {
-	ann, ok := volume.Annotations["hostPathProvisionerIdentity"]
+	ann, ok := volume.Annotations[annIdentity]
 	if !ok {
 		return errors.New("identity annotation not found on PV")
 	}
@@ -334,37 +1022,73 @@ func (p *hostPathProvisioner) Delete(volume *v1.PersistentVolume) error {
 	}
 
 	path := volume.Spec.PersistentVolumeSource.HostPath.Path
-	glog.Infof("removing backing directory: %v", path)
-	if err := os.RemoveAll(path); err != nil {
-		glog.Error("removing backing directory: %v,err: %v", path, err)
+	if err := p.removeDirectory(path); err != nil {
+		glog.Errorf("removing backing directory %s: %v", path, err)
 		return err
 	}
+	p.ledger.Remove(volume.Name)
+	p.capacityChanged()
 	var monitorArgs = monitor_disk.ModifyDiskArgs{
 		Namespace:       p.namespace,
 		CRName:          p.nodeName,
 		OwnerReferences: p.ownerReferences,
 		Path:            path,
 		Operation:       monitor_disk.OPERATE_DELETE,
-		DiskInfo:        nil,
-		Require:         volume.Spec.Capacity.Storage(),
 	}
-	if err := updateDiskRecords(&monitorArgs); err != nil {
+	if err := p.updateDiskRecords(&monitorArgs); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func getClientSet() kubernetes.Interface {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		glog.Fatalf("Failed to create config: %v", err)
+// removeDirectory moves a directory of the pool to the trash while the spec
+// sets a trash retention, and removes it otherwise.
+func (p *hostPathProvisioner) removeDirectory(path string) error {
+	if retention := p.currentSpec().TrashRetention; p.trash != nil && retention != nil && retention.Duration > 0 {
+		glog.Infof("moving backing directory to the trash: %v", path)
+		return p.trash.Move(path)
+	}
+	glog.Infof("removing backing directory: %v", path)
+	return os.RemoveAll(path)
+}
+
+// collectGarbage removes the trash entries past their retention, all of them
+// when the spec sets none. When the spec sets a GC retention, the orphans of
+// the pool older than it are removed like the directory of a deleted volume.
+func (p *hostPathProvisioner) collectGarbage(volumes []diskv2.Volume) {
+	spec := p.currentSpec()
+	if p.trash != nil {
+		var retention time.Duration
+		if spec.TrashRetention != nil {
+			retention = spec.TrashRetention.Duration
+		}
+		purged, err := p.trash.Purge(retention)
+		for _, path := range purged {
+			glog.Infof("purged from the trash: %v", path)
+		}
+		if err != nil {
+			glog.Errorf("Unable to purge the trash of node %s: %v", p.nodeName, err)
+		}
+	}
+	if spec.GCRetention == nil {
+		return
+	}
+	retention := spec.GCRetention.Duration
+	if retention < orphanGracePeriod {
+		retention = orphanGracePeriod
 	}
-	clientSet, err := kubernetes.NewForConfig(config)
+	orphans, err := findOrphans(p.pvDir, volumes, time.Now().Add(-retention))
 	if err != nil {
-		glog.Fatalf("Failed to create client: %v", err)
+		glog.Errorf("Unable to list the orphans of node %s: %v", p.nodeName, err)
+		return
+	}
+	for _, orphan := range orphans {
+		glog.Infof("garbage collecting orphan: %v", orphan)
+		if err := p.removeDirectory(orphan); err != nil {
+			glog.Errorf("removing orphan: %v, err: %v", orphan, err)
+		}
 	}
-	return clientSet
 }
 
 func calculatePvCapacity(path string) (*resource.Quantity, error) {
@@ -394,8 +1118,8 @@ func roundDownCapacityPretty(capacityBytes int64) int64 {
 	}
 	return capacityBytes
 }
-func getDaemonSet(ns, ownerReferences string) (*appsv1.DaemonSet, error) {
-	daemonSetsList, err := getClientSet().AppsV1().DaemonSets(ns).List(context.TODO(), metav1.ListOptions{})
+func getDaemonSet(client kubernetes.Interface, ns, ownerReferences string) (*appsv1.DaemonSet, error) {
+	daemonSetsList, err := client.AppsV1().DaemonSets(ns).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		glog.Error("get daemonSet err: ", err)
 		return nil, err
@@ -409,41 +1133,24 @@ func getDaemonSet(ns, ownerReferences string) (*appsv1.DaemonSet, error) {
 	glog.Error(errMsg)
 	return nil, errors.New(errMsg)
 }
-func createDiskMonitorCR(ns, ownerReferences, nodeName string) error {
-	mpDiskInfo := map[diskv1.PVPath]diskv1.DiskDetail{}
-	pvCapacity, _ := calculatePvCapacity("/mnt/disks/")
-	pvs, err := getExistPV()
+func (p *hostPathProvisioner) createDiskMonitorCR() error {
+	ns, nodeName := p.namespace, p.nodeName
+	// the reconciler fills the total in once the pool can be read
+	pvCapacity, err := calculatePvCapacity(p.pvDir)
 	if err != nil {
-		return err
+		glog.Errorf("Failed to read the size of pool %s: %v", p.pvDir, err)
 	}
 
-	daemonSet, errds := getDaemonSet(ns, ownerReferences)
+	daemonSet, errds := getDaemonSet(p.client, ns, p.ownerReferences)
 	if errds != nil {
 		return errds
 	}
-	var required resource.Quantity
-	if pvs != nil {
-		for _, pv := range pvs.Items {
-			if !isPVOnCurrentNode(nodeName, pv.Annotations["kubevirt.io/provisionOnNode"]) {
-				continue
-			}
+	required, volumes := p.diskVolumes(p.nodePVs())
 
-			required.Add(*pv.Spec.Capacity.Storage())
-			if pv.Spec.StorageClassName == StorageClassName {
-				mpDiskInfo[diskv1.PVPath(pv.Spec.HostPath.Path)] = diskv1.DiskDetail{
-					diskv1.Detail{
-						"pvName":  pv.Name,
-						"require": pv.Spec.Capacity.Storage().String(),
-					},
-				}
-			}
-		}
-	}
-
-	var monitor = diskv1.DiskMonitor{
+	var monitor = diskv2.DiskMonitor{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "DiskMonitor",
-			APIVersion: "diskmonitor.domain/v1",
+			APIVersion: diskv2.SchemeGroupVersion.String(),
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name: nodeName,
@@ -455,62 +1162,211 @@ func createDiskMonitorCR(ns, ownerReferences, nodeName string) error {
 				}),
 			},
 		},
-		Status: diskv1.DiskMonitorStatus{
+		Status: diskv2.DiskMonitorStatus{
 			Total:    pvCapacity,
 			Required: &required,
-			DiskInfo: mpDiskInfo,
+			Pool:     p.pool,
 		},
 	}
-	_, err = monitor_disk.Create(ns, &monitor)
+	monitor_disk.SetVolumes(&monitor.Status, volumes)
+	created, err := monitor_disk.Create(p.diskMonitors, ns, &monitor)
 	if err != nil {
 		return err
 	}
-	return nil
+	// the status is not written on create
+	created.Status = monitor.Status
+	_, err = monitor_disk.UpdateStatus(p.diskMonitors, ns, created)
+	return err
 }
 
-func InspectionMonitorDisk(ctx context.Context, nodeName, ns, cRName string) {
+// diskVolumes returns the volume entries of the PVs of this node and of the
+// given volumes being provisioned, sorted by path, and the sum of their
+// requests.
+func (p *hostPathProvisioner) diskVolumes(pvs []*v1.PersistentVolume, provisioning ...diskv2.Volume) (resource.Quantity, []diskv2.Volume) {
+	var required resource.Quantity
+	var volumes []diskv2.Volume
+	for _, pv := range pvs {
+		required.Add(*pv.Spec.Capacity.Storage())
+		volumes = append(volumes, p.diskVolume(pv))
+	}
+	for _, volume := range provisioning {
+		required.Add(volume.Requested)
+		volumes = append(volumes, volume)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Path < volumes[j].Path })
+	return required, volumes
+}
 
-	for {
-		var CurCap resource.Quantity
-		mpDiskInfo := map[diskv1.PVPath]diskv1.DiskDetail{}
-		time.Sleep(time.Second * 5)
-		pvs, err := getExistPV()
-		if err != nil {
-			glog.Error("get existed PV err", err)
-			continue
+// diskVolume returns the volume entry of a PV of this node.
+func (p *hostPathProvisioner) diskVolume(pv *v1.PersistentVolume) diskv2.Volume {
+	volume := diskv2.Volume{
+		PersistentVolume: pv.Name,
+		Pool:             p.pool,
+		Path:             pv.Spec.HostPath.Path,
+		Requested:        *pv.Spec.Capacity.Storage(),
+		CreationTime:     pv.CreationTimestamp.DeepCopy(),
+		Phase:            diskv2.VolumePhase(pv.Status.Phase),
+	}
+	if pool, ok := pv.Annotations[nodevolumes.AnnPool]; ok {
+		volume.Pool = pool
+	}
+	if pv.Spec.ClaimRef != nil {
+		volume.Claim = &diskv2.ClaimReference{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}
+	}
+	if p.usage != nil {
+		if used, ok := p.usage.Get(volume.Path); ok {
+			volume.Used = used
 		}
-		monitorDisk, err := monitor_disk.Get(ns, cRName)
-		if err != nil {
-			glog.Error("get monitor disk CR err", err)
-			continue
+	}
+	return volume
+}
+
+// volumePaths returns the backing directories of the PVs of this node.
+func (p *hostPathProvisioner) volumePaths() []string {
+	var paths []string
+	for _, pv := range p.nodePVs() {
+		paths = append(paths, pv.Spec.HostPath.Path)
+	}
+	return paths
+}
+
+// usageChanged refreshes the DiskMonitor with the usage measured last.
+func (p *hostPathProvisioner) usageChanged() {
+	if p.reconciler != nil {
+		p.reconciler.Enqueue()
+	}
+}
+
+// provisioningVolumes returns the entries recorded by Provision whose PV is
+// not stored yet, as long as the ledger still holds them.
+func (p *hostPathProvisioner) provisioningVolumes(recorded []diskv2.Volume, pvs []*v1.PersistentVolume) []diskv2.Volume {
+	stored := make(map[string]bool, len(pvs))
+	for _, pv := range pvs {
+		stored[pv.Name] = true
+	}
+	var volumes []diskv2.Volume
+	for _, volume := range recorded {
+		if volume.Phase == diskv2.VolumeProvisioning && !stored[volume.PersistentVolume] && p.ledger.Holds(volume.PersistentVolume) {
+			volumes = append(volumes, volume)
 		}
+	}
+	return volumes
+}
 
-		if pvs != nil {
-			for _, pv := range pvs.Items {
-				if !isPVOnCurrentNode(nodeName, pv.Annotations["kubevirt.io/provisionOnNode"]) {
-					continue
-				}
-				if pv.Spec.StorageClassName == StorageClassName {
-					CurCap.Add(*pv.Spec.Capacity.Storage())
-					mpDiskInfo[diskv1.PVPath(pv.Spec.HostPath.Path)] = diskv1.DiskDetail{
-						diskv1.Detail{
-							"pvName":  pv.Name,
-							"require": pv.Spec.Capacity.Storage().String(),
-						},
-					}
-				} else {
-					continue
-				}
-			}
-		} else {
+// orphanGracePeriod is how long a directory of the pool may exist without a
+// volume before it is reported as an orphan, it covers the time between
+// creating the directory of a volume and recording it.
+const orphanGracePeriod = 5 * time.Minute
+
+// maxOrphansListed is how many orphans the OrphansDetected condition names.
+const maxOrphansListed = 5
+
+// orphansCondition returns the OrphansDetected condition of the pool given the
+// volumes placed on it.
+func (p *hostPathProvisioner) orphansCondition(volumes []diskv2.Volume) diskv2.DiskMonitorCondition {
+	orphans, err := findOrphans(p.pvDir, volumes, time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		return diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorOrphansDetected,
+			Status:  v1.ConditionUnknown,
+			Reason:  "ListFailed",
+			Message: err.Error(),
+		}
+	}
+	if len(orphans) == 0 {
+		return diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorOrphansDetected,
+			Status:  v1.ConditionFalse,
+			Reason:  "NoOrphans",
+			Message: "every directory of the pool backs a volume",
+		}
+	}
+	listed := orphans
+	if len(listed) > maxOrphansListed {
+		listed = append(listed[:maxOrphansListed:maxOrphansListed], "...")
+	}
+	return diskv2.DiskMonitorCondition{
+		Type:    diskv2.DiskMonitorOrphansDetected,
+		Status:  v1.ConditionTrue,
+		Reason:  "OrphansDetected",
+		Message: fmt.Sprintf("%d directories back no volume: %s", len(orphans), strings.Join(listed, ", ")),
+	}
+}
+
+// findOrphans returns the directories of pvDir, sorted by name, that back none
+// of the volumes and were last modified before notAfter. Hidden directories,
+// such as the warm pool, are not volumes and skipped.
+func findOrphans(pvDir string, volumes []diskv2.Volume, notAfter time.Time) ([]string, error) {
+	entries, err := ioutil.ReadDir(pvDir)
+	if err != nil {
+		return nil, err
+	}
+	backing := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		backing[filepath.Clean(volume.Path)] = true
+	}
+	var orphans []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || entry.ModTime().After(notAfter) {
 			continue
 		}
-		monitorDisk.Status.Required = &CurCap
-		monitorDisk.Status.DiskInfo = mpDiskInfo
-		if _, err = monitor_disk.Update(ns, monitorDisk); err != nil {
-			glog.Error("update monitor disk err: ", err)
+		dir := filepath.Join(pvDir, entry.Name())
+		if !backing[dir] {
+			orphans = append(orphans, dir)
 		}
 	}
+	return orphans, nil
+}
+
+// diskMonitorStatus applies the spec of the DiskMonitor of this node and
+// computes its status from the PVs placed on it and the state of the pool. It
+// refreshes the ledger, the storage pressure and the advertised resources and
+// collects the garbage of the pool along the way.
+func (p *hostPathProvisioner) diskMonitorStatus(monitorDisk *diskv2.DiskMonitor) (*diskv2.DiskMonitorStatus, error) {
+	if err := p.applySpec(&monitorDisk.Spec); err != nil {
+		return nil, err
+	}
+	status := monitorDisk.Status.DeepCopy()
+	pvs := p.nodePVs()
+	p.ledger.Rebuild(nodeVolumes(pvs))
+	required, volumes := p.diskVolumes(pvs, p.provisioningVolumes(monitorDisk.Status.Volumes, pvs)...)
+	status.Required = &required
+	status.Pool = p.pool
+	monitor_disk.SetVolumes(status, volumes)
+	if poolCapacity, err := p.currentCapacity(); err != nil {
+		glog.Error("get pool stats err: ", err)
+		monitor_disk.SetCondition(status, diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorPoolMounted,
+			Status:  v1.ConditionFalse,
+			Reason:  "PoolUnavailable",
+			Message: err.Error(),
+		})
+		monitor_disk.SetCondition(status, diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorCapacityLow,
+			Status:  v1.ConditionUnknown,
+			Reason:  "PoolUnavailable",
+			Message: "capacity of the pool is unknown",
+		})
+	} else {
+		// the filesystem may have been grown or replaced since the last reconcile
+		status.Total = resource.NewQuantity(roundDownCapacityPretty(poolCapacity.Total), resource.BinarySI)
+		status.Free = resource.NewQuantity(poolCapacity.Available, resource.BinarySI)
+		status.Allocatable = resource.NewQuantity(poolCapacity.Allocatable, resource.BinarySI)
+		underPressure := p.reportPressure(poolCapacity)
+		p.advertiseResources(poolCapacity)
+		monitor_disk.SetCondition(status, diskv2.DiskMonitorCondition{
+			Type:    diskv2.DiskMonitorPoolMounted,
+			Status:  v1.ConditionTrue,
+			Reason:  "PoolAvailable",
+			Message: "pool at " + p.pvDir + " is available",
+		})
+		monitor_disk.SetCondition(status, p.capacityLowCondition(poolCapacity, underPressure))
+		status.Forecast = p.forecast(poolCapacity)
+	}
+	p.collectGarbage(volumes)
+	monitor_disk.SetCondition(status, p.orphansCondition(volumes))
+	monitor_disk.SetCondition(status, p.maintenanceCondition())
+	return status, nil
 }
 
 func main() {
@@ -530,6 +1386,11 @@ func main() {
 		glog.Fatalf("Failed to create client: %v", err)
 	}
 
+	diskMonitorClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create DiskMonitor client: %v", err)
+	}
+
 	// The controller needs to know what the server version is because out-of-tree
 	// provisioners aren't officially supported until 1.5
 	serverVersion, err := clientset.Discovery().ServerVersion()
@@ -537,22 +1398,136 @@ func main() {
 		glog.Fatalf("Error getting server version: %v", err)
 	}
 
+	// A single PV informer, indexed by node and storage class, serves the
+	// controller and every capacity calculation of this node
+	informerFactory := informers.NewSharedInformerFactory(clientset, controller.DefaultResyncPeriod)
+	volumeInformer := informerFactory.Core().V1().PersistentVolumes().Informer()
+	volumes, err := nodevolumes.New(volumeInformer)
+	if err != nil {
+		glog.Fatalf("Failed to index persistent volumes: %v", err)
+	}
+	classInformer := informerFactory.Storage().V1().StorageClasses().Informer()
+	informerFactory.Start(wait.NeverStop)
+	if !cache.WaitForCacheSync(wait.NeverStop, volumes.HasSynced, classInformer.HasSynced) {
+		glog.Fatalf("Failed to sync persistent volumes and storage classes")
+	}
+	// The claim informer is started after the controller added its indexers
+	claimInformer := informerFactory.Core().V1().PersistentVolumeClaims().Informer()
+	groups, err := claimgroups.New(claimInformer, volumeInformer)
+	if err != nil {
+		glog.Fatalf("Failed to index claim groups: %v", err)
+	}
+
+	// Only the Node of this provisioner is watched, for maintenance
+	nodeInformerFactory := informers.NewSharedInformerFactoryWithOptions(clientset, controller.DefaultResyncPeriod,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", os.Getenv("NODE_NAME")).String()
+		}))
+	nodeInformer := nodeInformerFactory.Core().V1().Nodes()
+	nodeInformer.Informer()
+	nodeInformerFactory.Start(wait.NeverStop)
+	if !cache.WaitForCacheSync(wait.NeverStop, nodeInformer.Informer().HasSynced) {
+		glog.Fatalf("Failed to sync node")
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(glog.Infof)
+	broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: clientset.CoreV1().Events(v1.NamespaceAll)})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: defaultProvisionerName, Host: os.Getenv("NODE_NAME")})
+
 	// Create the provisioner: it implements the Provisioner interface expected by
 	// the controller
-	hostPathProvisioner := NewHostPathProvisioner()
+	hostPathProvisioner := NewHostPathProvisioner(clientset, diskMonitorClient, volumes, groups, nodeInformer.Lister(), recorder)
+	hostPathProvisioner.preemptor = preemption.New(clientset, hostPathProvisioner.GetNodeName(),
+		informerFactory.Core().V1().PersistentVolumeClaims().Lister(), volumes, recorder)
+	// The forecast picks up the history of the pool where the previous run left it
+	if err := hostPathProvisioner.history.Load(); err != nil {
+		glog.Errorf("Failed to load the history of pool %s, starting over: %v", hostPathProvisioner.pool, err)
+	}
 
-	err = createDiskMonitorCR(hostPathProvisioner.GetNamespace(), hostPathProvisioner.GetOwnerReferences(), hostPathProvisioner.GetNodeName())
-	if err != nil {
-		if !strings.Contains(fmt.Sprintf("%s", err), RESOURCE_ALREADY_EXISTS) {
-			glog.Error("create Monitor CR err,process exited!: ", err)
+	err = hostPathProvisioner.createDiskMonitorCR()
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		glog.Error("create Monitor CR err,process exited!: ", err)
+		return
+	}
+	// The spec is applied before any claim is provisioned, the reconciler
+	// applies its later changes
+	if monitor, err := diskMonitorClient.DiskMonitorV2().DiskMonitors(hostPathProvisioner.GetNamespace()).Get(context.TODO(),
+		hostPathProvisioner.GetNodeName(), metav1.GetOptions{}); err != nil {
+		glog.Errorf("Failed to get DiskMonitor %s: %v", hostPathProvisioner.GetNodeName(), err)
+	} else if err := hostPathProvisioner.applySpec(&monitor.Spec); err != nil {
+		glog.Error(err)
+	}
+	// Storage classes with the warmPoolDirectories or warmPoolVolumes parameters
+	// get directories and volumes created ahead of their claims
+	hostPathProvisioner.warmPool = warmpool.New(hostPathProvisioner.pvDir, hostPathProvisioner.GetNodeName(), provisionerName,
+		classInformer.GetStore(), volumes, hostPathProvisioner.createWarmVolume, hostPathProvisioner.handOverWarmVolume)
+	volumeInformer.AddEventHandler(hostPathProvisioner.warmPool.EventHandler())
+	hostPathProvisioner.rebuildLedger()
+	// Publishing CSIStorageCapacity objects lets the scheduler pick nodes with enough room for
+	// WaitForFirstConsumer claims, see deploy/storage-capacity.yaml
+	if strings.ToLower(os.Getenv("PUBLISH_STORAGE_CAPACITY")) == "true" {
+		if err := storagecapacity.ValidateDriverName(provisionerName); err != nil {
+			glog.Fatalf("PUBLISH_STORAGE_CAPACITY needs a PROVISIONER_NAME matching the CSIDriver: %v", err)
+		}
+		dynamicClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			glog.Fatalf("Failed to create dynamic client: %v", err)
+		}
+		daemonSet, err := getDaemonSet(clientset, hostPathProvisioner.GetNamespace(), hostPathProvisioner.GetOwnerReferences())
+		if err != nil {
+			glog.Error("get daemonSet err,process exited!: ", err)
 			return
 		}
+		owner := metav1.NewControllerRef(daemonSet, appsv1.SchemeGroupVersion.WithKind("DaemonSet"))
+		hostPathProvisioner.publisher = storagecapacity.NewPublisher(dynamicClient, os.Getenv("STORAGE_CAPACITY_VERSION"),
+			hostPathProvisioner.GetNamespace(), hostPathProvisioner.GetNodeName(), provisionerName, owner, classInformer.GetStore(), hostPathProvisioner.currentCapacity)
+		go hostPathProvisioner.publisher.Run(wait.NeverStop)
+	}
+	go hostPathProvisioner.warmPool.Run(wait.NeverStop)
+	// The DiskMonitor of this node is reconciled when its PVs, the DiskMonitor
+	// or the Node change, instead of being rewritten periodically
+	diskMonitorInformerFactory := diskmonitorinformers.NewSharedInformerFactoryWithOptions(diskMonitorClient, controller.DefaultResyncPeriod,
+		diskmonitorinformers.WithNamespace(hostPathProvisioner.GetNamespace()),
+		diskmonitorinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", hostPathProvisioner.GetNodeName()).String()
+		}))
+	reconciler := monitor_disk.NewReconciler(diskMonitorClient, hostPathProvisioner.GetNamespace(), hostPathProvisioner.GetNodeName(),
+		diskMonitorInformerFactory.DiskMonitor().V2().DiskMonitors(), volumeInformer, hostPathProvisioner.diskMonitorStatus, hostPathProvisioner.createDiskMonitorCR)
+	hostPathProvisioner.reconciler = reconciler
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { reconciler.Enqueue() },
+		UpdateFunc: func(interface{}, interface{}) { reconciler.Enqueue() },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	diskMonitorInformerFactory.Start(ctx.Done())
+	go reconciler.Run(ctx)
+	// Growing or replacing the filesystem of the pool changes its capacity
+	// without touching any watched object
+	poolWatcher := poolwatch.New(hostPathProvisioner.pvDir, poolwatch.DefaultPeriod, hostPathProvisioner.poolChanged)
+	go poolWatcher.Run(ctx.Done())
+	// Walking the volumes is costly, their usage is measured on a slower period
+	// than the DiskMonitor is reconciled on
+	hostPathProvisioner.usage = diskusage.New(diskusage.DefaultPeriod, hostPathProvisioner.volumePaths, hostPathProvisioner.usageChanged)
+	go hostPathProvisioner.usage.Run(ctx.Done())
+	options := []func(*controller.ProvisionController) error{
+		controller.VolumesInformer(volumeInformer),
+		controller.ClassesInformer(classInformer),
+		controller.ClaimsInformer(claimInformer),
+	}
+	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" {
+		port, err := strconv.ParseInt(metricsPort, 10, 32)
+		if err != nil {
+			glog.Fatalf("invalid METRICS_PORT %q: %v", metricsPort, err)
+		}
+		options = append(options, controller.MetricsPort(int32(port)))
 	}
-	go InspectionMonitorDisk(context.TODO(), hostPathProvisioner.GetNodeName(), hostPathProvisioner.GetNamespace(), hostPathProvisioner.GetNodeName())
 	glog.Infof("creating provisioner controller with name: %s\n", provisionerName)
 	// Start the provision controller which will dynamically provision hostPath
 	// PVs
-	pc := controller.NewProvisionController(clientset, provisionerName, hostPathProvisioner, serverVersion.GitVersion)
+	pc := controller.NewProvisionController(clientset, provisionerName, hostPathProvisioner, serverVersion.GitVersion, options...)
+	informerFactory.Start(wait.NeverStop)
 	go rpcNodeInfo.Run()
 	pc.Run(wait.NeverStop)
 }
@@ -17,16 +17,47 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/sys/unix"
 
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	storage "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"kubevirt.io/hostpath-provisioner/controller"
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/diskusage"
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	diskv2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	diskmonitorfake "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned/fake"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/trash"
+	"kubevirt.io/hostpath-provisioner/controller/warmpool"
 )
 
 func getKubevirtNodeAnnotation(value string) map[string]string {
@@ -184,14 +215,634 @@ func Test_isCorrectNodeByBindingMode(t *testing.T) {
 	}
 }
 
+func Test_isReschedulable(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name:        "selected node can be rescheduled",
+			annotations: getSelectedNodeAnnotation("test-node"),
+			want:        true,
+		},
+		{
+			name:        "provisionOnNode can not be rescheduled",
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			want:        false,
+		},
+		{
+			name:        "no node annotation can not be rescheduled",
+			annotations: map[string]string{},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isReschedulable(tt.annotations); got != tt.want {
+				t.Errorf("isReschedulable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ProvisionExt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pvdir")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	testProvisioner := &hostPathProvisioner{
+		pvDir:          dir,
+		nodeName:       "test-node",
+		capacityPolicy: capacity.DefaultPolicy(),
+		ledger:         capacity.NewLedger(),
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        controller.ProvisioningState
+	}{
+		{
+			name:        "reschedules claim with selected node",
+			annotations: getSelectedNodeAnnotation("test-node"),
+			want:        controller.ProvisioningReschedule,
+		},
+		{
+			name:        "fails claim with provisionOnNode",
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			want:        controller.ProvisioningFinished,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", UID: "test-uid", Annotations: tt.annotations},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Ei")},
+					},
+				},
+			}
+			_, state, err := testProvisioner.ProvisionExt(controller.ProvisionOptions{PVName: "pvc-test", PVC: pvc})
+			if err == nil {
+				t.Fatalf("ProvisionExt() should fail")
+			}
+			if state != tt.want {
+				t.Errorf("ProvisionExt() state = %v, want %v", state, tt.want)
+			}
+			if testProvisioner.ledger.Requested() != 0 {
+				t.Errorf("ProvisionExt() should not reserve capacity")
+			}
+		})
+	}
+}
+
+func Test_ProvisionRecordFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pvdir")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// neither the DiskMonitor nor the daemonset to create it with exist
+	testProvisioner := &hostPathProvisioner{
+		client:          fake.NewSimpleClientset(),
+		diskMonitors:    diskmonitorfake.NewSimpleClientset(),
+		pvDir:           dir,
+		nodeName:        "test-node",
+		ownerReferences: "hostpath-provisioner",
+		capacityPolicy:  capacity.DefaultPolicy(),
+		ledger:          capacity.NewLedger(),
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", UID: "test-uid"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Ki")},
+			},
+		},
+	}
+	if _, err := testProvisioner.Provision(controller.ProvisionOptions{PVName: "pvc-test", PVC: pvc}); err == nil {
+		t.Fatalf("Provision() should fail to record the volume")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pvc-test")); !os.IsNotExist(err) {
+		t.Errorf("Provision() should remove the backing directory, stat error = %v", err)
+	}
+}
+
+func Test_handOverWarmVolume(t *testing.T) {
+	tests := []struct {
+		name            string
+		claimUID        types.UID
+		annotations     map[string]string
+		wantMode        os.FileMode
+		wantAnnotations map[string]string
+		wantEvent       string
+	}{
+		{
+			name:            "applies permissions and pool",
+			claimUID:        "claim-uid",
+			annotations:     map[string]string{nodevolumes.AnnPermissions: "0750", nodevolumes.AnnPool: "default"},
+			wantMode:        0750,
+			wantAnnotations: map[string]string{nodevolumes.AnnPool: "default", warmpool.AnnClaimed: "claim-uid"},
+		},
+		{
+			name:            "warns of a pool the node does not have",
+			claimUID:        "claim-uid",
+			annotations:     map[string]string{nodevolumes.AnnPool: "fast"},
+			wantMode:        0777,
+			wantAnnotations: map[string]string{warmpool.AnnClaimed: "claim-uid"},
+			wantEvent:       "PoolMismatch",
+		},
+		{
+			name:            "leaves the volume of a deleted claim",
+			claimUID:        "other-uid",
+			annotations:     map[string]string{nodevolumes.AnnPermissions: "0750"},
+			wantMode:        0777,
+			wantAnnotations: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "pvdir")
+			if err != nil {
+				t.Fatalf("Unable to create temporary directory, error = %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "warm-1")
+			if err := os.Mkdir(path, 0777); err != nil {
+				t.Fatalf("Mkdir() error = %v", err)
+			}
+			// the umask may have cleared some bits
+			if err := os.Chmod(path, 0777); err != nil {
+				t.Fatalf("Chmod() error = %v", err)
+			}
+			pv := &v1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "warm-1",
+					Labels:      map[string]string{warmpool.LabelWarmPool: "true"},
+					Annotations: map[string]string{},
+				},
+				Spec: v1.PersistentVolumeSpec{
+					PersistentVolumeSource: v1.PersistentVolumeSource{HostPath: &v1.HostPathVolumeSource{Path: path}},
+					ClaimRef:               &v1.ObjectReference{Namespace: "test", Name: "test", UID: "claim-uid"},
+				},
+			}
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", UID: tt.claimUID, Annotations: tt.annotations},
+			}
+			recorder := record.NewFakeRecorder(10)
+			testProvisioner := &hostPathProvisioner{
+				client:   fake.NewSimpleClientset(pv, pvc),
+				nodeName: "test-node",
+				pool:     "slow",
+				recorder: recorder,
+			}
+			if err := testProvisioner.handOverWarmVolume(pv); err != nil {
+				t.Fatalf("handOverWarmVolume() error = %v", err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if mode := info.Mode().Perm(); mode != tt.wantMode {
+				t.Errorf("mode = %v, want %v", mode, tt.wantMode)
+			}
+			got, err := testProvisioner.client.CoreV1().PersistentVolumes().Get(context.TODO(), pv.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Annotations, tt.wantAnnotations) {
+				t.Errorf("annotations = %v, want %v", got.Annotations, tt.wantAnnotations)
+			}
+			select {
+			case event := <-recorder.Events:
+				if tt.wantEvent == "" || !strings.Contains(event, tt.wantEvent) {
+					t.Errorf("event = %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected a %s event", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func Test_groupRequested(t *testing.T) {
+	newClaim := func(name, size string, annotations map[string]string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", UID: types.UID(name), Annotations: annotations},
+			Spec: v1.PersistentVolumeClaimSpec{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}
+	}
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	claimInformer := factory.Core().V1().PersistentVolumeClaims().Informer()
+	groups, err := claimgroups.New(claimInformer, factory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		t.Fatalf("claimgroups.New() error = %v", err)
+	}
+	claims := []*v1.PersistentVolumeClaim{
+		newClaim("root", "10Gi", map[string]string{claimgroups.AnnGroup: "vm", "volume.kubernetes.io/selected-node": "test-node"}),
+		newClaim("data", "20Gi", map[string]string{claimgroups.AnnGroup: "vm"}),
+		newClaim("scratch", "5Gi", map[string]string{claimgroups.AnnGroup: "vm"}),
+		newClaim("pinned", "5Gi", map[string]string{claimgroups.AnnGroup: "pinned", "kubevirt.io/provisionOnNode": "other-node"}),
+		newClaim("misplaced", "5Gi", map[string]string{claimgroups.AnnGroup: "pinned", "volume.kubernetes.io/selected-node": "test-node"}),
+	}
+	for _, claim := range claims {
+		claimInformer.GetIndexer().Add(claim)
+	}
+	testProvisioner := &hostPathProvisioner{
+		nodeName: "test-node",
+		groups:   groups,
+		ledger:   capacity.NewLedger(),
+	}
+	testProvisioner.ledger.Reserve("scratch", "test.pvc-scratch", 5*1024*1024*1024, func(int64, int64) (capacity.Capacity, error) {
+		return capacity.Capacity{Allocatable: 100 * 1024 * 1024 * 1024}, nil
+	})
+
+	// scratch is reserved already, root and data remain
+	if got, err := testProvisioner.groupRequested(claims[0]); err != nil || got != 30*1024*1024*1024 {
+		t.Errorf("groupRequested(root) = %d, %v, want 30Gi", got, err)
+	}
+	if _, err := testProvisioner.groupRequested(claims[4]); err == nil {
+		t.Errorf("groupRequested(misplaced) should fail, its group is on another node")
+	}
+}
+
+func Test_ShouldProvisionMaintenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pvdir")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	nodes.Add(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+		Spec:       v1.NodeSpec{Unschedulable: true},
+	})
+	recorder := record.NewFakeRecorder(10)
+	testProvisioner := &hostPathProvisioner{
+		pvDir:          dir,
+		nodeName:       "test-node",
+		capacityPolicy: capacity.DefaultPolicy(),
+		nodes:          corelisters.NewNodeLister(nodes),
+		recorder:       recorder,
+		ledger:         capacity.NewLedger(),
+	}
+	testProvisioner.ledger.Reserve("reserved-uid", "test.pvc-reserved", 1024, func(int64, int64) (capacity.Capacity, error) {
+		return capacity.Capacity{Allocatable: 1024}, nil
+	})
+
+	immediate := storage.VolumeBindingImmediate
+	waitForFirstConsumer := storage.VolumeBindingWaitForFirstConsumer
+	tests := []struct {
+		name        string
+		uid         types.UID
+		annotations map[string]string
+		bindingMode *storage.VolumeBindingMode
+		want        bool
+		wantEvent   bool
+		wantState   controller.ProvisioningState
+	}{
+		{
+			name:        "refuses new claim",
+			uid:         "new-uid",
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			bindingMode: &immediate,
+			want:        false,
+			wantEvent:   true,
+			wantState:   controller.ProvisioningFinished,
+		},
+		{
+			name:        "hands selected claim back to the scheduler",
+			uid:         "new-uid",
+			annotations: getSelectedNodeAnnotation("test-node"),
+			bindingMode: &waitForFirstConsumer,
+			want:        true,
+			wantState:   controller.ProvisioningReschedule,
+		},
+		{
+			name:        "lets reserved claim finish",
+			uid:         "reserved-uid",
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			bindingMode: &immediate,
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", UID: tt.uid, Annotations: tt.annotations},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Ki")},
+					},
+				},
+			}
+			if got := testProvisioner.ShouldProvision(pvc, tt.bindingMode); got != tt.want {
+				t.Errorf("ShouldProvision() = %v, want %v", got, tt.want)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !tt.wantEvent {
+					t.Errorf("unexpected event %q", event)
+				}
+			default:
+				if tt.wantEvent {
+					t.Errorf("expected a NodeInMaintenance event")
+				}
+			}
+			if tt.wantState == "" {
+				return
+			}
+			_, state, err := testProvisioner.ProvisionExt(controller.ProvisionOptions{PVName: "pvc-test", PVC: pvc})
+			if _, ok := err.(*maintenance.Error); !ok {
+				t.Fatalf("ProvisionExt() error = %v, want maintenance error", err)
+			}
+			if state != tt.wantState {
+				t.Errorf("ProvisionExt() state = %v, want %v", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func Test_ShouldProvisionSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pvdir")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	zero := int32(0)
+	immediate := storage.VolumeBindingImmediate
+	waitForFirstConsumer := storage.VolumeBindingWaitForFirstConsumer
+	tests := []struct {
+		name        string
+		spec        diskv2.DiskMonitorSpec
+		annotations map[string]string
+		bindingMode *storage.VolumeBindingMode
+		pool        string
+		want        bool
+		wantEvent   string
+		wantState   controller.ProvisioningState
+	}{
+		{
+			name:        "takes claim of allowed class",
+			spec:        diskv2.DiskMonitorSpec{AllowedStorageClasses: []string{"hpp"}},
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			bindingMode: &immediate,
+			want:        true,
+		},
+		{
+			name:        "refuses claim in maintenance",
+			spec:        diskv2.DiskMonitorSpec{Maintenance: true},
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			bindingMode: &immediate,
+			wantEvent:   "NodeInMaintenance",
+			wantState:   controller.ProvisioningFinished,
+		},
+		{
+			name:        "refuses claim of other class",
+			spec:        diskv2.DiskMonitorSpec{AllowedStorageClasses: []string{"other"}},
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			bindingMode: &immediate,
+			wantEvent:   "StorageClassNotAllowed",
+			wantState:   controller.ProvisioningFinished,
+		},
+		{
+			name:        "hands selected claim of other class back to the scheduler",
+			spec:        diskv2.DiskMonitorSpec{AllowedStorageClasses: []string{"other"}},
+			annotations: getSelectedNodeAnnotation("test-node"),
+			bindingMode: &waitForFirstConsumer,
+			want:        true,
+			wantState:   controller.ProvisioningReschedule,
+		},
+		{
+			name:        "refuses claim of other pool",
+			annotations: map[string]string{"kubevirt.io/provisionOnNode": "test-node", nodevolumes.AnnPool: "fast"},
+			bindingMode: &immediate,
+			wantEvent:   "PoolMismatch",
+			wantState:   controller.ProvisioningFinished,
+		},
+		{
+			name:        "hands selected claim of other pool back to the scheduler",
+			annotations: map[string]string{"volume.kubernetes.io/selected-node": "test-node", nodevolumes.AnnPool: "fast"},
+			bindingMode: &waitForFirstConsumer,
+			want:        true,
+			wantState:   controller.ProvisioningReschedule,
+		},
+		{
+			name:        "takes claim of the default pool on a node of another pool",
+			annotations: map[string]string{"kubevirt.io/provisionOnNode": "test-node", nodevolumes.AnnPool: "default"},
+			bindingMode: &immediate,
+			pool:        "fast",
+			want:        true,
+		},
+		{
+			name:        "takes claim of its pool",
+			annotations: map[string]string{"kubevirt.io/provisionOnNode": "test-node", nodevolumes.AnnPool: "default"},
+			bindingMode: &immediate,
+			want:        true,
+		},
+		{
+			name:        "refuses claim over max volumes",
+			spec:        diskv2.DiskMonitorSpec{MaxVolumes: &zero},
+			annotations: getKubevirtNodeAnnotation("test-node"),
+			bindingMode: &immediate,
+			wantState:   controller.ProvisioningFinished,
+		},
+		{
+			name:        "hands selected claim over max volumes back to the scheduler",
+			spec:        diskv2.DiskMonitorSpec{MaxVolumes: &zero},
+			annotations: getSelectedNodeAnnotation("test-node"),
+			bindingMode: &waitForFirstConsumer,
+			want:        true,
+			wantState:   controller.ProvisioningReschedule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			pool := tt.pool
+			if pool == "" {
+				pool = "default"
+			}
+			testProvisioner := &hostPathProvisioner{
+				pvDir:          dir,
+				nodeName:       "test-node",
+				pool:           pool,
+				capacityPolicy: capacity.DefaultPolicy(),
+				recorder:       recorder,
+				ledger:         capacity.NewLedger(),
+			}
+			if err := testProvisioner.applySpec(&tt.spec); err != nil {
+				t.Fatalf("applySpec() error = %v", err)
+			}
+			class := "hpp"
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test", UID: "test-uid", Annotations: tt.annotations},
+				Spec: v1.PersistentVolumeClaimSpec{
+					StorageClassName: &class,
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Ki")},
+					},
+				},
+			}
+			if got := testProvisioner.ShouldProvision(pvc, tt.bindingMode); got != tt.want {
+				t.Errorf("ShouldProvision() = %v, want %v", got, tt.want)
+			}
+			select {
+			case event := <-recorder.Events:
+				if tt.wantEvent == "" || !strings.Contains(event, tt.wantEvent) {
+					t.Errorf("event = %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected a %s event", tt.wantEvent)
+				}
+			}
+			if tt.wantState == "" {
+				return
+			}
+			_, state, err := testProvisioner.ProvisionExt(controller.ProvisionOptions{PVName: "pvc-test", PVC: pvc})
+			if err == nil {
+				t.Fatalf("ProvisionExt() should fail")
+			}
+			if state != tt.wantState {
+				t.Errorf("ProvisionExt() state = %v, want %v", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func Test_applySpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pvdir")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	testProvisioner := &hostPathProvisioner{
+		pvDir:          dir,
+		nodeName:       "test-node",
+		capacityPolicy: capacity.DefaultPolicy(),
+		ledger:         capacity.NewLedger(),
+	}
+	before, err := testProvisioner.currentCapacity()
+	if err != nil {
+		t.Fatalf("currentCapacity() error = %v", err)
+	}
+	headroom := resource.NewQuantity(before.Allocatable/2, resource.BinarySI)
+	if err := testProvisioner.applySpec(&diskv2.DiskMonitorSpec{ReservedHeadroom: headroom}); err != nil {
+		t.Fatalf("applySpec() error = %v", err)
+	}
+	after, err := testProvisioner.currentCapacity()
+	if err != nil {
+		t.Fatalf("currentCapacity() error = %v", err)
+	}
+	// the free space of the filesystem may change a little in between
+	if diff := before.Allocatable - headroom.Value() - after.Allocatable; diff > 10*MiB || diff < -10*MiB {
+		t.Errorf("allocatable with headroom = %d, want about %d", after.Allocatable, before.Allocatable-headroom.Value())
+	}
+
+	negative := resource.MustParse("-1Gi")
+	if err := testProvisioner.applySpec(&diskv2.DiskMonitorSpec{ReservedHeadroom: &negative}); err == nil {
+		t.Errorf("applySpec() of an invalid spec should fail")
+	}
+	if testProvisioner.currentSpec().ReservedHeadroom.Cmp(*headroom) != 0 {
+		t.Errorf("an invalid spec should leave the previous one applied")
+	}
+	huge := resource.MustParse("1Ei")
+	if err := testProvisioner.applySpec(&diskv2.DiskMonitorSpec{ReservedHeadroom: &huge}); err != nil {
+		t.Fatalf("applySpec() error = %v", err)
+	}
+	if c, err := testProvisioner.currentCapacity(); err != nil || c.Allocatable != 0 {
+		t.Errorf("allocatable with a headroom larger than the pool = %d, %v, want 0", c.Allocatable, err)
+	}
+}
+
+func Test_collectGarbage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pvdir")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	testProvisioner := &hostPathProvisioner{
+		pvDir:    dir,
+		nodeName: "test-node",
+		ledger:   capacity.NewLedger(),
+		trash:    trash.New(dir),
+	}
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"pvc-volume", "pvc-orphan", "pvc-deleted"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0777); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "pvc-recent"), 0777); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	volumes := []diskv2.Volume{{Path: filepath.Join(dir, "pvc-volume")}}
+
+	// without retention the orphans are only reported
+	testProvisioner.collectGarbage(volumes)
+	if _, err := os.Stat(filepath.Join(dir, "pvc-orphan")); err != nil {
+		t.Errorf("orphan removed without gc retention: %v", err)
+	}
+
+	err = testProvisioner.applySpec(&diskv2.DiskMonitorSpec{
+		GCRetention:    &metav1.Duration{Duration: time.Minute},
+		TrashRetention: &metav1.Duration{Duration: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("applySpec() error = %v", err)
+	}
+	if err := testProvisioner.removeDirectory(filepath.Join(dir, "pvc-deleted")); err != nil {
+		t.Fatalf("removeDirectory() error = %v", err)
+	}
+	testProvisioner.collectGarbage(volumes)
+	orphans, err := findOrphans(dir, volumes, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("findOrphans() error = %v", err)
+	}
+	if want := []string{filepath.Join(dir, "pvc-recent")}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("directories left = %v, want %v", orphans, want)
+	}
+	trashed, err := ioutil.ReadDir(filepath.Join(dir, trash.Directory))
+	if err != nil || len(trashed) != 2 {
+		t.Errorf("trash holds %d entries, %v, want 2", len(trashed), err)
+	}
+
+	// without trash retention the trash is emptied
+	if err := testProvisioner.applySpec(&diskv2.DiskMonitorSpec{}); err != nil {
+		t.Fatalf("applySpec() error = %v", err)
+	}
+	testProvisioner.collectGarbage(volumes)
+	if trashed, err := ioutil.ReadDir(filepath.Join(dir, trash.Directory)); err != nil || len(trashed) != 0 {
+		t.Errorf("trash holds %d entries, %v, want 0", len(trashed), err)
+	}
+}
+
 func Test_Delete(t *testing.T) {
 	type args struct {
 		identity string
 		nodeName string
 	}
+	diskMonitors := diskmonitorfake.NewSimpleClientset(&diskv2.DiskMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "testNode"},
+	})
 	testProvisioner := &hostPathProvisioner{
-		nodeName: "testNode",
-		identity: "testId",
+		diskMonitors: diskMonitors,
+		nodeName:     "testNode",
+		identity:     "testId",
+		ledger:       capacity.NewLedger(),
 	}
 
 	tests := []struct {
@@ -231,6 +882,11 @@ func Test_Delete(t *testing.T) {
 		}
 		defer os.Remove(file.Name())
 		pv := createPv(tt.args.identity, tt.args.nodeName, file.Name())
+		if !tt.wantErr {
+			if err := testProvisioner.updateDiskRecords(diskRecordArgs(monitor_disk.OPERATE_UPDATE, file.Name(), "2Gi")); err != nil {
+				t.Errorf("Unable to record the volume, error = %v", err)
+			}
+		}
 		t.Run(tt.name, func(t *testing.T) {
 			err := testProvisioner.Delete(pv)
 			if (err != nil) != tt.wantErr || (err == nil) == tt.wantErr {
@@ -239,6 +895,287 @@ func Test_Delete(t *testing.T) {
 			}
 		})
 	}
+	monitor, err := diskMonitors.DiskMonitorV2().DiskMonitors("").Get(context.TODO(), "testNode", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get DiskMonitor, error = %v", err)
+	}
+	if !monitor.Status.Required.IsZero() {
+		t.Errorf("Delete, required = %s, want 0", monitor.Status.Required.String())
+	}
+}
+
+// conflictingDiskMonitors returns a fake DiskMonitor client that, like the API
+// server, refuses updates of DiskMonitors changed since they were read.
+func conflictingDiskMonitors(objects ...runtime.Object) *diskmonitorfake.Clientset {
+	client := diskmonitorfake.NewSimpleClientset(objects...)
+	gvr := diskv2.SchemeGroupVersion.WithResource("diskmonitors")
+	client.PrependReactor("update", "diskmonitors", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		monitor := action.(clienttesting.UpdateAction).GetObject().(*diskv2.DiskMonitor).DeepCopy()
+		stored, err := client.Tracker().Get(gvr, monitor.Namespace, monitor.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		if stored.(*diskv2.DiskMonitor).ResourceVersion != monitor.ResourceVersion {
+			return true, nil, apierrors.NewConflict(gvr.GroupResource(), monitor.Name, errors.New("the object has been modified"))
+		}
+		version, _ := strconv.Atoi(monitor.ResourceVersion)
+		monitor.ResourceVersion = strconv.Itoa(version + 1)
+		return true, monitor, client.Tracker().Update(gvr, monitor, monitor.Namespace)
+	})
+	return client
+}
+
+func diskRecordArgs(operation, path, size string) *monitor_disk.ModifyDiskArgs {
+	return &monitor_disk.ModifyDiskArgs{
+		CRName:    "testNode",
+		Path:      path,
+		Operation: operation,
+		Volume: &diskv2.Volume{
+			PersistentVolume: path,
+			Path:             path,
+			Requested:        resource.MustParse(size),
+		},
+	}
+}
+
+func Test_updateDiskRecordsConcurrent(t *testing.T) {
+	const workers = 10
+	required := resource.MustParse("10Gi")
+	monitor := &diskv2.DiskMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "testNode", ResourceVersion: "1"},
+		Status: diskv2.DiskMonitorStatus{
+			Required: &required,
+		},
+	}
+	for i := 0; i < workers; i++ {
+		path := fmt.Sprintf("/pv/old-%d", i)
+		monitor.Status.Volumes = append(monitor.Status.Volumes, *diskRecordArgs(monitor_disk.OPERATE_UPDATE, path, "1Gi").Volume)
+	}
+	diskMonitors := conflictingDiskMonitors(monitor)
+	testProvisioner := &hostPathProvisioner{
+		diskMonitors: diskMonitors,
+		nodeName:     "testNode",
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- testProvisioner.updateDiskRecords(diskRecordArgs(monitor_disk.OPERATE_UPDATE, fmt.Sprintf("/pv/new-%d", i), "2Gi"))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			errs <- testProvisioner.updateDiskRecords(diskRecordArgs(monitor_disk.OPERATE_DELETE, fmt.Sprintf("/pv/old-%d", i), "1Gi"))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("updateDiskRecords, error = %v", err)
+		}
+	}
+
+	got, err := diskMonitors.DiskMonitorV2().DiskMonitors("").Get(context.TODO(), "testNode", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get DiskMonitor, error = %v", err)
+	}
+	if want := resource.MustParse("20Gi"); got.Status.Required.Cmp(want) != 0 {
+		t.Errorf("required = %s, want %s", got.Status.Required.String(), want.String())
+	}
+	var paths []string
+	for _, volume := range got.Status.Volumes {
+		paths = append(paths, volume.Path)
+	}
+	var wantPaths []string
+	for i := 0; i < workers; i++ {
+		wantPaths = append(wantPaths, fmt.Sprintf("/pv/new-%d", i))
+	}
+	sort.Strings(wantPaths)
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("volume paths = %v, want %v", paths, wantPaths)
+	}
+}
+
+func Test_updateDiskRecordsMissingMonitor(t *testing.T) {
+	diskMonitors := conflictingDiskMonitors()
+	volumeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().PersistentVolumes().Informer()
+	volumes, err := nodevolumes.New(volumeInformer)
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	testProvisioner := &hostPathProvisioner{
+		client: fake.NewSimpleClientset(&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "hostpath-provisioner"},
+		}),
+		diskMonitors:    diskMonitors,
+		volumes:         volumes,
+		nodeName:        "testNode",
+		ownerReferences: "hostpath-provisioner",
+	}
+
+	// both workers find no DiskMonitor, one of them creates it
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- testProvisioner.updateDiskRecords(diskRecordArgs(monitor_disk.OPERATE_UPDATE, fmt.Sprintf("/pv/new-%d", i), "3Gi"))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("updateDiskRecords, error = %v", err)
+		}
+	}
+	got, err := diskMonitors.DiskMonitorV2().DiskMonitors("").Get(context.TODO(), "testNode", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get DiskMonitor, error = %v", err)
+	}
+	if want := resource.MustParse("6Gi"); got.Status.Required.Cmp(want) != 0 {
+		t.Errorf("required = %s, want %s", got.Status.Required.String(), want.String())
+	}
+	if len(got.Status.Volumes) != 2 {
+		t.Errorf("volumes = %v, want 2 paths", got.Status.Volumes)
+	}
+}
+
+func Test_nodePVs(t *testing.T) {
+	volumeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().PersistentVolumes().Informer()
+	volumes, err := nodevolumes.New(volumeInformer)
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	for _, pv := range []struct {
+		name, identity, node, class string
+	}{
+		{name: "default-class", identity: "testId", node: "testNode", class: StorageClassName},
+		{name: "other-class", identity: "testId", node: "testNode", class: "fast"},
+		{name: "other-provisioner", identity: "otherId", node: "testNode", class: "fast"},
+		{name: "other-node", identity: "testId", node: "otherNode", class: StorageClassName},
+	} {
+		volume := createPv(pv.identity, pv.node, "/tmp/"+pv.name)
+		volume.Name = pv.name
+		volume.Spec.StorageClassName = pv.class
+		volumeInformer.GetIndexer().Add(volume)
+	}
+	testProvisioner := &hostPathProvisioner{volumes: volumes, nodeName: "testNode", identity: "testId"}
+
+	var got []string
+	for _, pv := range testProvisioner.nodePVs() {
+		got = append(got, pv.Name)
+	}
+	sort.Strings(got)
+	if want := []string{"default-class", "other-class"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("nodePVs() = %v, want %v", got, want)
+	}
+}
+
+func Test_diskVolumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volumes")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 64*KiB), 0644); err != nil {
+		t.Fatalf("Unable to write data, error = %v", err)
+	}
+	bound := createPv("testId", "testNode", dir)
+	bound.Name = "bound"
+	bound.Annotations[nodevolumes.AnnPool] = "fast"
+	bound.Spec.ClaimRef = &v1.ObjectReference{Namespace: "default", Name: "claim"}
+	bound.Status.Phase = v1.VolumeBound
+	missing := createPv("testId", "testNode", filepath.Join(dir, "missing"))
+	missing.Name = "missing"
+	missing.Status.Phase = v1.VolumeAvailable
+	testProvisioner := &hostPathProvisioner{pool: "default"}
+	testProvisioner.usage = diskusage.New(diskusage.DefaultPeriod, func() []string { return []string{dir, filepath.Join(dir, "missing")} }, nil)
+	testProvisioner.usage.Refresh()
+
+	required, volumes := testProvisioner.diskVolumes([]*v1.PersistentVolume{missing, bound})
+	if want := resource.MustParse("4Gi"); required.Cmp(want) != 0 {
+		t.Errorf("required = %s, want %s", required.String(), want.String())
+	}
+	if len(volumes) != 2 {
+		t.Fatalf("volumes = %v, want 2", volumes)
+	}
+	got := volumes[0]
+	if got.PersistentVolume != "bound" || got.Pool != "fast" || got.Phase != diskv2.VolumeBound ||
+		!reflect.DeepEqual(got.Claim, &diskv2.ClaimReference{Namespace: "default", Name: "claim"}) {
+		t.Errorf("volumes[0] = %+v, want the bound volume", got)
+	}
+	if got.Used == nil || got.Used.Value() < 64*KiB {
+		t.Errorf("volumes[0] used = %v, want at least 64Ki", got.Used)
+	}
+	got = volumes[1]
+	if got.PersistentVolume != "missing" || got.Pool != "default" || got.Phase != diskv2.VolumeAvailable || got.Claim != nil || got.Used != nil {
+		t.Errorf("volumes[1] = %+v, want the available volume without usage", got)
+	}
+}
+
+func Test_findOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orphans")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"volume", "orphan", ".warm", "recent"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0777); err != nil {
+			t.Fatalf("Unable to create %s, error = %v", name, err)
+		}
+		if name != "recent" {
+			os.Chtimes(filepath.Join(dir, name), old, old)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatalf("Unable to create file, error = %v", err)
+	}
+	volumes := []diskv2.Volume{{PersistentVolume: "volume", Path: filepath.Join(dir, "volume") + "/"}}
+
+	orphans, err := findOrphans(dir, volumes, time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		t.Fatalf("findOrphans() error = %v", err)
+	}
+	if want := []string{filepath.Join(dir, "orphan")}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("findOrphans() = %v, want %v", orphans, want)
+	}
+	if _, err := findOrphans(filepath.Join(dir, "missing"), volumes, time.Now()); err == nil {
+		t.Errorf("findOrphans() of a missing pool should fail")
+	}
+}
+
+func Test_provisioningVolumes(t *testing.T) {
+	testProvisioner := &hostPathProvisioner{ledger: capacity.NewLedger()}
+	fits := func(requested, inFlight int64) (capacity.Capacity, error) {
+		return capacity.Capacity{Total: 100 * GiB, Allocatable: 100*GiB - requested}, nil
+	}
+	for _, name := range []string{"stored", "inflight"} {
+		if err := testProvisioner.ledger.Reserve(name, name, GiB, fits); err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	stored := createPv("testId", "testNode", "/pv/stored")
+	stored.Name = "stored"
+	recorded := []diskv2.Volume{
+		{PersistentVolume: "stored", Path: "/pv/stored", Phase: diskv2.VolumeProvisioning},
+		{PersistentVolume: "inflight", Path: "/pv/inflight", Phase: diskv2.VolumeProvisioning},
+		{PersistentVolume: "released", Path: "/pv/released", Phase: diskv2.VolumeProvisioning},
+		{PersistentVolume: "bound", Path: "/pv/bound", Phase: diskv2.VolumeBound},
+	}
+
+	var got []string
+	for _, volume := range testProvisioner.provisioningVolumes(recorded, []*v1.PersistentVolume{stored}) {
+		got = append(got, volume.PersistentVolume)
+	}
+	if want := []string{"inflight"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("provisioningVolumes() = %v, want %v", got, want)
+	}
 }
 
 func Test_calculatePvCapacity(t *testing.T) {
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller"
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/extender"
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const (
+	defaultProvisionerName = "kubevirt.io/hostpath-provisioner"
+	defaultPort            = "8888"
+)
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func main() {
+	glog.InitFlags(nil)
+	flag.Parse()
+	flag.Set("logtostderr", "true")
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		glog.Fatalf("Failed to create config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create client: %v", err)
+	}
+	diskMonitorClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create DiskMonitor client: %v", err)
+	}
+
+	// DiskMonitors live in the namespace of the provisioner daemonset, the
+	// same capacity policy as on the nodes is applied to agents that do not
+	// report their allocatable capacity.
+	namespace := os.Getenv("NAMESPACE")
+	policy, err := capacity.ParsePolicy(os.Getenv("CAPACITY_MODE"), os.Getenv("CAPACITY_MIN_FREE"), os.Getenv("CAPACITY_OVERCOMMIT_RATIO"))
+	if err != nil {
+		glog.Fatalf("Invalid capacity policy: %v", err)
+	}
+	// MAINTENANCE_TAINTS and MAINTENANCE_LABEL_SELECTOR must match the provisioner
+	// daemonset, so that pods are not scheduled to nodes that would refuse their claims
+	maintenanceConfig, err := maintenance.ParseConfig(os.Getenv("MAINTENANCE_TAINTS"), os.Getenv("MAINTENANCE_LABEL_SELECTOR"))
+	if err != nil {
+		glog.Fatalf("Invalid maintenance configuration: %v", err)
+	}
+
+	informerFactory := informers.NewSharedInformerFactory(clientset, controller.DefaultResyncPeriod)
+	claimInformer := informerFactory.Core().V1().PersistentVolumeClaims()
+	classInformer := informerFactory.Storage().V1().StorageClasses()
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	volumes, err := nodevolumes.New(informerFactory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		glog.Fatalf("Failed to index volumes: %v", err)
+	}
+	groups, err := claimgroups.New(claimInformer.Informer(), volumes.Informer())
+	if err != nil {
+		glog.Fatalf("Failed to index claim groups: %v", err)
+	}
+	diskMonitorInformerFactory := diskmonitorinformers.NewSharedInformerFactoryWithOptions(diskMonitorClient, controller.DefaultResyncPeriod,
+		diskmonitorinformers.WithNamespace(namespace))
+	diskMonitorInformer := diskMonitorInformerFactory.DiskMonitor().V2().DiskMonitors()
+
+	e, err := extender.New(getEnv("PROVISIONER_NAME", defaultProvisionerName), extender.Strategy(os.Getenv("STRATEGY")),
+		claimInformer.Lister(), groups, classInformer.Lister(), nodeInformer.Lister(), volumes,
+		monitor_disk.NodeCapacity(diskMonitorInformer.Lister(), namespace, policy),
+		monitor_disk.NodeAdmission(diskMonitorInformer.Lister(), namespace, maintenanceConfig))
+	if err != nil {
+		glog.Fatalf("Failed to create extender: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+	diskMonitorInformerFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, claimInformer.Informer().HasSynced, classInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced,
+		volumes.HasSynced, diskMonitorInformer.Informer().HasSynced) {
+		glog.Fatalf("Failed to sync informers")
+	}
+
+	port := getEnv("PORT", defaultPort)
+	glog.Infof("scheduler extender listening on port %s", port)
+	glog.Fatal(http.ListenAndServe(":"+port, e.Handler()))
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller"
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/webhook"
+)
+
+const (
+	defaultProvisionerName = "kubevirt.io/hostpath-provisioner"
+	defaultPort            = "8443"
+	defaultCertFile        = "/etc/webhook/certs/tls.crt"
+	defaultKeyFile         = "/etc/webhook/certs/tls.key"
+)
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func main() {
+	glog.InitFlags(nil)
+	flag.Parse()
+	flag.Set("logtostderr", "true")
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		glog.Fatalf("Failed to create config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create client: %v", err)
+	}
+	diskMonitorClient, err := versioned.NewForConfig(config)
+	if err != nil {
+		glog.Fatalf("Failed to create DiskMonitor client: %v", err)
+	}
+
+	// DiskMonitors live in the namespace of the provisioner daemonset, the
+	// same capacity policy as on the nodes is applied to agents that do not
+	// report their allocatable capacity.
+	namespace := os.Getenv("NAMESPACE")
+	provisionerName := getEnv("PROVISIONER_NAME", defaultProvisionerName)
+	policy, err := capacity.ParsePolicy(os.Getenv("CAPACITY_MODE"), os.Getenv("CAPACITY_MIN_FREE"), os.Getenv("CAPACITY_OVERCOMMIT_RATIO"))
+	if err != nil {
+		glog.Fatalf("Invalid capacity policy: %v", err)
+	}
+
+	informerFactory := informers.NewSharedInformerFactory(clientset, controller.DefaultResyncPeriod)
+	claimInformer := informerFactory.Core().V1().PersistentVolumeClaims()
+	classInformer := informerFactory.Storage().V1().StorageClasses()
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	volumes, err := nodevolumes.New(informerFactory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		glog.Fatalf("Failed to index persistent volumes: %v", err)
+	}
+	groups, err := claimgroups.New(claimInformer.Informer(), volumes.Informer())
+	if err != nil {
+		glog.Fatalf("Failed to index claim groups: %v", err)
+	}
+	diskMonitorInformerFactory := diskmonitorinformers.NewSharedInformerFactoryWithOptions(diskMonitorClient, controller.DefaultResyncPeriod,
+		diskmonitorinformers.WithNamespace(namespace))
+	diskMonitorInformer := diskMonitorInformerFactory.DiskMonitor().V2().DiskMonitors()
+
+	capacities := monitor_disk.NodeCapacity(diskMonitorInformer.Lister(), namespace, policy)
+	mutator := webhook.NewMutator(provisionerName, classInformer.Lister(), namespaceInformer.Lister(), groups)
+	validator := webhook.NewValidator(provisionerName, classInformer.Lister(), nodeInformer.Lister(), groups, capacities)
+
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+	diskMonitorInformerFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, claimInformer.Informer().HasSynced, classInformer.Informer().HasSynced, namespaceInformer.Informer().HasSynced,
+		nodeInformer.Informer().HasSynced, volumes.HasSynced, diskMonitorInformer.Informer().HasSynced) {
+		glog.Fatalf("Failed to sync informers")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/mutate", mutator.Handler())
+	mux.Handle("/validate", validator.Handler())
+	port := getEnv("PORT", defaultPort)
+	glog.Infof("webhook listening on port %s", port)
+	glog.Fatal(http.ListenAndServeTLS(":"+port, getEnv("TLS_CERT_FILE", defaultCertFile), getEnv("TLS_KEY_FILE", defaultKeyFile), mux))
+}
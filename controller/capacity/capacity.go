@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capacity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Mode selects what the sum of PV requests on a pool is compared against.
+type Mode string
+
+const (
+	// ModeRequestsVsTotal compares the sum of PV requests with the size of the
+	// filesystem. This is how the provisioner has always behaved.
+	ModeRequestsVsTotal Mode = "RequestsVsTotal"
+	// ModeRequestsVsAvailable compares the sum of PV requests with the part of
+	// the filesystem usable by unprivileged writers, i.e. without the blocks
+	// reserved for root.
+	ModeRequestsVsAvailable Mode = "RequestsVsAvailable"
+	// ModeActualFree ignores PV requests and only looks at the space that is
+	// actually free on the filesystem right now, less the volumes being
+	// provisioned that do not show in it yet.
+	ModeActualFree Mode = "ActualFree"
+)
+
+// DefaultMode is used when no mode is configured.
+const DefaultMode = ModeRequestsVsTotal
+
+// Stats is the raw usage of the filesystem backing a pool, in bytes.
+type Stats struct {
+	// Total is the size of the filesystem.
+	Total int64
+	// Free is the free space, including blocks reserved for root.
+	Free int64
+	// Available is the free space usable by unprivileged writers.
+	Available int64
+}
+
+// Statfs returns the Stats of the filesystem that path lives on.
+func Statfs(path string) (Stats, error) {
+	statfs := &unix.Statfs_t{}
+	if err := unix.Statfs(path, statfs); err != nil {
+		return Stats{}, err
+	}
+	return Stats{
+		Total:     int64(statfs.Blocks) * statfs.Bsize,
+		Free:      int64(statfs.Bfree) * statfs.Bsize,
+		Available: int64(statfs.Bavail) * statfs.Bsize,
+	}, nil
+}
+
+// Policy describes how much of a pool may be handed out to new volumes.
+type Policy struct {
+	Mode Mode
+	// MinFreeBytes is an absolute amount of headroom that is never handed out.
+	MinFreeBytes int64
+	// MinFreePercent is headroom expressed as a percentage of the filesystem
+	// size. When both MinFreeBytes and MinFreePercent are set the larger
+	// headroom wins.
+	MinFreePercent float64
+	// OvercommitRatio scales the budget of the request based modes, allowing
+	// thin use of the pool. 1 means no overcommit.
+	OvercommitRatio float64
+}
+
+// DefaultPolicy returns the policy matching the historical behaviour: requests
+// against the filesystem size, no headroom and no overcommit.
+func DefaultPolicy() Policy {
+	return Policy{
+		Mode:            DefaultMode,
+		OvercommitRatio: 1,
+	}
+}
+
+// ParsePolicy builds a Policy from its string form. Empty values keep the
+// defaults. minFree is either a quantity ("10Gi") or a percentage ("5%").
+func ParsePolicy(mode, minFree, overcommitRatio string) (Policy, error) {
+	policy := DefaultPolicy()
+	if mode != "" {
+		switch m := Mode(mode); m {
+		case ModeRequestsVsTotal, ModeRequestsVsAvailable, ModeActualFree:
+			policy.Mode = m
+		default:
+			return policy, fmt.Errorf("unknown capacity mode %q", mode)
+		}
+	}
+	if minFree != "" {
+		if strings.HasSuffix(minFree, "%") {
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(minFree, "%"), 64)
+			if err != nil || percent < 0 || percent >= 100 {
+				return policy, fmt.Errorf("invalid minimum free percentage %q", minFree)
+			}
+			policy.MinFreePercent = percent
+		} else {
+			quantity, err := resource.ParseQuantity(minFree)
+			if err != nil || quantity.Sign() < 0 {
+				return policy, fmt.Errorf("invalid minimum free size %q", minFree)
+			}
+			policy.MinFreeBytes = quantity.Value()
+		}
+	}
+	if overcommitRatio != "" {
+		ratio, err := strconv.ParseFloat(overcommitRatio, 64)
+		if err != nil || ratio <= 0 {
+			return policy, fmt.Errorf("invalid overcommit ratio %q", overcommitRatio)
+		}
+		policy.OvercommitRatio = ratio
+	}
+	return policy, nil
+}
+
+// Capacity is the outcome of applying a Policy to a pool, in bytes.
+type Capacity struct {
+	// Total is the size of the filesystem.
+	Total int64
+	// Available is the space usable by unprivileged writers right now.
+	Available int64
+	// Requested is the sum of the requests of the volumes on the pool.
+	Requested int64
+	// Headroom is the space the policy keeps free.
+	Headroom int64
+	// Allocatable is what can still be handed out to new volumes. It is never
+	// negative.
+	Allocatable int64
+}
+
+// Headroom returns the amount of space the policy keeps free on a filesystem
+// of the given size.
+func (p Policy) Headroom(total int64) int64 {
+	headroom := p.MinFreeBytes
+	if percent := int64(float64(total) * p.MinFreePercent / 100); percent > headroom {
+		headroom = percent
+	}
+	return headroom
+}
+
+// Compute applies the policy to the filesystem stats and the sum of the
+// requests already placed on the pool. inFlight is the part of requested that
+// is reserved for volumes being provisioned, which ModeActualFree takes off
+// the free space.
+func (p Policy) Compute(stats Stats, requested, inFlight int64) Capacity {
+	ratio := p.OvercommitRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	c := Capacity{
+		Total:     stats.Total,
+		Available: stats.Available,
+		Requested: requested,
+		Headroom:  p.Headroom(stats.Total),
+	}
+	switch p.Mode {
+	case ModeActualFree:
+		c.Allocatable = stats.Available - inFlight - c.Headroom
+	case ModeRequestsVsAvailable:
+		rootReserved := stats.Free - stats.Available
+		c.Allocatable = int64(float64(stats.Total-rootReserved)*ratio) - requested - c.Headroom
+	default:
+		c.Allocatable = int64(float64(stats.Total)*ratio) - requested - c.Headroom
+	}
+	if c.Allocatable < 0 {
+		c.Allocatable = 0
+	}
+	return c
+}
+
+// Fits returns whether a volume of the given size can be placed on the pool.
+func (c Capacity) Fits(size int64) bool {
+	return size <= c.Allocatable
+}
+
+// NodeCapacityFunc returns the capacity of the pool of a node, and false when
+// the node does not report one.
+type NodeCapacityFunc func(nodeName string) (Capacity, bool)
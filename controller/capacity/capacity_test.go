@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capacity
+
+import (
+	"testing"
+)
+
+const (
+	MiB int64 = 1024 * 1024
+	GiB int64 = 1024 * MiB
+)
+
+func Test_ParsePolicy(t *testing.T) {
+	type args struct {
+		mode, minFree, ratio string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    Policy
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			args: args{},
+			want: DefaultPolicy(),
+		},
+		{
+			name: "absolute headroom",
+			args: args{mode: "ActualFree", minFree: "10Gi"},
+			want: Policy{Mode: ModeActualFree, MinFreeBytes: 10 * GiB, OvercommitRatio: 1},
+		},
+		{
+			name: "percent headroom and overcommit",
+			args: args{mode: "RequestsVsAvailable", minFree: "5%", ratio: "1.5"},
+			want: Policy{Mode: ModeRequestsVsAvailable, MinFreePercent: 5, OvercommitRatio: 1.5},
+		},
+		{
+			name:    "unknown mode",
+			args:    args{mode: "Whatever"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid percentage",
+			args:    args{minFree: "120%"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ratio",
+			args:    args{ratio: "0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.args.mode, tt.args.minFree, tt.args.ratio)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParsePolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Compute(t *testing.T) {
+	// 100GiB filesystem, 5GiB reserved for root, 30GiB written.
+	stats := Stats{
+		Total:     100 * GiB,
+		Free:      70 * GiB,
+		Available: 65 * GiB,
+	}
+	tests := []struct {
+		name      string
+		policy    Policy
+		requested int64
+		inFlight  int64
+		want      int64
+	}{
+		{
+			name:      "requests vs total",
+			policy:    DefaultPolicy(),
+			requested: 40 * GiB,
+			want:      60 * GiB,
+		},
+		{
+			name:      "requests vs available excludes root reserved blocks",
+			policy:    Policy{Mode: ModeRequestsVsAvailable, OvercommitRatio: 1},
+			requested: 40 * GiB,
+			want:      55 * GiB,
+		},
+		{
+			name:      "actual free ignores requests",
+			policy:    Policy{Mode: ModeActualFree, OvercommitRatio: 1},
+			requested: 90 * GiB,
+			want:      65 * GiB,
+		},
+		{
+			name:      "actual free counts the volumes in flight",
+			policy:    Policy{Mode: ModeActualFree, OvercommitRatio: 1},
+			requested: 90 * GiB,
+			inFlight:  20 * GiB,
+			want:      45 * GiB,
+		},
+		{
+			name:      "requests already count the volumes in flight",
+			policy:    DefaultPolicy(),
+			requested: 40 * GiB,
+			inFlight:  20 * GiB,
+			want:      60 * GiB,
+		},
+		{
+			name:      "percent headroom",
+			policy:    Policy{Mode: ModeRequestsVsTotal, MinFreePercent: 10, OvercommitRatio: 1},
+			requested: 40 * GiB,
+			want:      50 * GiB,
+		},
+		{
+			name:      "larger of absolute and percent headroom wins",
+			policy:    Policy{Mode: ModeRequestsVsTotal, MinFreeBytes: 20 * GiB, MinFreePercent: 10, OvercommitRatio: 1},
+			requested: 40 * GiB,
+			want:      40 * GiB,
+		},
+		{
+			name:      "overcommit",
+			policy:    Policy{Mode: ModeRequestsVsTotal, OvercommitRatio: 2},
+			requested: 150 * GiB,
+			want:      50 * GiB,
+		},
+		{
+			name:      "never negative",
+			policy:    DefaultPolicy(),
+			requested: 200 * GiB,
+			want:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Compute(stats, tt.requested, tt.inFlight)
+			if got.Allocatable != tt.want {
+				t.Errorf("Compute().Allocatable = %d, want %d", got.Allocatable, tt.want)
+			}
+		})
+	}
+}
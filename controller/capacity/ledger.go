@@ -0,0 +1,294 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capacity
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultConfirmGrace is how long a confirmed volume is kept by Rebuild while
+// it does not show up in the PV list yet.
+const DefaultConfirmGrace = 2 * time.Minute
+
+// DefaultGroupHold is how long ReserveGroup holds the reservations of the
+// other members of a group for them to be provisioned.
+const DefaultGroupHold = 10 * time.Minute
+
+// InsufficientCapacityError is returned by Reserve when the pool cannot hold a
+// claim.
+type InsufficientCapacityError struct {
+	Requested   int64
+	Allocatable int64
+}
+
+func (e *InsufficientCapacityError) Error() string {
+	return fmt.Sprintf("insufficient capacity: requested %s, allocatable %s",
+		resource.NewQuantity(e.Requested, resource.BinarySI).String(),
+		resource.NewQuantity(e.Allocatable, resource.BinarySI).String())
+}
+
+// TooManyVolumesError is returned by Reserve when the pool holds as many
+// volumes as it may.
+type TooManyVolumesError struct {
+	Max int
+}
+
+func (e *TooManyVolumesError) Error() string {
+	return fmt.Sprintf("too many volumes: the pool holds at most %d", e.Max)
+}
+
+// ComputeFunc returns the capacity of a pool given the sum of the requests
+// placed on it and the part of them reserved for volumes being provisioned.
+type ComputeFunc func(requested, inFlight int64) (Capacity, error)
+
+type reservation struct {
+	pvName string
+	size   int64
+	// expires is when a reservation held for a member of a group is dropped,
+	// zero once the claim is being provisioned itself
+	expires time.Time
+}
+
+// Request is a claim to reserve capacity for.
+type Request struct {
+	ClaimUID string
+	PVName   string
+	Size     int64
+}
+
+type volume struct {
+	size        int64
+	confirmedAt time.Time
+}
+
+// Ledger keeps the requests placed on a node's pool: the stored PVs and the
+// claims that are being provisioned and whose PV is not stored yet. Reserving
+// through the ledger is atomic, so concurrent provisioning workers can not
+// admit claims against the same free space.
+//
+// A reservation is taken when provisioning of a claim starts, confirmed once
+// its PV is stored and released when provisioning fails or is given up.
+type Ledger struct {
+	mu           sync.Mutex
+	volumes      map[string]volume
+	reservations map[string]reservation
+	confirmGrace time.Duration
+	// maxVolumes limits the stored volumes and reservations, negative for no limit
+	maxVolumes int
+	now        func() time.Time
+}
+
+// NewLedger returns an empty ledger.
+func NewLedger() *Ledger {
+	return &Ledger{
+		volumes:      map[string]volume{},
+		reservations: map[string]reservation{},
+		confirmGrace: DefaultConfirmGrace,
+		maxVolumes:   -1,
+		now:          time.Now,
+	}
+}
+
+// Rebuild replaces the stored volumes with the given PV sizes keyed by PV
+// name. In flight reservations are kept, as are volumes confirmed recently
+// enough that the PV list may not contain them yet. Expired reservations held
+// for members of a group are dropped.
+func (l *Ledger) Rebuild(volumes map[string]int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rebuilt := make(map[string]volume, len(volumes))
+	for name, size := range volumes {
+		rebuilt[name] = volume{size: size}
+	}
+	for name, v := range l.volumes {
+		if _, ok := rebuilt[name]; !ok && !v.confirmedAt.IsZero() && l.now().Sub(v.confirmedAt) < l.confirmGrace {
+			rebuilt[name] = v
+		}
+	}
+	l.volumes = rebuilt
+	for uid, r := range l.reservations {
+		if !r.expires.IsZero() && !l.now().Before(r.expires) {
+			delete(l.reservations, uid)
+		}
+	}
+}
+
+// Reserve records size for the claim if the capacity returned by compute,
+// given everything already in the ledger, can hold it. Reserving a claim that
+// already holds a reservation, or whose PV is already stored, is a no-op.
+func (l *Ledger) Reserve(claimUID, pvName string, size int64, compute ComputeFunc) error {
+	return l.ReserveGroup(Request{ClaimUID: claimUID, PVName: pvName, Size: size}, nil, 0, compute)
+}
+
+// ReserveGroup reserves the claim like Reserve, together with the other
+// members of its group that are not reserved or stored yet: the capacity must
+// hold them all. The reservations of the members are held for hold, so that
+// the room of the group is not handed out to another claim meanwhile. A member
+// reserved within that time keeps its reservation, the others are dropped by
+// Rebuild.
+func (l *Ledger) ReserveGroup(claim Request, members []Request, hold time.Duration, compute ComputeFunc) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.reservations[claim.ClaimUID]; ok {
+		r.expires = time.Time{}
+		l.reservations[claim.ClaimUID] = r
+		return nil
+	}
+	if _, ok := l.volumes[claim.PVName]; ok {
+		return nil
+	}
+	var pending []Request
+	size := claim.Size
+	for _, member := range members {
+		if _, ok := l.reservations[member.ClaimUID]; ok {
+			continue
+		}
+		if _, ok := l.volumes[member.PVName]; ok {
+			continue
+		}
+		pending = append(pending, member)
+		size += member.Size
+	}
+	if l.maxVolumes >= 0 && len(l.volumes)+len(l.reservations)+1+len(pending) > l.maxVolumes {
+		return &TooManyVolumesError{Max: l.maxVolumes}
+	}
+	c, err := compute(l.requested(), l.inFlight())
+	if err != nil {
+		return err
+	}
+	if !c.Fits(size) {
+		return &InsufficientCapacityError{Requested: size, Allocatable: c.Allocatable}
+	}
+	l.reservations[claim.ClaimUID] = reservation{pvName: claim.PVName, size: claim.Size}
+	expires := l.now().Add(hold)
+	for _, member := range pending {
+		l.reservations[member.ClaimUID] = reservation{pvName: member.PVName, size: member.Size, expires: expires}
+	}
+	return nil
+}
+
+// Confirm turns the reservation of the claim into a stored volume.
+func (l *Ledger) Confirm(claimUID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.reservations[claimUID]
+	if !ok {
+		return
+	}
+	delete(l.reservations, claimUID)
+	l.volumes[r.pvName] = volume{size: r.size, confirmedAt: l.now()}
+}
+
+// Release drops the reservation of the claim, if any.
+func (l *Ledger) Release(claimUID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.reservations, claimUID)
+}
+
+// Remove drops the volume, or the reservation made for it, from the ledger.
+func (l *Ledger) Remove(pvName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.volumes, pvName)
+	for uid, r := range l.reservations {
+		if r.pvName == pvName {
+			delete(l.reservations, uid)
+		}
+	}
+}
+
+// IsReserved returns whether the claim holds a reservation.
+func (l *Ledger) IsReserved(claimUID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.reservations[claimUID]
+	return ok
+}
+
+// Holds returns whether the volume is stored or reserved for.
+func (l *Ledger) Holds(pvName string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.volumes[pvName]; ok {
+		return true
+	}
+	for _, r := range l.reservations {
+		if r.pvName == pvName {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxVolumes limits how many volumes the pool holds, counting the
+// reservations. A negative max removes the limit. Volumes already over the
+// limit are kept, only new reservations are refused.
+func (l *Ledger) SetMaxVolumes(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxVolumes = max
+}
+
+// Full returns whether the pool holds as many volumes as it may.
+func (l *Ledger) Full() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.full()
+}
+
+func (l *Ledger) full() bool {
+	return l.maxVolumes >= 0 && len(l.volumes)+len(l.reservations) >= l.maxVolumes
+}
+
+// Requested returns the sum of the stored volumes and the reservations.
+func (l *Ledger) Requested() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requested()
+}
+
+// InFlight returns the sum of the reservations, the requests of the volumes
+// that are not stored yet.
+func (l *Ledger) InFlight() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight()
+}
+
+func (l *Ledger) inFlight() int64 {
+	var inFlight int64
+	for _, r := range l.reservations {
+		inFlight += r.size
+	}
+	return inFlight
+}
+
+func (l *Ledger) requested() int64 {
+	var requested int64
+	for _, v := range l.volumes {
+		requested += v.size
+	}
+	for _, r := range l.reservations {
+		requested += r.size
+	}
+	return requested
+}
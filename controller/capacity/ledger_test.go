@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capacity
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func computeFor(total int64) ComputeFunc {
+	return func(requested, inFlight int64) (Capacity, error) {
+		return DefaultPolicy().Compute(Stats{Total: total, Free: total, Available: total}, requested, inFlight), nil
+	}
+}
+
+func Test_LedgerConcurrentReserve(t *testing.T) {
+	ledger := NewLedger()
+	var admitted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uid := fmt.Sprintf("claim-%d", i)
+			if err := ledger.Reserve(uid, "pv-"+uid, 10*GiB, computeFor(100*GiB)); err == nil {
+				atomic.AddInt32(&admitted, 1)
+			} else if _, ok := err.(*InsufficientCapacityError); !ok {
+				t.Errorf("Reserve() unexpected error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if admitted != 10 {
+		t.Errorf("admitted %d claims, want 10", admitted)
+	}
+	if got := ledger.Requested(); got != 100*GiB {
+		t.Errorf("Requested() = %d, want %d", got, 100*GiB)
+	}
+}
+
+func Test_LedgerLifecycle(t *testing.T) {
+	now := time.Now()
+	ledger := NewLedger()
+	ledger.now = func() time.Time { return now }
+	ledger.Rebuild(map[string]int64{"existing": 50 * GiB})
+
+	if err := ledger.Reserve("a", "pv-a", 30*GiB, computeFor(100*GiB)); err != nil {
+		t.Fatalf("Reserve(a) error = %v", err)
+	}
+	if err := ledger.Reserve("a", "pv-a", 30*GiB, computeFor(100*GiB)); err != nil {
+		t.Errorf("Reserve(a) again should be a no-op, error = %v", err)
+	}
+	if err := ledger.Reserve("b", "pv-b", 30*GiB, computeFor(100*GiB)); err == nil {
+		t.Errorf("Reserve(b) should not fit")
+	}
+	if err := ledger.Reserve("c", "pv-c", 10*GiB, computeFor(100*GiB)); err != nil {
+		t.Fatalf("Reserve(c) error = %v", err)
+	}
+	if !ledger.Holds("existing") || !ledger.Holds("pv-c") || ledger.Holds("pv-b") {
+		t.Errorf("Holds() should report the stored and reserved volumes only")
+	}
+
+	// a is stored, c fails.
+	ledger.Confirm("a")
+	ledger.Release("c")
+	if ledger.IsReserved("a") || ledger.IsReserved("c") {
+		t.Errorf("no reservation should be left")
+	}
+	if got := ledger.Requested(); got != 80*GiB {
+		t.Errorf("Requested() = %d, want %d", got, 80*GiB)
+	}
+
+	// The PV list does not contain pv-a yet, it is kept during the grace period.
+	ledger.Rebuild(map[string]int64{"existing": 50 * GiB})
+	if got := ledger.Requested(); got != 80*GiB {
+		t.Errorf("Requested() after rebuild = %d, want %d", got, 80*GiB)
+	}
+	now = now.Add(DefaultConfirmGrace)
+	ledger.Rebuild(map[string]int64{"existing": 50 * GiB})
+	if got := ledger.Requested(); got != 50*GiB {
+		t.Errorf("Requested() after grace period = %d, want %d", got, 50*GiB)
+	}
+
+	ledger.Remove("existing")
+	if got := ledger.Requested(); got != 0 {
+		t.Errorf("Requested() after remove = %d, want 0", got)
+	}
+}
+
+func Test_LedgerMaxVolumes(t *testing.T) {
+	ledger := NewLedger()
+	ledger.Rebuild(map[string]int64{"existing": GiB})
+	ledger.SetMaxVolumes(2)
+
+	if err := ledger.Reserve("a", "pv-a", GiB, computeFor(100*GiB)); err != nil {
+		t.Fatalf("Reserve(a) error = %v", err)
+	}
+	if !ledger.Full() {
+		t.Errorf("Full() = false with 2 of 2 volumes")
+	}
+	if err := ledger.Reserve("a", "pv-a", GiB, computeFor(100*GiB)); err != nil {
+		t.Errorf("Reserve(a) again should be a no-op, error = %v", err)
+	}
+	if _, ok := ledger.Reserve("b", "pv-b", GiB, computeFor(100*GiB)).(*TooManyVolumesError); !ok {
+		t.Errorf("Reserve(b) should fail with TooManyVolumesError")
+	}
+
+	ledger.Release("a")
+	if ledger.Full() {
+		t.Errorf("Full() = true with 1 of 2 volumes")
+	}
+	ledger.SetMaxVolumes(-1)
+	for _, uid := range []string{"b", "c", "d"} {
+		if err := ledger.Reserve(uid, "pv-"+uid, GiB, computeFor(100*GiB)); err != nil {
+			t.Errorf("Reserve(%s) without limit error = %v", uid, err)
+		}
+	}
+}
+
+func Test_LedgerReserveGroup(t *testing.T) {
+	now := time.Now()
+	ledger := NewLedger()
+	ledger.now = func() time.Time { return now }
+	members := []Request{
+		{ClaimUID: "data", PVName: "pv-data", Size: 40 * GiB},
+		{ClaimUID: "scratch", PVName: "pv-scratch", Size: 20 * GiB},
+	}
+
+	if err := ledger.ReserveGroup(Request{ClaimUID: "root", PVName: "pv-root", Size: 50 * GiB}, members, time.Minute, computeFor(100*GiB)); err == nil {
+		t.Errorf("ReserveGroup() of 110GiB should not fit")
+	}
+	if err := ledger.ReserveGroup(Request{ClaimUID: "root", PVName: "pv-root", Size: 30 * GiB}, members, time.Minute, computeFor(100*GiB)); err != nil {
+		t.Fatalf("ReserveGroup() error = %v", err)
+	}
+	if got := ledger.Requested(); got != 90*GiB {
+		t.Errorf("Requested() = %d, want %d", got, 90*GiB)
+	}
+	// the room of the group is not handed out to another claim
+	if _, ok := ledger.Reserve("other", "pv-other", 20*GiB, computeFor(100*GiB)).(*InsufficientCapacityError); !ok {
+		t.Errorf("Reserve(other) should fail with InsufficientCapacityError")
+	}
+	// data is provisioned within the hold and keeps its reservation
+	if err := ledger.ReserveGroup(Request{ClaimUID: "data", PVName: "pv-data", Size: 40 * GiB}, nil, 0, computeFor(0)); err != nil {
+		t.Errorf("ReserveGroup(data) error = %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	ledger.Rebuild(map[string]int64{})
+	if !ledger.IsReserved("root") || !ledger.IsReserved("data") || ledger.IsReserved("scratch") {
+		t.Errorf("only the reservation held for scratch should expire")
+	}
+	if got := ledger.Requested(); got != 70*GiB {
+		t.Errorf("Requested() after the hold = %d, want %d", got, 70*GiB)
+	}
+}
+
+func Test_LedgerConcurrentReserveActualFree(t *testing.T) {
+	// both claims see the same free space, neither is written yet
+	policy := Policy{Mode: ModeActualFree, OvercommitRatio: 1}
+	snapshot := Stats{Total: 100 * GiB, Free: 50 * GiB, Available: 50 * GiB}
+	compute := func(requested, inFlight int64) (Capacity, error) {
+		return policy.Compute(snapshot, requested, inFlight), nil
+	}
+	ledger := NewLedger()
+	ledger.Rebuild(map[string]int64{"existing": 50 * GiB})
+	var admitted int32
+	var wg sync.WaitGroup
+	for _, uid := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			if err := ledger.Reserve(uid, "pv-"+uid, 30*GiB, compute); err == nil {
+				atomic.AddInt32(&admitted, 1)
+			} else if _, ok := err.(*InsufficientCapacityError); !ok {
+				t.Errorf("Reserve(%s) unexpected error = %v", uid, err)
+			}
+		}(uid)
+	}
+	wg.Wait()
+	if admitted != 1 {
+		t.Errorf("admitted %d claims, want 1", admitted)
+	}
+	if got := ledger.InFlight(); got != 30*GiB {
+		t.Errorf("InFlight() = %d, want %d", got, 30*GiB)
+	}
+}
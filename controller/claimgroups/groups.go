@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claimgroups
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const (
+	// AnnGroup puts a claim in a co-location group, all claims of the group in
+	// the namespace are placed on the same node.
+	AnnGroup = "hostpath.kubevirt.io/group"
+	// AnnAntiAffinity spreads claims, no two claims with the same value in the
+	// namespace are placed on the same node. StatefulSets set it in their
+	// volumeClaimTemplates, so the claims of their replicas land on different nodes.
+	AnnAntiAffinity = "hostpath.kubevirt.io/anti-affinity"
+
+	// GroupIndex indexes claims by namespace and group.
+	GroupIndex = "group"
+	// AntiAffinityIndex indexes claims by namespace and anti-affinity key.
+	AntiAffinityIndex = "antiAffinity"
+
+	annSelectedNode = "volume.kubernetes.io/selected-node"
+)
+
+// Constraint is what the group and anti-affinity annotations of a claim
+// require from the node it is placed on.
+type Constraint struct {
+	// Node is set when other members of the group are on a node already.
+	Node string
+	// Pending are the members of the group that are not provisioned yet,
+	// including the claim. A claim without group is its own only member.
+	Pending []*v1.PersistentVolumeClaim
+	// Requested is the sum of the requests of the pending members, the node
+	// must hold them all. Callers subtract the members they already account
+	// for, e.g. claims holding a reservation.
+	Requested int64
+	// Excluded are the nodes holding a claim with the same anti-affinity key.
+	Excluded map[string]bool
+	// Conflict is set when the members of the group are on different nodes
+	// already, no node can satisfy the claim.
+	Conflict string
+}
+
+// Allows returns why the claim can not be placed on the node, or "" when
+// the node satisfies the annotations of the claim. Capacity is not checked.
+func (c *Constraint) Allows(nodeName string) string {
+	if c.Conflict != "" {
+		return c.Conflict
+	}
+	if c.Node != "" && c.Node != nodeName {
+		return fmt.Sprintf("claim group is placed on node %s", c.Node)
+	}
+	if c.Excluded[nodeName] {
+		return "node holds a claim with the same anti-affinity"
+	}
+	return ""
+}
+
+// Check returns a ConstraintError when the claim can not be placed on the node.
+func (c *Constraint) Check(nodeName string) error {
+	if reason := c.Allows(nodeName); reason != "" {
+		return &ConstraintError{Node: nodeName, Reason: reason}
+	}
+	return nil
+}
+
+// ConstraintError is returned by Check when the annotations of a claim do not
+// allow a node.
+type ConstraintError struct {
+	Node   string
+	Reason string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("node %s can not take the claim: %s", e.Node, e.Reason)
+}
+
+// Groups answers group and anti-affinity queries from a claim informer.
+type Groups struct {
+	claims  cache.Indexer
+	volumes cache.Store
+}
+
+// New adds the group and anti-affinity indexers to the claim informer and
+// returns Groups reading from it and from the PV informer. It must be called
+// before the claim informer is started.
+func New(claimInformer cache.SharedIndexInformer, volumeInformer cache.SharedIndexInformer) (*Groups, error) {
+	err := claimInformer.AddIndexers(cache.Indexers{
+		GroupIndex:        annotationIndexFunc(AnnGroup),
+		AntiAffinityIndex: annotationIndexFunc(AnnAntiAffinity),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Groups{claims: claimInformer.GetIndexer(), volumes: volumeInformer.GetStore()}, nil
+}
+
+func annotationIndexFunc(annotation string) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		if claim, ok := obj.(*v1.PersistentVolumeClaim); ok {
+			if value := claim.Annotations[annotation]; value != "" {
+				return []string{claim.Namespace + "/" + value}, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// Constraint returns the constraint the annotations of the claim put on its node.
+func (g *Groups) Constraint(claim *v1.PersistentVolumeClaim) *Constraint {
+	c := &Constraint{Excluded: map[string]bool{}}
+	members := []*v1.PersistentVolumeClaim{claim}
+	if group := claim.Annotations[AnnGroup]; group != "" {
+		members = g.byIndex(GroupIndex, claim.Namespace+"/"+group, claim)
+	}
+	var nodes []string
+	for _, member := range members {
+		if member.Spec.VolumeName == "" {
+			c.Pending = append(c.Pending, member)
+			c.Requested += member.Spec.Resources.Requests.Storage().Value()
+		}
+		node := g.NodeOf(member)
+		if member.UID != claim.UID && node == "" {
+			node = member.Annotations[annSelectedNode]
+		}
+		if node == "" {
+			continue
+		}
+		if c.Node == "" {
+			c.Node = node
+		} else if node != c.Node {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) > 0 {
+		c.Conflict = fmt.Sprintf("claim group is split over nodes %s and %v", c.Node, nodes)
+	}
+
+	if key := claim.Annotations[AnnAntiAffinity]; key != "" {
+		for _, other := range g.byIndex(AntiAffinityIndex, claim.Namespace+"/"+key, claim) {
+			if other.UID == claim.UID {
+				continue
+			}
+			node := g.NodeOf(other)
+			if node == "" {
+				node = other.Annotations[annSelectedNode]
+			}
+			if node != "" {
+				c.Excluded[node] = true
+			}
+		}
+	}
+	return c
+}
+
+// NodeOf returns the node the claim is provisioned or assigned to, or "".
+func (g *Groups) NodeOf(claim *v1.PersistentVolumeClaim) string {
+	if claim.Spec.VolumeName != "" {
+		if obj, exists, err := g.volumes.GetByKey(claim.Spec.VolumeName); err == nil && exists {
+			if pv, ok := obj.(*v1.PersistentVolume); ok {
+				return pv.Annotations[nodevolumes.AnnProvisionOnNode]
+			}
+		}
+	}
+	return claim.Annotations[nodevolumes.AnnProvisionOnNode]
+}
+
+// byIndex returns the claims under key, which always include claim. The
+// claims are sorted by name, so every component sees the same order.
+func (g *Groups) byIndex(indexName, key string, claim *v1.PersistentVolumeClaim) []*v1.PersistentVolumeClaim {
+	claims := []*v1.PersistentVolumeClaim{claim}
+	objs, _ := g.claims.ByIndex(indexName, key)
+	for _, obj := range objs {
+		if other, ok := obj.(*v1.PersistentVolumeClaim); ok && other.UID != claim.UID {
+			claims = append(claims, other)
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].Name < claims[j].Name })
+	return claims
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package claimgroups
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const GiB = int64(1024 * 1024 * 1024)
+
+func newClaim(name, size, volumeName string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			VolumeName: volumeName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func newTestGroups(t *testing.T, claims ...*v1.PersistentVolumeClaim) *Groups {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	volumeInformer := factory.Core().V1().PersistentVolumes().Informer()
+	groups, err := New(factory.Core().V1().PersistentVolumeClaims().Informer(), volumeInformer)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	volumeInformer.GetStore().Add(&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pv-bound",
+		Annotations: map[string]string{nodevolumes.AnnProvisionOnNode: "node-1"},
+	}})
+	for _, claim := range claims {
+		groups.claims.Add(claim)
+	}
+	return groups
+}
+
+func Test_Constraint(t *testing.T) {
+	group := map[string]string{AnnGroup: "vm"}
+	tests := []struct {
+		name          string
+		claims        []*v1.PersistentVolumeClaim
+		wantNode      string
+		wantPending   []string
+		wantRequested int64
+		wantExcluded  map[string]bool
+		wantConflict  bool
+	}{
+		{
+			name:          "claim without annotations is on its own",
+			claims:        []*v1.PersistentVolumeClaim{newClaim("a", "10Gi", "", nil)},
+			wantPending:   []string{"a"},
+			wantRequested: 10 * GiB,
+			wantExcluded:  map[string]bool{},
+		},
+		{
+			name: "unplaced group is checked as a whole",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "10Gi", "", group),
+				newClaim("b", "20Gi", "", group),
+				newClaim("c", "5Gi", "", map[string]string{AnnGroup: "other"}),
+			},
+			wantPending:   []string{"a", "b"},
+			wantRequested: 30 * GiB,
+			wantExcluded:  map[string]bool{},
+		},
+		{
+			name: "group follows its provisioned member",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "10Gi", "", group),
+				newClaim("b", "20Gi", "pv-bound", group),
+			},
+			wantNode:      "node-1",
+			wantPending:   []string{"a"},
+			wantRequested: 10 * GiB,
+			wantExcluded:  map[string]bool{},
+		},
+		{
+			name: "group follows the node selected for a member",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "10Gi", "", group),
+				newClaim("b", "20Gi", "", map[string]string{AnnGroup: "vm", annSelectedNode: "node-2"}),
+			},
+			wantNode:      "node-2",
+			wantPending:   []string{"a", "b"},
+			wantRequested: 30 * GiB,
+			wantExcluded:  map[string]bool{},
+		},
+		{
+			name: "group split over nodes conflicts",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "10Gi", "", group),
+				newClaim("b", "20Gi", "pv-bound", group),
+				newClaim("c", "20Gi", "", map[string]string{AnnGroup: "vm", nodevolumes.AnnProvisionOnNode: "node-2"}),
+			},
+			wantNode:      "node-1",
+			wantPending:   []string{"a", "c"},
+			wantRequested: 30 * GiB,
+			wantExcluded:  map[string]bool{},
+			wantConflict:  true,
+		},
+		{
+			name: "anti-affinity excludes the nodes of the other claims",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("data-0", "10Gi", "", map[string]string{AnnAntiAffinity: "db"}),
+				newClaim("data-1", "10Gi", "pv-bound", map[string]string{AnnAntiAffinity: "db"}),
+				newClaim("data-2", "10Gi", "", map[string]string{AnnAntiAffinity: "db", nodevolumes.AnnProvisionOnNode: "node-2"}),
+				newClaim("data-3", "10Gi", "", map[string]string{AnnAntiAffinity: "db"}),
+			},
+			wantPending:   []string{"data-0"},
+			wantRequested: 10 * GiB,
+			wantExcluded:  map[string]bool{"node-1": true, "node-2": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := newTestGroups(t, tt.claims...)
+			c := groups.Constraint(tt.claims[0])
+			var pending []string
+			for _, claim := range c.Pending {
+				pending = append(pending, claim.Name)
+			}
+			if c.Node != tt.wantNode {
+				t.Errorf("Constraint() node = %q, want %q", c.Node, tt.wantNode)
+			}
+			if !reflect.DeepEqual(pending, tt.wantPending) {
+				t.Errorf("Constraint() pending = %v, want %v", pending, tt.wantPending)
+			}
+			if c.Requested != tt.wantRequested {
+				t.Errorf("Constraint() requested = %d, want %d", c.Requested, tt.wantRequested)
+			}
+			if !reflect.DeepEqual(c.Excluded, tt.wantExcluded) {
+				t.Errorf("Constraint() excluded = %v, want %v", c.Excluded, tt.wantExcluded)
+			}
+			if (c.Conflict != "") != tt.wantConflict {
+				t.Errorf("Constraint() conflict = %q, wantConflict %v", c.Conflict, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func Test_ConstraintCheck(t *testing.T) {
+	c := &Constraint{Node: "node-1", Excluded: map[string]bool{"node-2": true}}
+	if err := c.Check("node-1"); err != nil {
+		t.Errorf("Check(node-1) error = %v", err)
+	}
+	for _, node := range []string{"node-2", "node-3"} {
+		if _, ok := c.Check(node).(*ConstraintError); !ok {
+			t.Errorf("Check(%s) should return a ConstraintError", node)
+		}
+	}
+}
@@ -211,6 +211,10 @@ const (
 
 var errRuntime = fmt.Errorf("cannot call option functions after controller has Run")
 
+// errStopProvision is returned by provisionClaimOperation when the claim was
+// handed back to the scheduler and must not be retried.
+var errStopProvision = fmt.Errorf("stop provisioning")
+
 // ResyncPeriod is how often the controller relists PVCs, PVs, & storage
 // classes. OnUpdate will be called even if nothing has changed, meaning failed
 // operations may be retried on a PVC/PV every resyncPeriod regardless of
@@ -786,6 +790,17 @@ func (ctrl *ProvisionController) Run(_ <-chan struct{}) {
 				metrics.PersistentVolumeDeleteTotal,
 				metrics.PersistentVolumeDeleteFailedTotal,
 				metrics.PersistentVolumeDeleteDurationSeconds,
+				metrics.CapacityTotalBytes,
+				metrics.CapacityAvailableBytes,
+				metrics.CapacityRequestedBytes,
+				metrics.CapacityAllocatableBytes,
+				metrics.CapacityUsedGrowthBytesPerDay,
+				metrics.CapacityRequestedGrowthBytesPerDay,
+				metrics.CapacityFullTimestampSeconds,
+				metrics.CapacityRequestsFullTimestampSeconds,
+				metrics.WarmPoolSize,
+				metrics.WarmPoolHitsTotal,
+				metrics.WarmPoolMissesTotal,
 			}...)
 			http.Handle(ctrl.metricsPath, promhttp.Handler())
 			address := net.JoinHostPort(ctrl.metricsAddress, strconv.FormatInt(int64(ctrl.metricsPort), 10))
@@ -885,7 +900,12 @@ func (ctrl *ProvisionController) processNextClaimWorkItem() bool {
 			return fmt.Errorf("expected string in workqueue but got %#v", obj)
 		}
 
-		if _, err := ctrl.syncClaimHandler(key); err != nil {
+		if _, err := ctrl.syncClaimHandler(key); err == errStopProvision {
+			glog.V(2).Infof("Stopped provisioning, removing PVC %s from claims in progress", key)
+			ctrl.claimQueue.Forget(obj)
+			ctrl.claimsInProgress.Delete(key)
+			return nil
+		} else if err != nil {
 			if ctrl.failedProvisionThreshold == 0 {
 				glog.Warningf("Retrying syncing claim %q, failure %v", key, ctrl.claimQueue.NumRequeues(obj))
 				ctrl.claimQueue.AddRateLimited(obj)
@@ -896,6 +916,7 @@ func (ctrl *ProvisionController) processNextClaimWorkItem() bool {
 				glog.Errorf("Giving up syncing claim %q because failures %v >= threshold %v", key, ctrl.claimQueue.NumRequeues(obj), ctrl.failedProvisionThreshold)
 				glog.V(2).Infof("Removing PVC %s from claims in progress", key)
 				ctrl.claimsInProgress.Delete(key) // This can leak a volume that's being provisioned in the background!
+				ctrl.releaseReservation(key)
 				// Done but do not Forget: it will not be in the queue but NumRequeues
 				// will be saved until the obj is deleted from kubernetes
 			}
@@ -1285,6 +1306,22 @@ func (ctrl *ProvisionController) provisionClaimOperation(claim *v1.PersistentVol
 		}
 		err = fmt.Errorf("failed to provision volume with StorageClass %q: %v", claimClass, err)
 		ctrl.eventRecorder.Event(claim, v1.EventTypeWarning, "ProvisioningFailed", err.Error())
+		if result != ProvisioningInBackground {
+			ctrl.releaseReservation(string(claim.UID))
+		}
+		if result == ProvisioningReschedule {
+			if _, ok := claim.Annotations[annSelectedNode]; ok {
+				// The selected node can not hold the volume, let the scheduler
+				// try another one.
+				if rerr := ctrl.rescheduleProvisioning(claim); rerr != nil {
+					glog.Error(logOperation(operation, "failed to reschedule: %v", rerr))
+					return ProvisioningFinished, err
+				}
+				glog.Info(logOperation(operation, "volume rescheduled because: %v", err))
+				return ProvisioningFinished, errStopProvision
+			}
+			result = ProvisioningFinished
+		}
 		return result, err
 	}
 
@@ -1308,11 +1345,40 @@ func (ctrl *ProvisionController) provisionClaimOperation(claim *v1.PersistentVol
 	glog.Info(logOperation(operation, "succeeded"))
 
 	if err := ctrl.volumeStore.StoreVolume(claim, volume); err != nil {
+		ctrl.releaseReservation(string(claim.UID))
 		return ProvisioningFinished, err
 	}
+	if reserver, ok := ctrl.provisioner.(CapacityReserver); ok {
+		reserver.ConfirmReservation(claim, volume)
+	}
 	return ProvisioningFinished, nil
 }
 
+// rescheduleProvisioning removes the selected node annotation from the claim,
+// which makes the scheduler pick a node for the pod again.
+func (ctrl *ProvisionController) rescheduleProvisioning(claim *v1.PersistentVolumeClaim) error {
+	newClaim := claim.DeepCopy()
+	delete(newClaim.Annotations, annSelectedNode)
+	updatedClaim, err := ctrl.client.CoreV1().PersistentVolumeClaims(newClaim.Namespace).Update(context.TODO(), newClaim, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to remove annotation %q from claim %q: %v", annSelectedNode, claimToClaimKey(claim), err)
+	}
+	// Save the updated claim into the informer cache, so the old selected
+	// node is not used again before the informer catches up.
+	if err := ctrl.claimsIndexer.Update(updatedClaim); err != nil {
+		glog.Warningf("failed to update claim %q in the informer cache: %v", claimToClaimKey(claim), err)
+	}
+	return nil
+}
+
+// releaseReservation tells a CapacityReserver provisioner that the claim with
+// the given UID no longer needs its reservation.
+func (ctrl *ProvisionController) releaseReservation(claimUID string) {
+	if reserver, ok := ctrl.provisioner.(CapacityReserver); ok {
+		reserver.ReleaseReservation(claimUID)
+	}
+}
+
 // deleteVolumeOperation attempts to delete the volume backing the given
 // volume. Returns error, which indicates whether deletion should be retried
 // (requeue the volume) or not
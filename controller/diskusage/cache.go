@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskusage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	glog "k8s.io/klog"
+)
+
+// DefaultPeriod is how often the directories are walked.
+const DefaultPeriod = 5 * time.Minute
+
+// Cache holds the usage of the directories paths returns, as of the last
+// refresh. Walking a directory touches every file in it, so it is done on a
+// period of its own instead of whenever the usage is read.
+type Cache struct {
+	period    time.Duration
+	paths     func() []string
+	onRefresh func()
+	trigger   chan struct{}
+
+	mu    sync.Mutex
+	usage map[string]*resource.Quantity
+}
+
+// New returns a Cache of the usage of the directories paths returns, walked
+// every period. onRefresh is called after every refresh and may be nil.
+func New(period time.Duration, paths func() []string, onRefresh func()) *Cache {
+	return &Cache{
+		period:    period,
+		paths:     paths,
+		onRefresh: onRefresh,
+		trigger:   make(chan struct{}, 1),
+		usage:     map[string]*resource.Quantity{},
+	}
+}
+
+// Get returns the usage of the directory at path, false when it was not
+// measured yet or could not be read.
+func (c *Cache) Get(path string) (*resource.Quantity, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	used, ok := c.usage[path]
+	if !ok {
+		return nil, false
+	}
+	copied := used.DeepCopy()
+	return &copied, true
+}
+
+// Trigger asks for the usage to be refreshed as soon as possible.
+func (c *Cache) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run refreshes the usage every period and when triggered until stopCh is
+// closed.
+func (c *Cache) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(c.period)
+	defer ticker.Stop()
+	for {
+		c.Refresh()
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+		}
+	}
+}
+
+// Refresh walks the directories paths returns and forgets the others.
+func (c *Cache) Refresh() {
+	usage := map[string]*resource.Quantity{}
+	for _, path := range c.paths() {
+		used, err := Usage(path)
+		if err != nil {
+			glog.V(4).Infof("failed to compute the usage of %s: %v", path, err)
+			continue
+		}
+		usage[path] = used
+	}
+	c.mu.Lock()
+	c.usage = usage
+	c.mu.Unlock()
+	if c.onRefresh != nil {
+		c.onRefresh()
+	}
+}
+
+// Usage returns the space the files below path take on disk.
+func Usage(path string) (*resource.Quantity, error) {
+	var used int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			// st_blocks is counted in 512 byte units whatever the block size
+			used += stat.Blocks * 512
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resource.NewQuantity(used, resource.BinarySI), nil
+}
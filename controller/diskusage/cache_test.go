@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskusage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const KiB = 1024
+
+func Test_Cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskusage")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory, error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	volume := filepath.Join(dir, "volume")
+	if err := os.Mkdir(volume, 0777); err != nil {
+		t.Fatalf("Unable to create volume directory, error = %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(volume, "data"), make([]byte, 64*KiB), 0644); err != nil {
+		t.Fatalf("Unable to write data, error = %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+	paths := []string{volume, missing}
+	refreshed := 0
+	c := New(DefaultPeriod, func() []string { return paths }, func() { refreshed++ })
+
+	if _, ok := c.Get(volume); ok {
+		t.Errorf("Get() before the first refresh should report no usage")
+	}
+	c.Refresh()
+	if used, ok := c.Get(volume); !ok || used.Value() < 64*KiB {
+		t.Errorf("Get() = %v, %v, want at least 64Ki", used, ok)
+	}
+	if _, ok := c.Get(missing); ok {
+		t.Errorf("Get() of a missing directory should report no usage")
+	}
+	if refreshed != 1 {
+		t.Errorf("onRefresh called %d times, want 1", refreshed)
+	}
+
+	// the file is only counted again with the next refresh
+	if err := ioutil.WriteFile(filepath.Join(volume, "more"), make([]byte, 64*KiB), 0644); err != nil {
+		t.Fatalf("Unable to write data, error = %v", err)
+	}
+	if used, _ := c.Get(volume); used.Value() >= 128*KiB {
+		t.Errorf("Get() = %v before the refresh, want the cached usage", used)
+	}
+	paths = []string{missing}
+	c.Refresh()
+	if _, ok := c.Get(volume); ok {
+		t.Errorf("Get() should forget the directories paths no longer returns")
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extender
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	glog "k8s.io/klog"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/util"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+)
+
+// Strategy selects how nodes that can hold the claims of a pod are scored.
+type Strategy string
+
+const (
+	// StrategyMostFree prefers the nodes with the most capacity left after
+	// placing the claims, spreading volumes over the pools.
+	StrategyMostFree Strategy = "most-free"
+	// StrategyBinPack prefers the nodes with the least capacity left after
+	// placing the claims, filling pools up before using new ones.
+	StrategyBinPack Strategy = "bin-pack"
+)
+
+// Extender filters and scores nodes for pods with unbound claims of the
+// hostpath provisioner.
+type Extender struct {
+	provisionerName string
+	strategy        Strategy
+	claims          corelisters.PersistentVolumeClaimLister
+	groups          *claimgroups.Groups
+	classes         storagelisters.StorageClassLister
+	nodes           corelisters.NodeLister
+	volumes         *nodevolumes.Index
+	capacities      capacity.NodeCapacityFunc
+	admit           placement.AdmitFunc
+}
+
+// New returns an Extender for the claims of provisionerName, using capacities
+// for the capacity of the nodes and admit, the check of the node agents, for
+// the nodes that would refuse the claims. groups must read from the same
+// claims as claims. nodes is used when the scheduler only sends node names.
+func New(provisionerName string, strategy Strategy, claims corelisters.PersistentVolumeClaimLister, groups *claimgroups.Groups, classes storagelisters.StorageClassLister,
+	nodes corelisters.NodeLister, volumes *nodevolumes.Index, capacities capacity.NodeCapacityFunc, admit placement.AdmitFunc) (*Extender, error) {
+	switch strategy {
+	case "":
+		strategy = StrategyMostFree
+	case StrategyMostFree, StrategyBinPack:
+	default:
+		return nil, fmt.Errorf("unknown strategy %q, must be %s or %s", strategy, StrategyMostFree, StrategyBinPack)
+	}
+	return &Extender{
+		provisionerName: provisionerName,
+		strategy:        strategy,
+		claims:          claims,
+		groups:          groups,
+		classes:         classes,
+		nodes:           nodes,
+		volumes:         volumes,
+		capacities:      capacities,
+		admit:           admit,
+	}, nil
+}
+
+// podClaims is the demand the unbound hostpath claims of a pod place on a node.
+type podClaims struct {
+	// requested is the sum of the requests of the claims and of the members
+	// of their groups that are not provisioned yet, they land on the same node.
+	requested int64
+	// constraints are what the annotations of the claims require from the node.
+	constraints []*claimgroups.Constraint
+	// classes are the storage classes of the counted claims, one per volume
+	// the node has to take.
+	classes []string
+	// pools are the pools the claims are placed in.
+	pools []string
+}
+
+// podClaims returns the demand of the unbound hostpath claims of the pod.
+func (e *Extender) podClaims(pod *v1.Pod) (podClaims, error) {
+	var demand podClaims
+	counted := map[string]bool{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claim, err := e.claims.PersistentVolumeClaims(pod.Namespace).Get(volume.PersistentVolumeClaim.ClaimName)
+		if err != nil {
+			return demand, fmt.Errorf("failed to get claim %s/%s: %v", pod.Namespace, volume.PersistentVolumeClaim.ClaimName, err)
+		}
+		if claim.Spec.VolumeName != "" {
+			continue
+		}
+		className := util.GetPersistentVolumeClaimClass(claim)
+		if className == "" {
+			continue
+		}
+		class, err := e.classes.Get(className)
+		if err != nil {
+			return demand, fmt.Errorf("failed to get storage class %s: %v", className, err)
+		}
+		if class.Provisioner != e.provisionerName {
+			continue
+		}
+		constraint := e.groups.Constraint(claim)
+		demand.constraints = append(demand.constraints, constraint)
+		if pool := claim.Annotations[nodevolumes.AnnPool]; pool != "" {
+			demand.pools = append(demand.pools, pool)
+		}
+		// claims of the pod may share a group, count every member once
+		for _, member := range constraint.Pending {
+			if !counted[string(member.UID)] {
+				counted[string(member.UID)] = true
+				demand.requested += member.Spec.Resources.Requests.Storage().Value()
+				demand.classes = append(demand.classes, util.GetPersistentVolumeClaimClass(member))
+			}
+		}
+	}
+	return demand, nil
+}
+
+// fits returns why the claims do not fit on the node, or "" when they do.
+// Claims of the default pool may be placed on any node.
+func (e *Extender) fits(demand podClaims, node *v1.Node) string {
+	for _, constraint := range demand.constraints {
+		if reason := constraint.Allows(node.Name); reason != "" {
+			return reason
+		}
+	}
+	for _, pool := range demand.pools {
+		if pool != noderesources.DefaultPool && node.Labels[noderesources.PoolLabel(pool)] != "true" {
+			return fmt.Sprintf("node has no pool %q, it is not labeled %s=true", pool, noderesources.PoolLabel(pool))
+		}
+	}
+	if e.admit != nil {
+		volumes := len(e.volumes.ByNode(node.Name))
+		for i, class := range demand.classes {
+			if reason := e.admit(node, class, volumes+i); reason != "" {
+				return reason
+			}
+		}
+	}
+	nodeCapacity, ok := e.capacities(node.Name)
+	if !ok {
+		return "node does not report hostpath capacity"
+	}
+	if !nodeCapacity.Fits(demand.requested) {
+		return fmt.Sprintf("insufficient hostpath capacity: requested %s, allocatable %s",
+			resource.NewQuantity(demand.requested, resource.BinarySI).String(),
+			resource.NewQuantity(nodeCapacity.Allocatable, resource.BinarySI).String())
+	}
+	return ""
+}
+
+// Filter removes the nodes whose pools can not hold all the unbound hostpath
+// claims of the pod together.
+func (e *Extender) Filter(args *ExtenderArgs) *ExtenderFilterResult {
+	demand, err := e.podClaims(args.Pod)
+	if err != nil {
+		return &ExtenderFilterResult{Error: err.Error()}
+	}
+	if len(demand.constraints) == 0 {
+		return &ExtenderFilterResult{Nodes: args.Nodes, NodeNames: args.NodeNames}
+	}
+
+	result := &ExtenderFilterResult{FailedNodes: FailedNodesMap{}}
+	if args.Nodes != nil {
+		result.Nodes = &v1.NodeList{}
+		for i, node := range args.Nodes.Items {
+			if reason := e.fits(demand, &args.Nodes.Items[i]); reason != "" {
+				result.FailedNodes[node.Name] = reason
+			} else {
+				result.Nodes.Items = append(result.Nodes.Items, node)
+			}
+		}
+	}
+	if args.NodeNames != nil {
+		nodeNames := []string{}
+		for _, name := range *args.NodeNames {
+			node, err := e.nodes.Get(name)
+			if err != nil {
+				result.FailedNodes[name] = fmt.Sprintf("failed to get node: %v", err)
+				continue
+			}
+			if reason := e.fits(demand, node); reason != "" {
+				result.FailedNodes[name] = reason
+			} else {
+				nodeNames = append(nodeNames, name)
+			}
+		}
+		result.NodeNames = &nodeNames
+	}
+	glog.V(4).Infof("filtered nodes for pod %s/%s, failed: %v", args.Pod.Namespace, args.Pod.Name, result.FailedNodes)
+	return result
+}
+
+// Prioritize scores the nodes by the capacity left after placing the unbound
+// hostpath claims of the pod, according to the strategy.
+func (e *Extender) Prioritize(args *ExtenderArgs) (HostPriorityList, error) {
+	demand, err := e.podClaims(args.Pod)
+	if err != nil {
+		return nil, err
+	}
+	nodeNames, nodes := e.candidateNodes(args)
+	scores := make(HostPriorityList, 0, len(nodeNames))
+	if len(demand.constraints) == 0 {
+		for _, name := range nodeNames {
+			scores = append(scores, HostPriority{Host: name})
+		}
+		return scores, nil
+	}
+
+	left := make(map[string]int64, len(nodeNames))
+	var maxLeft int64
+	for _, name := range nodeNames {
+		if node, ok := nodes[name]; !ok || e.fits(demand, node) != "" {
+			continue
+		}
+		nodeCapacity, _ := e.capacities(name)
+		left[name] = nodeCapacity.Allocatable - demand.requested
+		if left[name] > maxLeft {
+			maxLeft = left[name]
+		}
+	}
+	for _, name := range nodeNames {
+		var score int64
+		if l, ok := left[name]; ok {
+			score = MaxPriority
+			if maxLeft > 0 {
+				score = l * MaxPriority / maxLeft
+			}
+			if e.strategy == StrategyBinPack {
+				score = MaxPriority - score
+			}
+		}
+		scores = append(scores, HostPriority{Host: name, Score: score})
+	}
+	return scores, nil
+}
+
+// candidateNodes returns the names of the nodes of the args and the nodes by
+// name, read from the node lister when the scheduler only sent their names.
+// Nodes that are gone are left out of the map.
+func (e *Extender) candidateNodes(args *ExtenderArgs) ([]string, map[string]*v1.Node) {
+	var names []string
+	nodes := map[string]*v1.Node{}
+	if args.Nodes != nil {
+		for i := range args.Nodes.Items {
+			names = append(names, args.Nodes.Items[i].Name)
+			nodes[args.Nodes.Items[i].Name] = &args.Nodes.Items[i]
+		}
+	} else if args.NodeNames != nil {
+		for _, name := range *args.NodeNames {
+			names = append(names, name)
+			if node, err := e.nodes.Get(name); err == nil {
+				nodes[name] = node
+			}
+		}
+	}
+	return names, nodes
+}
+
+// Handler returns the HTTP handler serving the /filter and /prioritize verbs.
+func (e *Extender) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/filter", func(w http.ResponseWriter, r *http.Request) {
+		args, ok := decodeArgs(w, r)
+		if !ok {
+			return
+		}
+		encodeResult(w, e.Filter(args))
+	})
+	mux.HandleFunc("/prioritize", func(w http.ResponseWriter, r *http.Request) {
+		args, ok := decodeArgs(w, r)
+		if !ok {
+			return
+		}
+		scores, err := e.Prioritize(args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		encodeResult(w, scores)
+	})
+	return mux
+}
+
+func decodeArgs(w http.ResponseWriter, r *http.Request) (*ExtenderArgs, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	args := &ExtenderArgs{}
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil || args.Pod == nil {
+		http.Error(w, fmt.Sprintf("invalid extender args: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return args, true
+}
+
+func encodeResult(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		glog.Errorf("failed to encode extender result: %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extender
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	diskv2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	diskmonitorlisters "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const (
+	testProvisioner = "kubevirt.io/hostpath-provisioner"
+	testNamespace   = "hostpath-provisioner"
+)
+
+func newClaim(name, class, size, volumeName string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &class,
+			VolumeName:       volumeName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func newTestExtender(t *testing.T, strategy Strategy, claims ...*v1.PersistentVolumeClaim) *Extender {
+	return newAdmittingExtender(t, strategy, maintenance.Config{}, claims...)
+}
+
+// newAdmittingExtender returns an extender that skips the nodes in maintenance
+// according to config.
+func newAdmittingExtender(t *testing.T, strategy Strategy, config maintenance.Config, claims ...*v1.PersistentVolumeClaim) *Extender {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	claimInformer := factory.Core().V1().PersistentVolumeClaims()
+	volumes, err := nodevolumes.New(factory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	groups, err := claimgroups.New(claimInformer.Informer(), volumes.Informer())
+	if err != nil {
+		t.Fatalf("claimgroups.New() error = %v", err)
+	}
+	if len(claims) == 0 {
+		claims = []*v1.PersistentVolumeClaim{
+			newClaim("vm-1-rootdisk", "hostpath", "30Gi", "", nil),
+			newClaim("vm-1-datadisk", "hostpath", "20Gi", "", nil),
+		}
+	}
+	claims = append(claims, newClaim("shared-bound", "hostpath", "500Gi", "pvc-shared", nil))
+	for _, claim := range claims {
+		claimInformer.Informer().GetIndexer().Add(claim)
+	}
+	classes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "hostpath"}, Provisioner: testProvisioner})
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "diskmonitors.json"))
+	if err != nil {
+		t.Fatalf("failed to read DiskMonitors: %v", err)
+	}
+	list := &diskv2.DiskMonitorList{}
+	if err := json.Unmarshal(data, list); err != nil {
+		t.Fatalf("failed to decode DiskMonitors: %v", err)
+	}
+	diskMonitors := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for i := range list.Items {
+		diskMonitors.Add(&list.Items[i])
+	}
+
+	// the nodes of prioritize-args.json, which only names them
+	for _, name := range []string{"node-1", "node-2", "node-3", "node-4"} {
+		factory.Core().V1().Nodes().Informer().GetIndexer().Add(&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/hostname": name}},
+		})
+	}
+	lister := diskmonitorlisters.NewDiskMonitorLister(diskMonitors)
+	e, err := New(testProvisioner, strategy, claimInformer.Lister(), groups, storagelisters.NewStorageClassLister(classes),
+		factory.Core().V1().Nodes().Lister(), volumes, monitor_disk.NodeCapacity(lister, testNamespace, capacity.DefaultPolicy()),
+		monitor_disk.NodeAdmission(lister, testNamespace, config))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return e
+}
+
+func post(t *testing.T, handler http.Handler, verb, fixture string, result interface{}) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", fixture, err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, verb, bytes.NewReader(data)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s returned %d: %s", verb, recorder.Code, recorder.Body.String())
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+		t.Fatalf("failed to decode %s result: %v", verb, err)
+	}
+}
+
+func Test_Filter(t *testing.T) {
+	e := newTestExtender(t, StrategyMostFree)
+	result := &ExtenderFilterResult{}
+	post(t, e.Handler(), "/filter", "filter-args.json", result)
+
+	if result.Error != "" {
+		t.Fatalf("Filter() error = %s", result.Error)
+	}
+	var nodes []string
+	for _, node := range result.Nodes.Items {
+		nodes = append(nodes, node.Name)
+	}
+	if want := []string{"node-1", "node-4"}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Filter() nodes = %v, want %v", nodes, want)
+	}
+	var failed []string
+	for name := range result.FailedNodes {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	if want := []string{"node-2", "node-3"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("Filter() failed nodes = %v, want %v", result.FailedNodes, want)
+	}
+}
+
+func Test_FilterGroups(t *testing.T) {
+	group := map[string]string{claimgroups.AnnGroup: "vm-1"}
+	tests := []struct {
+		name   string
+		claims []*v1.PersistentVolumeClaim
+		want   []string
+	}{
+		{
+			name: "unprovisioned members of the group must fit as well",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("vm-1-rootdisk", "hostpath", "30Gi", "", group),
+				newClaim("vm-1-datadisk", "hostpath", "20Gi", "", group),
+				newClaim("vm-1-scratch", "hostpath", "40Gi", "", group),
+			},
+			want: []string{"node-1"},
+		},
+		{
+			name: "group follows the node of a member",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("vm-1-rootdisk", "hostpath", "30Gi", "", group),
+				newClaim("vm-1-datadisk", "hostpath", "20Gi", "", nil),
+				newClaim("vm-1-scratch", "hostpath", "5Gi", "", map[string]string{claimgroups.AnnGroup: "vm-1", "kubevirt.io/provisionOnNode": "node-4"}),
+			},
+			want: []string{"node-4"},
+		},
+		{
+			name: "anti-affinity skips the nodes of the other replicas",
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("vm-1-rootdisk", "hostpath", "30Gi", "", map[string]string{claimgroups.AnnAntiAffinity: "vm"}),
+				newClaim("vm-1-datadisk", "hostpath", "20Gi", "", nil),
+				newClaim("vm-0-rootdisk", "hostpath", "30Gi", "", map[string]string{claimgroups.AnnAntiAffinity: "vm", "kubevirt.io/provisionOnNode": "node-1"}),
+			},
+			want: []string{"node-4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExtender(t, StrategyMostFree, tt.claims...)
+			result := &ExtenderFilterResult{}
+			post(t, e.Handler(), "/filter", "filter-args.json", result)
+			var nodes []string
+			for _, node := range result.Nodes.Items {
+				nodes = append(nodes, node.Name)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Filter() nodes = %v, want %v, failed %v", nodes, tt.want, result.FailedNodes)
+			}
+		})
+	}
+}
+
+func Test_FilterAdmission(t *testing.T) {
+	inMaintenance, err := maintenance.ParseConfig("", "example.com/maintenance=true")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		config maintenance.Config
+		pool   string
+		labels map[string]map[string]string
+		want   []string
+	}{
+		{
+			name:   "skips the node in maintenance",
+			config: inMaintenance,
+			labels: map[string]map[string]string{"node-1": {"example.com/maintenance": "true"}},
+			want:   []string{"node-4"},
+		},
+		{
+			name:   "skips the nodes without the pool of the claims",
+			pool:   "fast",
+			labels: map[string]map[string]string{"node-4": {noderesources.PoolLabel("fast"): "true"}},
+			want:   []string{"node-4"},
+		},
+		{
+			name: "places claims of the default pool on nodes of every pool",
+			pool: noderesources.DefaultPool,
+			labels: map[string]map[string]string{
+				"node-1": {noderesources.PoolLabel("fast"): "true"},
+				"node-4": {noderesources.PoolLabel(noderesources.DefaultPool): "true"},
+			},
+			want: []string{"node-1", "node-4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var annotations map[string]string
+			if tt.pool != "" {
+				annotations = map[string]string{nodevolumes.AnnPool: tt.pool}
+			}
+			e := newAdmittingExtender(t, StrategyMostFree, tt.config,
+				newClaim("vm-1-rootdisk", "hostpath", "30Gi", "", annotations),
+				newClaim("vm-1-datadisk", "hostpath", "20Gi", "", annotations))
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "filter-args.json"))
+			if err != nil {
+				t.Fatalf("failed to read filter-args.json: %v", err)
+			}
+			args := &ExtenderArgs{}
+			if err := json.Unmarshal(data, args); err != nil {
+				t.Fatalf("failed to decode filter-args.json: %v", err)
+			}
+			for i := range args.Nodes.Items {
+				for key, value := range tt.labels[args.Nodes.Items[i].Name] {
+					args.Nodes.Items[i].Labels[key] = value
+				}
+			}
+			result := e.Filter(args)
+			var nodes []string
+			for _, node := range result.Nodes.Items {
+				nodes = append(nodes, node.Name)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Filter() nodes = %v, want %v, failed %v", nodes, tt.want, result.FailedNodes)
+			}
+		})
+	}
+}
+
+func Test_Prioritize(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     HostPriorityList
+	}{
+		{
+			name:     "most free prefers the emptiest pool",
+			strategy: StrategyMostFree,
+			want:     HostPriorityList{{Host: "node-1", Score: 10}, {Host: "node-3", Score: 0}, {Host: "node-4", Score: 6}},
+		},
+		{
+			name:     "bin pack prefers the fullest pool",
+			strategy: StrategyBinPack,
+			want:     HostPriorityList{{Host: "node-1", Score: 0}, {Host: "node-3", Score: 0}, {Host: "node-4", Score: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExtender(t, tt.strategy)
+			var got HostPriorityList
+			post(t, e.Handler(), "/prioritize", "prioritize-args.json", &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prioritize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_NewUnknownStrategy(t *testing.T) {
+	if _, err := New(testProvisioner, "random", nil, nil, nil, nil, nil, nil, nil); err == nil {
+		t.Errorf("New() should reject unknown strategies")
+	}
+}
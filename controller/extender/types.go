@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extender
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// The types below are the wire format of the scheduler extender protocol, as
+// defined by k8s.io/kube-scheduler/extender/v1.
+
+// MaxPriority is the highest score an extender may give a node.
+const MaxPriority int64 = 10
+
+// ExtenderArgs is the body of filter and prioritize requests.
+type ExtenderArgs struct {
+	// Pod being scheduled
+	Pod *v1.Pod `json:"pod"`
+	// List of candidate nodes where the pod can be scheduled; to be populated
+	// only if the extender is not nodeCacheCapable
+	Nodes *v1.NodeList `json:"nodes,omitempty"`
+	// List of candidate node names where the pod can be scheduled; to be
+	// populated only if the extender is nodeCacheCapable
+	NodeNames *[]string `json:"nodenames,omitempty"`
+}
+
+// FailedNodesMap represents the filtered out nodes, with node names and failure messages.
+type FailedNodesMap map[string]string
+
+// ExtenderFilterResult is the response of a filter request.
+type ExtenderFilterResult struct {
+	// Filtered set of nodes where the pod can be scheduled; to be populated
+	// only if the extender is not nodeCacheCapable
+	Nodes *v1.NodeList `json:"nodes,omitempty"`
+	// Filtered set of nodes where the pod can be scheduled; to be populated
+	// only if the extender is nodeCacheCapable
+	NodeNames *[]string `json:"nodenames,omitempty"`
+	// Filtered out nodes where the pod can't be scheduled and the failure messages
+	FailedNodes FailedNodesMap `json:"failedNodes,omitempty"`
+	// Error message indicating failure
+	Error string `json:"error,omitempty"`
+}
+
+// HostPriority represents the priority of scheduling to a particular host, higher priority is better.
+type HostPriority struct {
+	// Name of the host
+	Host string `json:"host"`
+	// Score associated with the host
+	Score int64 `json:"score"`
+}
+
+// HostPriorityList declares a []HostPriority type, the response of a prioritize request.
+type HostPriorityList []HostPriority
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"time"
+)
+
+const (
+	// MinSamples is the least samples a trend is fitted to.
+	MinSamples = 3
+	// Horizon is how far ahead a pool is projected to fill up, later dates
+	// are not reported.
+	Horizon = 10 * 365 * 24 * time.Hour
+)
+
+// Forecast is the growth of a pool fitted to its history.
+type Forecast struct {
+	// Samples is the number of samples the trend is fitted to.
+	Samples int
+	// Since is when the oldest of them was taken.
+	Since time.Time
+	// UsedPerSecond is how many bytes the used space grows by per second, it
+	// is negative when it shrinks.
+	UsedPerSecond float64
+	// RequestedPerSecond is how many bytes the requests grow by per second.
+	RequestedPerSecond float64
+	// FullAt is when the used space reaches the size of the pool, nil when it
+	// does not grow or not within the Horizon.
+	FullAt *time.Time
+	// RequestsFullAt is when the requests reach the size of the pool, nil when
+	// they do not grow or not within the Horizon.
+	RequestsFullAt *time.Time
+}
+
+// Fit fits a linear trend by least squares to the samples of a pool of the
+// given size, and projects from the last sample when the pool fills up. It
+// returns false when there are fewer than MinSamples samples or they were all
+// taken at the same time.
+func Fit(samples []Sample, total int64) (Forecast, bool) {
+	if len(samples) < MinSamples {
+		return Forecast{}, false
+	}
+	usedPerSecond, ok := slope(samples, func(s Sample) int64 { return s.Used })
+	if !ok {
+		return Forecast{}, false
+	}
+	requestedPerSecond, _ := slope(samples, func(s Sample) int64 { return s.Requested })
+	last := samples[len(samples)-1]
+	return Forecast{
+		Samples:            len(samples),
+		Since:              samples[0].Time,
+		UsedPerSecond:      usedPerSecond,
+		RequestedPerSecond: requestedPerSecond,
+		FullAt:             fullAt(last.Time, last.Used, total, usedPerSecond),
+		RequestsFullAt:     fullAt(last.Time, last.Requested, total, requestedPerSecond),
+	}, true
+}
+
+// slope returns the slope of the least squares line through the values of
+// the samples over time, in units per second.
+func slope(samples []Sample, value func(Sample) int64) (float64, bool) {
+	start := samples[0].Time
+	var meanX, meanY float64
+	for _, s := range samples {
+		meanX += s.Time.Sub(start).Seconds()
+		meanY += float64(value(s))
+	}
+	n := float64(len(samples))
+	meanX /= n
+	meanY /= n
+	var covariance, variance float64
+	for _, s := range samples {
+		dx := s.Time.Sub(start).Seconds() - meanX
+		covariance += dx * (float64(value(s)) - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return covariance / variance, true
+}
+
+// fullAt returns when value, growing by perSecond from at, reaches total.
+func fullAt(at time.Time, value, total int64, perSecond float64) *time.Time {
+	if value >= total {
+		return &at
+	}
+	if perSecond <= 0 {
+		return nil
+	}
+	seconds := float64(total-value) / perSecond
+	if seconds > Horizon.Seconds() {
+		return nil
+	}
+	full := at.Add(time.Duration(seconds * float64(time.Second)))
+	return &full
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+const GiB int64 = 1024 * 1024 * 1024
+
+func samplesOf(used, requested []int64) []Sample {
+	start := time.Unix(1600000000, 0).UTC()
+	var samples []Sample
+	for i := range used {
+		samples = append(samples, Sample{Time: start.Add(time.Duration(i) * time.Hour), Used: used[i], Requested: requested[i]})
+	}
+	return samples
+}
+
+func Test_Fit(t *testing.T) {
+	start := time.Unix(1600000000, 0).UTC()
+	tests := []struct {
+		name               string
+		samples            []Sample
+		total              int64
+		wantOK             bool
+		wantUsedPerHour    int64
+		wantRequestedPerHr int64
+		wantFullAt         *time.Time
+		wantRequestsFullAt *time.Time
+	}{
+		{
+			name:    "too few samples",
+			samples: samplesOf([]int64{GiB, 2 * GiB}, []int64{0, 0}),
+			total:   10 * GiB,
+		},
+		{
+			name:    "samples at the same time",
+			samples: []Sample{{Time: start, Used: GiB}, {Time: start, Used: 2 * GiB}, {Time: start, Used: 3 * GiB}},
+			total:   10 * GiB,
+		},
+		{
+			name:               "steady growth",
+			samples:            samplesOf([]int64{GiB, 2 * GiB, 3 * GiB, 4 * GiB}, []int64{5 * GiB, 5 * GiB, 6 * GiB, 6 * GiB}),
+			total:              10 * GiB,
+			wantOK:             true,
+			wantUsedPerHour:    GiB,
+			wantRequestedPerHr: 2 * GiB / 5,
+			// 6GiB left at 1GiB per hour from the last sample at 3h
+			wantFullAt:         timePtr(start.Add(9 * time.Hour)),
+			wantRequestsFullAt: timePtr(start.Add(3*time.Hour + 10*time.Hour)),
+		},
+		{
+			name:            "shrinking",
+			samples:         samplesOf([]int64{4 * GiB, 3 * GiB, 2 * GiB}, []int64{GiB, GiB, GiB}),
+			total:           10 * GiB,
+			wantOK:          true,
+			wantUsedPerHour: -GiB,
+		},
+		{
+			name:               "full already",
+			samples:            samplesOf([]int64{8 * GiB, 9 * GiB, 10 * GiB}, []int64{12 * GiB, 12 * GiB, 12 * GiB}),
+			total:              10 * GiB,
+			wantOK:             true,
+			wantUsedPerHour:    GiB,
+			wantFullAt:         timePtr(start.Add(2 * time.Hour)),
+			wantRequestsFullAt: timePtr(start.Add(2 * time.Hour)),
+		},
+		{
+			name:            "beyond the horizon",
+			samples:         samplesOf([]int64{GiB, GiB + 1, GiB + 2}, []int64{0, 0, 0}),
+			total:           10 * GiB,
+			wantOK:          true,
+			wantUsedPerHour: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Fit(tt.samples, tt.total)
+			if ok != tt.wantOK {
+				t.Fatalf("Fit() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.Samples != len(tt.samples) || !got.Since.Equal(tt.samples[0].Time) {
+				t.Errorf("Fit() samples, since = %d, %v", got.Samples, got.Since)
+			}
+			if perHour := int64(got.UsedPerSecond * 3600); perHour != tt.wantUsedPerHour {
+				t.Errorf("Fit() used per hour = %d, want %d", perHour, tt.wantUsedPerHour)
+			}
+			if perHour := int64(got.RequestedPerSecond * 3600); perHour != tt.wantRequestedPerHr {
+				t.Errorf("Fit() requested per hour = %d, want %d", perHour, tt.wantRequestedPerHr)
+			}
+			if !sameTime(got.FullAt, tt.wantFullAt) {
+				t.Errorf("Fit() fullAt = %v, want %v", got.FullAt, tt.wantFullAt)
+			}
+			if !sameTime(got.RequestsFullAt, tt.wantRequestsFullAt) {
+				t.Errorf("Fit() requestsFullAt = %v, want %v", got.RequestsFullAt, tt.wantRequestsFullAt)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// sameTime compares to the second, the projection is not exact.
+func sameTime(got, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return got.Sub(*want).Round(time.Second) == 0
+}
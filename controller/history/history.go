@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// File is the file the history is kept in, below the directory of the
+	// volumes. Being hidden, it is not taken for a volume or an orphan.
+	File = ".history.json"
+	// DefaultInterval is the least time between two samples of a pool.
+	DefaultInterval = 5 * time.Minute
+	// DefaultMaxSamples is the most samples kept per pool, a week of samples
+	// at the default interval.
+	DefaultMaxSamples = 7 * 24 * 12
+)
+
+// Sample is the usage of a pool at a point in time.
+type Sample struct {
+	Time time.Time `json:"time"`
+	// Used is the space of the filesystem of the pool that is not available.
+	Used int64 `json:"used"`
+	// Requested is the sum of the requests of the volumes on the pool.
+	Requested int64 `json:"requested"`
+}
+
+// History is the time series of the usage of the pools of a node, persisted
+// to a file so it survives restarts of the provisioner.
+type History struct {
+	file       string
+	interval   time.Duration
+	maxSamples int
+
+	mutex sync.Mutex
+	pools map[string][]Sample
+}
+
+type content struct {
+	Pools map[string][]Sample `json:"pools"`
+}
+
+// New returns the History kept in the pool at pvDir.
+func New(pvDir string) *History {
+	return &History{
+		file:       filepath.Join(pvDir, File),
+		interval:   DefaultInterval,
+		maxSamples: DefaultMaxSamples,
+		pools:      map[string][]Sample{},
+	}
+}
+
+// Load reads the history persisted by a previous run. A missing file is an
+// empty history.
+func (h *History) Load() error {
+	data, err := ioutil.ReadFile(h.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var c content
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.pools = map[string][]Sample{}
+	for pool, samples := range c.Pools {
+		h.pools[pool] = h.trim(samples)
+	}
+	return nil
+}
+
+// Record adds the sample to the history of the pool and persists the history.
+// The sample is dropped when the last one of the pool is more recent than the
+// interval, and it returns whether it was recorded. A sample older than the
+// last one, after the clock was set back, starts the history over.
+func (h *History) Record(pool string, sample Sample) (bool, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	samples := h.pools[pool]
+	if n := len(samples); n > 0 {
+		last := samples[n-1].Time
+		if sample.Time.Before(last) {
+			samples = nil
+		} else if sample.Time.Sub(last) < h.interval {
+			return false, nil
+		}
+	}
+	h.pools[pool] = h.trim(append(samples, sample))
+	return true, h.save()
+}
+
+// Samples returns the samples of the pool, oldest first.
+func (h *History) Samples(pool string) []Sample {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]Sample(nil), h.pools[pool]...)
+}
+
+// Forecast returns the forecast of the pool of the given size, see Fit.
+func (h *History) Forecast(pool string, total int64) (Forecast, bool) {
+	return Fit(h.Samples(pool), total)
+}
+
+// trim drops the oldest samples beyond the most kept.
+func (h *History) trim(samples []Sample) []Sample {
+	if len(samples) > h.maxSamples {
+		samples = append([]Sample(nil), samples[len(samples)-h.maxSamples:]...)
+	}
+	return samples
+}
+
+// save writes the history to a temporary file renamed over the file, so a
+// crash never leaves a truncated history behind.
+func (h *History) save() error {
+	data, err := json.Marshal(content{Pools: h.pools})
+	if err != nil {
+		return err
+	}
+	tmp := h.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.file)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_HistoryRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h := New(dir)
+	h.maxSamples = 3
+	start := time.Unix(1600000000, 0).UTC()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	steps := []struct {
+		name        string
+		pool        string
+		time        time.Time
+		want        bool
+		wantSamples int
+	}{
+		{name: "first sample", pool: "default", time: at(0), want: true, wantSamples: 1},
+		{name: "within the interval", pool: "default", time: at(time.Minute), want: false, wantSamples: 1},
+		{name: "other pool", pool: "fast", time: at(time.Minute), want: true, wantSamples: 1},
+		{name: "after the interval", pool: "default", time: at(DefaultInterval), want: true, wantSamples: 2},
+		{name: "third sample", pool: "default", time: at(2 * DefaultInterval), want: true, wantSamples: 3},
+		{name: "oldest sample dropped", pool: "default", time: at(3 * DefaultInterval), want: true, wantSamples: 3},
+		{name: "clock set back", pool: "default", time: at(time.Minute), want: true, wantSamples: 1},
+	}
+	for _, step := range steps {
+		got, err := h.Record(step.pool, Sample{Time: step.time, Used: 1, Requested: 2})
+		if err != nil {
+			t.Fatalf("%s: Record() error = %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: Record() = %v, want %v", step.name, got, step.want)
+		}
+		if samples := h.Samples(step.pool); len(samples) != step.wantSamples {
+			t.Errorf("%s: Samples() = %v, want %d samples", step.name, samples, step.wantSamples)
+		}
+	}
+
+	loaded := New(dir)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for _, pool := range []string{"default", "fast"} {
+		want, got := h.Samples(pool), loaded.Samples(pool)
+		if len(got) != len(want) || !got[0].Time.Equal(want[0].Time) || got[0].Used != 1 || got[0].Requested != 2 {
+			t.Errorf("Load() samples of %s = %v, want %v", pool, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, File+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("the temporary file should be renamed, stat error = %v", err)
+	}
+}
+
+func Test_HistoryLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := New(dir)
+	if err := h.Load(); err != nil {
+		t.Errorf("Load() without file error = %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, File), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Load(); err == nil {
+		t.Errorf("Load() of a corrupt file should fail")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AnnMaintenance on a node stops new volumes from being provisioned on it,
+// volumes already on the node keep working. Any value but "false" enables it.
+const AnnMaintenance = "hostpath.kubevirt.io/maintenance"
+
+// Config lists the taints and labels that put a node in maintenance, besides
+// being cordoned or carrying AnnMaintenance.
+type Config struct {
+	// Taints are taint keys.
+	Taints []string
+	// Selector matches the labels of nodes in maintenance, nil matches no node.
+	Selector labels.Selector
+}
+
+// ParseConfig parses a comma separated list of taint keys and a label selector,
+// both may be empty.
+func ParseConfig(taints, selector string) (Config, error) {
+	var config Config
+	for _, taint := range strings.Split(taints, ",") {
+		if taint = strings.TrimSpace(taint); taint != "" {
+			config.Taints = append(config.Taints, taint)
+		}
+	}
+	if selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return config, fmt.Errorf("invalid maintenance label selector %q: %v", selector, err)
+		}
+		config.Selector = s
+	}
+	return config, nil
+}
+
+// Reason returns why the node does not take new volumes, or "" when it does.
+func (c Config) Reason(node *v1.Node) string {
+	if node == nil {
+		return ""
+	}
+	if value, ok := node.Annotations[AnnMaintenance]; ok && value != "false" {
+		return fmt.Sprintf("node has the %s annotation", AnnMaintenance)
+	}
+	if node.Spec.Unschedulable {
+		return "node is cordoned"
+	}
+	for _, key := range c.Taints {
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == key {
+				return fmt.Sprintf("node has the %s taint", key)
+			}
+		}
+	}
+	if c.Selector != nil && c.Selector.Matches(labels.Set(node.Labels)) {
+		return fmt.Sprintf("node labels match %s", c.Selector.String())
+	}
+	return ""
+}
+
+// Error is returned when a claim is refused because its node is in maintenance.
+type Error struct {
+	Node   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("node %s is in maintenance: %s", e.Node, e.Reason)
+}
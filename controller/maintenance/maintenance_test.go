@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_Reason(t *testing.T) {
+	config, err := ParseConfig("node.kubernetes.io/disk-replacement, example.com/drain", "hostpath=off")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		node   *v1.Node
+		config Config
+		want   bool
+	}{
+		{
+			name:   "plain node takes volumes",
+			node:   &v1.Node{},
+			config: config,
+		},
+		{
+			name:   "maintenance annotation",
+			node:   &v1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnMaintenance: "true"}}},
+			config: config,
+			want:   true,
+		},
+		{
+			name:   "maintenance annotation turned off",
+			node:   &v1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnMaintenance: "false"}}},
+			config: config,
+		},
+		{
+			name:   "cordoned node",
+			node:   &v1.Node{Spec: v1.NodeSpec{Unschedulable: true}},
+			config: Config{},
+			want:   true,
+		},
+		{
+			name:   "configured taint",
+			node:   &v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "example.com/drain", Effect: v1.TaintEffectNoSchedule}}}},
+			config: config,
+			want:   true,
+		},
+		{
+			name:   "other taint",
+			node:   &v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "example.com/gpu", Effect: v1.TaintEffectNoSchedule}}}},
+			config: config,
+		},
+		{
+			name:   "configured label",
+			node:   &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"hostpath": "off"}}},
+			config: config,
+			want:   true,
+		},
+		{
+			name:   "label without selector",
+			node:   &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"hostpath": "off"}}},
+			config: Config{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Reason(tt.node); (got != "") != tt.want {
+				t.Errorf("Reason() = %q, want maintenance %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseConfigInvalidSelector(t *testing.T) {
+	if _, err := ParseConfig("", "a in b"); err == nil {
+		t.Errorf("ParseConfig() should reject invalid selectors")
+	}
+}
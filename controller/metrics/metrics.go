@@ -23,6 +23,10 @@ import (
 const (
 	// ControllerSubsystem is prometheus subsystem name.
 	ControllerSubsystem = "controller"
+	// CapacitySubsystem is prometheus subsystem name for hostpath pool capacity.
+	CapacitySubsystem = "hostpath_capacity"
+	// WarmPoolSubsystem is prometheus subsystem name for the warm pool of pre-created volumes.
+	WarmPoolSubsystem = "hostpath_warm_pool"
 )
 
 var (
@@ -82,4 +86,103 @@ var (
 		},
 		[]string{"class"},
 	)
+	// CapacityTotalBytes is used to collect the size of the filesystem backing the pool.
+	CapacityTotalBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "total_bytes",
+			Help:      "Size of the filesystem backing the hostpath pool. Broken down by node.",
+		},
+		[]string{"node"},
+	)
+	// CapacityAvailableBytes is used to collect the space currently available on the pool.
+	CapacityAvailableBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "available_bytes",
+			Help:      "Space currently available to unprivileged writers on the hostpath pool. Broken down by node.",
+		},
+		[]string{"node"},
+	)
+	// CapacityRequestedBytes is used to collect the sum of the requests of the volumes on the pool.
+	CapacityRequestedBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "requested_bytes",
+			Help:      "Sum of the requests of the persistent volumes on the hostpath pool. Broken down by node.",
+		},
+		[]string{"node"},
+	)
+	// CapacityAllocatableBytes is used to collect what can still be handed out to new volumes.
+	CapacityAllocatableBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "allocatable_bytes",
+			Help:      "Capacity of the hostpath pool that can still be provisioned according to the capacity policy. Broken down by node.",
+		},
+		[]string{"node"},
+	)
+	// CapacityUsedGrowthBytesPerDay is used to collect the growth of the used space of the pool fitted to its history.
+	CapacityUsedGrowthBytesPerDay = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "used_growth_bytes_per_day",
+			Help:      "Growth per day of the used space of the hostpath pool fitted to its history, negative when it shrinks. Broken down by node and pool.",
+		},
+		[]string{"node", "pool"},
+	)
+	// CapacityRequestedGrowthBytesPerDay is used to collect the growth of the requests on the pool fitted to its history.
+	CapacityRequestedGrowthBytesPerDay = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "requested_growth_bytes_per_day",
+			Help:      "Growth per day of the requests of the persistent volumes on the hostpath pool fitted to its history. Broken down by node and pool.",
+		},
+		[]string{"node", "pool"},
+	)
+	// CapacityFullTimestampSeconds is used to collect when the pool is projected to fill up.
+	CapacityFullTimestampSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "full_timestamp_seconds",
+			Help:      "Unix time the hostpath pool is projected to have no space left at its current growth, absent when it does not fill up. Broken down by node and pool.",
+		},
+		[]string{"node", "pool"},
+	)
+	// CapacityRequestsFullTimestampSeconds is used to collect when the requests on the pool are projected to reach its size.
+	CapacityRequestsFullTimestampSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: CapacitySubsystem,
+			Name:      "requests_full_timestamp_seconds",
+			Help:      "Unix time the requests on the hostpath pool are projected to reach its size at their current growth, absent when they do not. Broken down by node and pool.",
+		},
+		[]string{"node", "pool"},
+	)
+	// WarmPoolSize is used to collect the number of pre-created volumes waiting in the warm pool.
+	WarmPoolSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: WarmPoolSubsystem,
+			Name:      "size",
+			Help:      "Number of pre-created directories or persistent volumes in the warm pool. Broken down by node, storage class name and kind.",
+		},
+		[]string{"node", "class", "kind"},
+	)
+	// WarmPoolHitsTotal is used to collect accumulated count of claims served from the warm pool.
+	WarmPoolHitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: WarmPoolSubsystem,
+			Name:      "hits_total",
+			Help:      "Total number of claims served from the warm pool. Broken down by node, storage class name and kind.",
+		},
+		[]string{"node", "class", "kind"},
+	)
+	// WarmPoolMissesTotal is used to collect accumulated count of claims provisioned cold although a warm pool is configured.
+	WarmPoolMissesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: WarmPoolSubsystem,
+			Name:      "misses_total",
+			Help:      "Total number of claims provisioned cold because the warm pool was empty. Broken down by node and storage class name.",
+		},
+		[]string{"node", "class"},
+	)
 )
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	listers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+)
+
+// MaintenanceReason returns why the node, whose DiskMonitor has spec, takes no
+// new volumes at all, or "" when it does. node may be nil.
+func MaintenanceReason(config maintenance.Config, node *v1.Node, spec *v2.DiskMonitorSpec) string {
+	if spec.Maintenance {
+		return "DiskMonitor requests maintenance"
+	}
+	return config.Reason(node)
+}
+
+// AdmissionReason returns why the node, whose DiskMonitor has spec, takes no
+// new volume of the storage class while it holds volumes volumes, or "" when
+// it does. It is what the node agent checks before provisioning a claim.
+func AdmissionReason(config maintenance.Config, node *v1.Node, spec *v2.DiskMonitorSpec, class string, volumes int) string {
+	if reason := MaintenanceReason(config, node, spec); reason != "" {
+		return reason
+	}
+	if !StorageClassAllowed(spec, class) {
+		return fmt.Sprintf("DiskMonitor does not allow storage class %q", class)
+	}
+	if spec.MaxVolumes != nil && volumes >= int(*spec.MaxVolumes) {
+		return fmt.Sprintf("node holds %d volumes, its DiskMonitor allows %d", volumes, *spec.MaxVolumes)
+	}
+	return ""
+}
+
+// NodeAdmission returns AdmissionReason for the nodes, reading their
+// DiskMonitors from lister in namespace. Nodes without a DiskMonitor are only
+// checked against config.
+func NodeAdmission(lister listers.DiskMonitorLister, namespace string, config maintenance.Config) func(node *v1.Node, class string, volumes int) string {
+	return func(node *v1.Node, class string, volumes int) string {
+		spec := &v2.DiskMonitorSpec{}
+		if diskMonitor, err := lister.DiskMonitors(namespace).Get(node.Name); err == nil {
+			spec = &diskMonitor.Spec
+		}
+		return AdmissionReason(config, node, spec, class, volumes)
+	}
+}
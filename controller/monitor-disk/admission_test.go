@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kubevirt.io/hostpath-provisioner/controller/maintenance"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	diskmonitorfake "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned/fake"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+)
+
+func Test_NodeAdmission(t *testing.T) {
+	two := int32(2)
+	config := maintenance.Config{Taints: []string{"example.com/drain"}}
+	factory := diskmonitorinformers.NewSharedInformerFactory(diskmonitorfake.NewSimpleClientset(), 0)
+	diskMonitors := factory.DiskMonitor().V2().DiskMonitors().Informer().GetIndexer()
+	for name, spec := range map[string]v2.DiskMonitorSpec{
+		"maintenance": {Maintenance: true},
+		"fast-only":   {AllowedStorageClasses: []string{"fast"}},
+		"two-volumes": {MaxVolumes: &two},
+	} {
+		diskMonitors.Add(&v2.DiskMonitor{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "hostpath"}, Spec: spec})
+	}
+	admit := NodeAdmission(factory.DiskMonitor().V2().DiskMonitors().Lister(), "hostpath", config)
+
+	tests := []struct {
+		name    string
+		node    *v1.Node
+		class   string
+		volumes int
+		want    bool
+	}{
+		{
+			name: "node without DiskMonitor",
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "new"}},
+			want: true,
+		},
+		{
+			name: "cordoned node",
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "new"}, Spec: v1.NodeSpec{Unschedulable: true}},
+		},
+		{
+			name: "node with a maintenance taint",
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "new"}, Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "example.com/drain"}}}},
+		},
+		{
+			name: "node with the maintenance annotation",
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "new", Annotations: map[string]string{maintenance.AnnMaintenance: "true"}}},
+		},
+		{
+			name: "DiskMonitor in maintenance",
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "maintenance"}},
+		},
+		{
+			name:  "allowed storage class",
+			node:  &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "fast-only"}},
+			class: "fast",
+			want:  true,
+		},
+		{
+			name:  "storage class not allowed",
+			node:  &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "fast-only"}},
+			class: "standard",
+		},
+		{
+			name:    "below max volumes",
+			node:    &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "two-volumes"}},
+			volumes: 1,
+			want:    true,
+		},
+		{
+			name:    "at max volumes",
+			node:    &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "two-volumes"}},
+			volumes: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := admit(tt.node, tt.class, tt.volumes)
+			if got := reason == ""; got != tt.want {
+				t.Errorf("NodeAdmission() = %q, want admitted %v", reason, tt.want)
+			}
+		})
+	}
+}
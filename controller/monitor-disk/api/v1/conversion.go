@@ -0,0 +1,194 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+// Keys of the detail recorded in DiskInfo for every volume.
+const (
+	DetailPVName  = "pvName"
+	DetailRequire = "require"
+)
+
+// Keys of the detail recorded in DiskInfo for the fields of a v2 volume v1
+// has no room for. They are only set when the volume has the field.
+const (
+	// DetailClaim is the claim of the volume, as namespace/name.
+	DetailClaim        = "claim"
+	DetailPool         = "pool"
+	DetailUsed         = "used"
+	DetailCreationTime = "creationTime"
+	DetailPhase        = "phase"
+)
+
+// AnnV2Fields holds, as JSON, the fields of the v2 version v1 has no room
+// for, so that a v1 client writing a DiskMonitor back does not drop them.
+// The fields of the volumes are kept in their DiskInfo details instead, so
+// the annotation does not grow with the number of volumes.
+const AnnV2Fields = "diskmonitor.domain/v2-fields"
+
+// v2Fields are the fields of the v2 version v1 has no room for, but for
+// those of the volumes.
+type v2Fields struct {
+	Spec               v2.DiskMonitorSpec `json:"spec,omitempty"`
+	Pool               string             `json:"pool,omitempty"`
+	Pools              []v2.PoolStatus    `json:"pools,omitempty"`
+	Forecast           *v2.PoolForecast   `json:"forecast,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	LastSyncTime       *metav1.Time       `json:"lastSyncTime,omitempty"`
+}
+
+// ConvertTo converts this DiskMonitor to the v2 hub version. Every DiskInfo
+// entry becomes a volume named after its pvName and requesting its require,
+// with the other fields of the volume its details hold. The fields kept in
+// AnnV2Fields are restored.
+func (src *DiskMonitor) ConvertTo(dst *v2.DiskMonitor) error {
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	var fields v2Fields
+	if value, ok := dst.Annotations[AnnV2Fields]; ok {
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return fmt.Errorf("invalid %s annotation: %v", AnnV2Fields, err)
+		}
+		delete(dst.Annotations, AnnV2Fields)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+	dst.Spec = fields.Spec
+	dst.Status = v2.DiskMonitorStatus{
+		Total:              src.Status.Total,
+		Required:           src.Status.Required,
+		Free:               src.Status.Free,
+		Allocatable:        src.Status.Allocatable,
+		Pool:               fields.Pool,
+		Pools:              fields.Pools,
+		Forecast:           fields.Forecast,
+		ObservedGeneration: fields.ObservedGeneration,
+		LastSyncTime:       fields.LastSyncTime,
+	}
+	for path, detail := range src.Status.DiskInfo {
+		volume := v2.Volume{
+			PersistentVolume: detail.Detail[DetailPVName],
+			Path:             string(path),
+			Pool:             detail.Detail[DetailPool],
+			Phase:            v2.VolumePhase(detail.Detail[DetailPhase]),
+		}
+		if require, err := resource.ParseQuantity(detail.Detail[DetailRequire]); err == nil {
+			volume.Requested = require
+		}
+		if parts := strings.SplitN(detail.Detail[DetailClaim], "/", 2); len(parts) == 2 {
+			volume.Claim = &v2.ClaimReference{Namespace: parts[0], Name: parts[1]}
+		}
+		if used, err := resource.ParseQuantity(detail.Detail[DetailUsed]); err == nil {
+			volume.Used = &used
+		}
+		if created, err := time.Parse(time.RFC3339, detail.Detail[DetailCreationTime]); err == nil {
+			creationTime := metav1.NewTime(created.Local())
+			volume.CreationTime = &creationTime
+		}
+		dst.Status.Volumes = append(dst.Status.Volumes, volume)
+	}
+	sort.Slice(dst.Status.Volumes, func(i, j int) bool {
+		return dst.Status.Volumes[i].Path < dst.Status.Volumes[j].Path
+	})
+	dst.Status.VolumeCount = int32(len(dst.Status.Volumes))
+	for _, condition := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v2.DiskMonitorCondition{
+			Type:               v2.DiskMonitorConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	return nil
+}
+
+// ConvertFrom converts the v2 hub version to this DiskMonitor. The fields v1
+// has no room for, such as the spec and the pools, are kept in AnnV2Fields,
+// those of the volumes, such as their claims and usage, in their details.
+func (dst *DiskMonitor) ConvertFrom(src *v2.DiskMonitor) error {
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	fields := v2Fields{
+		Spec:               src.Spec,
+		Pool:               src.Status.Pool,
+		Pools:              src.Status.Pools,
+		Forecast:           src.Status.Forecast,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		LastSyncTime:       src.Status.LastSyncTime,
+	}
+	if !reflect.DeepEqual(fields, v2Fields{}) {
+		value, err := json.Marshal(&fields)
+		if err != nil {
+			return err
+		}
+		metav1.SetMetaDataAnnotation(&dst.ObjectMeta, AnnV2Fields, string(value))
+	}
+	dst.Status = DiskMonitorStatus{
+		Total:       src.Status.Total,
+		Required:    src.Status.Required,
+		Free:        src.Status.Free,
+		Allocatable: src.Status.Allocatable,
+	}
+	if len(src.Status.Volumes) > 0 {
+		dst.Status.DiskInfo = map[PVPath]DiskDetail{}
+	}
+	for _, volume := range src.Status.Volumes {
+		detail := Detail{
+			DetailPVName:  volume.PersistentVolume,
+			DetailRequire: volume.Requested.String(),
+		}
+		if volume.Claim != nil {
+			detail[DetailClaim] = volume.Claim.Namespace + "/" + volume.Claim.Name
+		}
+		if volume.Pool != "" {
+			detail[DetailPool] = volume.Pool
+		}
+		if volume.Used != nil {
+			detail[DetailUsed] = volume.Used.String()
+		}
+		if volume.CreationTime != nil {
+			detail[DetailCreationTime] = volume.CreationTime.UTC().Format(time.RFC3339)
+		}
+		if volume.Phase != "" {
+			detail[DetailPhase] = string(volume.Phase)
+		}
+		dst.Status.DiskInfo[PVPath(volume.Path)] = DiskDetail{Detail: detail}
+	}
+	for _, condition := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, DiskMonitorCondition{
+			Type:               DiskMonitorConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	return nil
+}
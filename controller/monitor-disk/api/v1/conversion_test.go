@@ -0,0 +1,195 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+func quantity(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func Test_Conversion(t *testing.T) {
+	meta := metav1.ObjectMeta{Name: "node1", Namespace: "hostpath", ResourceVersion: "7"}
+	tests := []struct {
+		name string
+		v1   DiskMonitor
+		v2   v2.DiskMonitor
+	}{
+		{
+			name: "empty",
+			v1:   DiskMonitor{ObjectMeta: meta},
+			v2:   v2.DiskMonitor{ObjectMeta: meta},
+		},
+		{
+			name: "volumes sorted by path",
+			v1: DiskMonitor{
+				ObjectMeta: meta,
+				Status: DiskMonitorStatus{
+					Total:    quantity("100Gi"),
+					Required: quantity("15Gi"),
+					DiskInfo: map[PVPath]DiskDetail{
+						"/pv/b": {Detail: Detail{DetailPVName: "b", DetailRequire: "10Gi"}},
+						"/pv/a": {Detail: Detail{DetailPVName: "a", DetailRequire: "5Gi"}},
+					},
+					Conditions: []DiskMonitorCondition{{Type: DiskMonitorMaintenance, Status: "True", Reason: "Cordoned"}},
+				},
+			},
+			v2: v2.DiskMonitor{
+				ObjectMeta: meta,
+				Status: v2.DiskMonitorStatus{
+					Total:    quantity("100Gi"),
+					Required: quantity("15Gi"),
+					Volumes: []v2.Volume{
+						{PersistentVolume: "a", Path: "/pv/a", Requested: resource.MustParse("5Gi")},
+						{PersistentVolume: "b", Path: "/pv/b", Requested: resource.MustParse("10Gi")},
+					},
+					VolumeCount: 2,
+					Conditions:  []v2.DiskMonitorCondition{{Type: v2.DiskMonitorMaintenance, Status: "True", Reason: "Cordoned"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hub v2.DiskMonitor
+			if err := tt.v1.ConvertTo(&hub); err != nil {
+				t.Fatalf("ConvertTo() error = %v", err)
+			}
+			if !reflect.DeepEqual(hub, tt.v2) {
+				t.Errorf("ConvertTo() = %+v, want %+v", hub, tt.v2)
+			}
+			var spoke DiskMonitor
+			if err := spoke.ConvertFrom(&hub); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			if !reflect.DeepEqual(spoke, tt.v1) {
+				t.Errorf("ConvertFrom() = %+v, want %+v", spoke, tt.v1)
+			}
+		})
+	}
+}
+
+func Test_ConversionRoundTrip(t *testing.T) {
+	maxVolumes := int32(10)
+	created := metav1.NewTime(time.Unix(1600000000, 0))
+	hub := v2.DiskMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "hostpath", Annotations: map[string]string{"example.com/owner": "ops"}},
+		Spec:       v2.DiskMonitorSpec{Maintenance: true, MaxVolumes: &maxVolumes, AllowedStorageClasses: []string{"fast"}},
+		Status: v2.DiskMonitorStatus{
+			Total:    quantity("100Gi"),
+			Required: quantity("15Gi"),
+			Pool:     "fast",
+			Volumes: []v2.Volume{
+				{PersistentVolume: "a", Path: "/pv/a", Requested: resource.MustParse("5Gi")},
+				{PersistentVolume: "b", Path: "/pv/b", Requested: resource.MustParse("10Gi"), Pool: "fast", Used: quantity("1Gi"),
+					Claim: &v2.ClaimReference{Namespace: "default", Name: "b"}, CreationTime: &created, Phase: v2.VolumeBound},
+			},
+			VolumeCount:        2,
+			Pools:              []v2.PoolStatus{{Name: "fast", Volumes: 2, Requested: resource.MustParse("15Gi"), Used: resource.MustParse("1Gi")}},
+			ObservedGeneration: 3,
+			LastSyncTime:       &created,
+		},
+	}
+
+	var spoke DiskMonitor
+	if err := spoke.ConvertFrom(&hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if _, ok := spoke.Annotations[AnnV2Fields]; !ok {
+		t.Fatalf("ConvertFrom() should keep the v2 fields in %s", AnnV2Fields)
+	}
+	if _, ok := hub.Annotations[AnnV2Fields]; ok {
+		t.Fatalf("ConvertFrom() should not modify the hub")
+	}
+	var got v2.DiskMonitor
+	if err := spoke.ConvertTo(&got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, hub) {
+		t.Errorf("round trip = %+v, want %+v", got, hub)
+	}
+
+	if detail := spoke.Status.DiskInfo["/pv/b"].Detail; detail[DetailClaim] != "default/b" || detail[DetailUsed] != "1Gi" {
+		t.Errorf("ConvertFrom() details of /pv/b = %v, want the claim and usage", detail)
+	}
+
+	// a v1 client removing a volume removes its details as well
+	delete(spoke.Status.DiskInfo, "/pv/b")
+	if err := spoke.ConvertTo(&got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if len(got.Status.Volumes) != 1 || got.Status.Volumes[0].Path != "/pv/a" || got.Spec.Maintenance != true {
+		t.Errorf("ConvertTo() after removing a volume = %+v", got)
+	}
+
+	spoke.Annotations[AnnV2Fields] = "{"
+	if err := spoke.ConvertTo(&got); err == nil {
+		t.Errorf("ConvertTo() with an invalid %s annotation should fail", AnnV2Fields)
+	}
+}
+
+func Test_ConversionAnnotationSize(t *testing.T) {
+	// the annotation does not grow with the volumes, far below the 256KiB
+	// all annotations of an object may take together
+	const limit = 4 * 1024
+	hub := v2.DiskMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "hostpath"},
+		Spec:       v2.DiskMonitorSpec{AllowedStorageClasses: []string{"fast", "slow"}},
+		Status: v2.DiskMonitorStatus{
+			Pool:  "fast",
+			Pools: []v2.PoolStatus{{Name: "fast", Volumes: 5000, Requested: resource.MustParse("5000Gi"), Used: resource.MustParse("1Ti")}},
+		},
+	}
+	for i := 0; i < 5000; i++ {
+		name := fmt.Sprintf("pvc-%05d", i)
+		hub.Status.Volumes = append(hub.Status.Volumes, v2.Volume{
+			PersistentVolume: name,
+			Path:             "/pv/" + name,
+			Requested:        resource.MustParse("1Gi"),
+			Used:             quantity("100Mi"),
+			Claim:            &v2.ClaimReference{Namespace: "default", Name: name},
+			Pool:             "fast",
+			Phase:            v2.VolumeBound,
+		})
+	}
+	var spoke DiskMonitor
+	if err := spoke.ConvertFrom(&hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if size := len(spoke.Annotations[AnnV2Fields]); size > limit {
+		t.Errorf("%s takes %d bytes with %d volumes, want at most %d", AnnV2Fields, size, len(hub.Status.Volumes), limit)
+	}
+	var got v2.DiskMonitor
+	if err := spoke.ConvertTo(&got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	hub.Status.VolumeCount = int32(len(hub.Status.Volumes))
+	if !reflect.DeepEqual(got, hub) {
+		t.Errorf("round trip of %d volumes does not keep them", len(hub.Status.Volumes))
+	}
+}
@@ -17,40 +17,76 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
-// DiskMonitorSpec defines the desired state of DiskMonitor
+// DiskMonitorSpec is empty in v1, the settings of the pool are only served by
+// v2.
 type DiskMonitorSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-
-	// Foo is an example field of DiskMonitor. Edit DiskMonitor_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
 }
+
+// PVPath is the directory backing a volume.
 type PVPath string
 
-// DiskMonitorStatus defines the observed state of DiskMonitor
+// DiskMonitorStatus defines the observed state of the pool of a node.
 type DiskMonitorStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
-	Total    *resource.Quantity    `json:"total,omitempty"`
-	Required *resource.Quantity    `json:"required,omitempty"`
+	// Total is the size of the filesystem backing the pool.
+	Total *resource.Quantity `json:"total,omitempty"`
+	// Required is the sum of the requests of the volumes on the pool.
+	Required *resource.Quantity `json:"required,omitempty"`
+	// Free is the space currently available on the pool.
+	Free *resource.Quantity `json:"free,omitempty"`
+	// Allocatable is what can still be provisioned according to the capacity policy.
+	Allocatable *resource.Quantity `json:"allocatable,omitempty"`
+	// DiskInfo holds the volumes of the pool keyed by path, with their PV
+	// name and request as details.
 	DiskInfo map[PVPath]DiskDetail `json:"disk_info,omitempty"`
-	// DiskInfo map[PVPath]map[string]string `json:"disk_info,omitempty"`
+	// Conditions are the latest observations of the state of the pool.
+	Conditions []DiskMonitorCondition `json:"conditions,omitempty"`
+}
+
+// DiskMonitorConditionType is the type of a DiskMonitor condition.
+type DiskMonitorConditionType string
+
+const (
+	// DiskMonitorMaintenance is true while the node does not take new volumes
+	// because it is cordoned, tainted, labeled or annotated for maintenance.
+	DiskMonitorMaintenance DiskMonitorConditionType = "Maintenance"
+)
+
+// DiskMonitorCondition describes the state of the pool of a node.
+type DiskMonitorCondition struct {
+	Type   DiskMonitorConditionType `json:"type"`
+	Status corev1.ConditionStatus   `json:"status"`
+	// LastTransitionTime is when the condition last changed its status.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a machine readable explanation of the status.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable explanation of the status.
+	Message string `json:"message,omitempty"`
 }
+
+// Detail holds the details of a volume, see DetailPVName and DetailRequire.
 type Detail map[string]string
+
+// DiskDetail describes a volume of the pool.
 type DiskDetail struct {
 	Detail `json:"detail,omitempty"`
 }
 
+// +genclient
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=dm
+// +kubebuilder:printcolumn:name="Total",type=string,JSONPath=`.status.total`
+// +kubebuilder:printcolumn:name="Required",type=string,JSONPath=`.status.required`
+// +kubebuilder:printcolumn:name="Free",type=string,JSONPath=`.status.free`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
-// DiskMonitor is the Schema for the diskmonitors API
+// DiskMonitor reports the pool of the node it is named after.
 type DiskMonitor struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -24,13 +24,56 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in Detail) DeepCopyInto(out *Detail) {
+	{
+		in := &in
+		*out = make(Detail, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Detail.
+func (in Detail) DeepCopy() Detail {
+	if in == nil {
+		return nil
+	}
+	out := new(Detail)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskDetail) DeepCopyInto(out *DiskDetail) {
+	*out = *in
+	if in.Detail != nil {
+		in, out := &in.Detail, &out.Detail
+		*out = make(Detail, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskDetail.
+func (in *DiskDetail) DeepCopy() *DiskDetail {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskDetail)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiskMonitor) DeepCopyInto(out *DiskMonitor) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitor.
@@ -51,6 +94,22 @@ func (in *DiskMonitor) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskMonitorCondition) DeepCopyInto(out *DiskMonitorCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitorCondition.
+func (in *DiskMonitorCondition) DeepCopy() *DiskMonitorCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskMonitorCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiskMonitorList) DeepCopyInto(out *DiskMonitorList) {
 	*out = *in
@@ -101,6 +160,40 @@ func (in *DiskMonitorSpec) DeepCopy() *DiskMonitorSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiskMonitorStatus) DeepCopyInto(out *DiskMonitorStatus) {
 	*out = *in
+	if in.Total != nil {
+		in, out := &in.Total, &out.Total
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Required != nil {
+		in, out := &in.Required, &out.Required
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Free != nil {
+		in, out := &in.Free, &out.Free
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Allocatable != nil {
+		in, out := &in.Allocatable, &out.Allocatable
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.DiskInfo != nil {
+		in, out := &in.DiskInfo, &out.DiskInfo
+		*out = make(map[PVPath]DiskDetail, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]DiskMonitorCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitorStatus.
@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DiskMonitorSpec defines the desired state of the pool of a node. The node
+// agent applies changes without being restarted, fields left out keep the
+// behavior configured on the DaemonSet.
+type DiskMonitorSpec struct {
+	// Maintenance puts the node in maintenance: new volumes are refused,
+	// existing ones are left alone.
+	Maintenance bool `json:"maintenance,omitempty"`
+	// ReservedHeadroom is kept free on the pool on top of the capacity policy,
+	// it is subtracted from the allocatable capacity.
+	ReservedHeadroom *resource.Quantity `json:"reservedHeadroom,omitempty"`
+	// MaxVolumes is the most volumes the pool holds, including warm volumes.
+	// +kubebuilder:validation:Minimum=0
+	MaxVolumes *int32 `json:"maxVolumes,omitempty"`
+	// AllowedStorageClasses are the storage classes whose claims the node
+	// takes. All classes of the provisioner are allowed when empty.
+	AllowedStorageClasses []string `json:"allowedStorageClasses,omitempty"`
+	// GCRetention is how long a directory of the pool that backs no volume is
+	// kept before it is garbage collected. Orphans are only reported when unset.
+	GCRetention *metav1.Duration `json:"gcRetention,omitempty"`
+	// TrashRetention is how long the directories of deleted volumes and
+	// garbage collected orphans are kept in the trash of the pool before they
+	// are removed. They are removed right away when unset.
+	TrashRetention *metav1.Duration `json:"trashRetention,omitempty"`
+}
+
+// DiskMonitorStatus defines the observed state of the pool of a node.
+type DiskMonitorStatus struct {
+	// Total is the size of the filesystem backing the pool.
+	Total *resource.Quantity `json:"total,omitempty"`
+	// Required is the sum of the requests of the volumes on the pool.
+	Required *resource.Quantity `json:"required,omitempty"`
+	// Free is the space currently available on the pool.
+	Free *resource.Quantity `json:"free,omitempty"`
+	// Allocatable is what can still be provisioned according to the capacity policy.
+	Allocatable *resource.Quantity `json:"allocatable,omitempty"`
+	// Pool is the pool of the node, volumes whose claim names no pool are
+	// placed in it.
+	Pool string `json:"pool,omitempty"`
+	// Volumes are the volumes placed on the pool, sorted by path.
+	// +listType=map
+	// +listMapKey=path
+	Volumes []Volume `json:"volumes,omitempty"`
+	// VolumeCount is the number of volumes placed on the pool.
+	VolumeCount int32 `json:"volumeCount,omitempty"`
+	// Pools are the totals of the volumes of every pool.
+	// +listType=map
+	// +listMapKey=name
+	Pools []PoolStatus `json:"pools,omitempty"`
+	// Forecast is the growth of the pool fitted to the history of its usage
+	// the node agent keeps. It is unset until enough samples were taken.
+	Forecast *PoolForecast `json:"forecast,omitempty"`
+	// Conditions are the latest observations of the state of the pool.
+	Conditions []DiskMonitorCondition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation of the DiskMonitor the node agent
+	// last reconciled.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastSyncTime is when the node agent last reconciled the DiskMonitor. It
+	// is refreshed at least every heartbeat period, so an old value means the
+	// agent is gone.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// VolumePhase is the state of a volume on the pool.
+type VolumePhase string
+
+const (
+	// VolumeProvisioning is a volume whose directory is created and whose PV
+	// is not stored yet.
+	VolumeProvisioning VolumePhase = "Provisioning"
+	// VolumeAvailable is a PV not bound to a claim yet.
+	VolumeAvailable VolumePhase = VolumePhase(corev1.VolumeAvailable)
+	// VolumeBound is a PV bound to a claim.
+	VolumeBound VolumePhase = VolumePhase(corev1.VolumeBound)
+	// VolumeReleased is a PV whose claim was deleted.
+	VolumeReleased VolumePhase = VolumePhase(corev1.VolumeReleased)
+	// VolumeFailed is a PV whose reclamation failed.
+	VolumeFailed VolumePhase = VolumePhase(corev1.VolumeFailed)
+)
+
+// ClaimReference names the claim a volume is provisioned for.
+type ClaimReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Volume is a volume placed on the pool.
+type Volume struct {
+	// PersistentVolume is the name of the PV of the volume.
+	PersistentVolume string `json:"persistentVolume"`
+	// Claim is the claim the volume is provisioned for or bound to.
+	Claim *ClaimReference `json:"claim,omitempty"`
+	// Pool is the pool the volume is placed in.
+	Pool string `json:"pool,omitempty"`
+	// Path is the directory backing the volume.
+	Path string `json:"path"`
+	// Requested is the capacity of the volume.
+	Requested resource.Quantity `json:"requested"`
+	// Used is the space the files of the volume take on the pool.
+	Used *resource.Quantity `json:"used,omitempty"`
+	// CreationTime is when the PV of the volume was created.
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+	// Phase is the state of the volume.
+	Phase VolumePhase `json:"phase,omitempty"`
+}
+
+// PoolStatus sums up the volumes of a pool.
+type PoolStatus struct {
+	// Name is the name of the pool.
+	Name string `json:"name"`
+	// Volumes is the number of volumes in the pool.
+	Volumes int32 `json:"volumes"`
+	// Requested is the sum of the requests of the volumes in the pool.
+	Requested resource.Quantity `json:"requested"`
+	// Used is the sum of the space the volumes in the pool take, for the
+	// volumes whose usage is known.
+	Used resource.Quantity `json:"used"`
+}
+
+// PoolForecast is the growth of the pool of a node and when it fills up at
+// that rate.
+type PoolForecast struct {
+	// Samples is the number of samples of the history the trend is fitted to.
+	Samples int32 `json:"samples"`
+	// Since is when the oldest of them was taken.
+	Since metav1.Time `json:"since"`
+	// UsedGrowthPerDay is how much the used space of the pool grows per day,
+	// it is negative when it shrinks.
+	UsedGrowthPerDay resource.Quantity `json:"usedGrowthPerDay"`
+	// RequestedGrowthPerDay is how much the requests of the volumes on the
+	// pool grow per day.
+	RequestedGrowthPerDay resource.Quantity `json:"requestedGrowthPerDay"`
+	// FullAt is when the pool is projected to have no space left. It is unset
+	// when the usage does not grow or the pool does not fill up within ten
+	// years.
+	FullAt *metav1.Time `json:"fullAt,omitempty"`
+	// RequestsFullAt is when the requests of the volumes are projected to
+	// reach the size of the pool.
+	RequestsFullAt *metav1.Time `json:"requestsFullAt,omitempty"`
+}
+
+// DiskMonitorConditionType is the type of a DiskMonitor condition.
+type DiskMonitorConditionType string
+
+const (
+	// DiskMonitorMaintenance is true while the node does not take new volumes
+	// because it is cordoned, tainted, labeled or annotated for maintenance.
+	DiskMonitorMaintenance DiskMonitorConditionType = "Maintenance"
+	// DiskMonitorReady is true while the node agent reconciles the DiskMonitor
+	// and its pool is available. It is set to false by the stale checker when
+	// the agent stopped sending heartbeats.
+	DiskMonitorReady DiskMonitorConditionType = "Ready"
+	// DiskMonitorPoolMounted is true while the directory of the pool exists
+	// and its filesystem can be read.
+	DiskMonitorPoolMounted DiskMonitorConditionType = "PoolMounted"
+	// DiskMonitorCapacityLow is true while the pool is under storage pressure,
+	// or has no allocatable capacity left when no thresholds are configured.
+	DiskMonitorCapacityLow DiskMonitorConditionType = "CapacityLow"
+	// DiskMonitorOrphansDetected is true while the pool holds directories no
+	// volume of the node is backed by.
+	DiskMonitorOrphansDetected DiskMonitorConditionType = "OrphansDetected"
+	// DiskMonitorReconcileFailed is true when the node agent failed to compute
+	// the status in its last attempt.
+	DiskMonitorReconcileFailed DiskMonitorConditionType = "ReconcileFailed"
+)
+
+// DiskMonitorCondition describes the state of the pool of a node.
+type DiskMonitorCondition struct {
+	Type   DiskMonitorConditionType `json:"type"`
+	Status corev1.ConditionStatus   `json:"status"`
+	// LastTransitionTime is when the condition last changed its status.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a machine readable explanation of the status.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable explanation of the status.
+	Message string `json:"message,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:shortName=dm
+// +kubebuilder:printcolumn:name="Total",type=string,JSONPath=`.status.total`
+// +kubebuilder:printcolumn:name="Required",type=string,JSONPath=`.status.required`
+// +kubebuilder:printcolumn:name="Free",type=string,JSONPath=`.status.free`
+// +kubebuilder:printcolumn:name="Volumes",type=integer,JSONPath=`.status.volumeCount`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Full At",type=string,JSONPath=`.status.forecast.fullAt`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DiskMonitor reports the pool of the node it is named after.
+type DiskMonitor struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DiskMonitorSpec   `json:"spec,omitempty"`
+	Status DiskMonitorStatus `json:"status,omitempty"`
+}
+
+// Hub marks v2 as the version the other versions convert to and from.
+func (*DiskMonitor) Hub() {}
+
+// +kubebuilder:object:root=true
+
+// DiskMonitorList contains a list of DiskMonitor
+type DiskMonitorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DiskMonitor `json:"items"`
+}
@@ -0,0 +1,23 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v2 contains the v2 API of the DiskMonitor CRD. It replaces the
+// free-form disk_info map of v1 with a list of volume entries and per pool
+// totals, and is the version DiskMonitors are stored in.
+// +kubebuilder:object:generate=true
+// +groupName=diskmonitor.domain
+// +groupGoName=DiskMonitor
+package v2
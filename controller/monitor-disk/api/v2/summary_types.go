@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CapacitySummary sums up the capacity of the pools of a set of nodes.
+type CapacitySummary struct {
+	// Total is the size of the filesystems backing the pools.
+	Total resource.Quantity `json:"total"`
+	// Requested is the sum of the requests of the volumes on the pools.
+	Requested resource.Quantity `json:"requested"`
+	// Free is the space currently available on the pools.
+	Free resource.Quantity `json:"free"`
+	// Allocatable is what can still be provisioned according to the capacity
+	// policies of the nodes.
+	Allocatable resource.Quantity `json:"allocatable"`
+}
+
+// PoolSummary sums up the nodes of a pool.
+type PoolSummary struct {
+	// Name is the name of the pool.
+	Name string `json:"name"`
+	// Nodes is the number of nodes of the pool.
+	Nodes int32 `json:"nodes"`
+	// Volumes is the number of volumes on the nodes of the pool.
+	Volumes int32 `json:"volumes"`
+	// CapacitySummary is the capacity of the nodes of the pool.
+	CapacitySummary `json:",inline"`
+	// UnschedulableClaims is the number of pending claims of the pool that
+	// no node can hold.
+	UnschedulableClaims int32 `json:"unschedulableClaims"`
+}
+
+// StorageClassSummary sums up the volumes of a storage class. Its capacity
+// is the capacity of the pool of the class, except for Requested which only
+// counts the volumes of the class.
+type StorageClassSummary struct {
+	// Name is the name of the storage class.
+	Name string `json:"name"`
+	// Pool is the pool of the claims of the class that name none.
+	Pool string `json:"pool"`
+	// Volumes is the number of volumes of the class.
+	Volumes int32 `json:"volumes"`
+	// CapacitySummary is the capacity of the pool of the class, with the
+	// requests of the volumes of the class.
+	CapacitySummary `json:",inline"`
+	// UnschedulableClaims is the number of pending claims of the class that
+	// no node can hold.
+	UnschedulableClaims int32 `json:"unschedulableClaims"`
+}
+
+// NodeSummary is the capacity of the pool of a node.
+type NodeSummary struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+	// Pool is the pool of the node.
+	Pool string `json:"pool,omitempty"`
+	// Ready is whether the DiskMonitor of the node is ready.
+	Ready bool `json:"ready"`
+	// Volumes is the number of volumes on the node.
+	Volumes int32 `json:"volumes"`
+	// CapacitySummary is the capacity of the pool of the node.
+	CapacitySummary `json:",inline"`
+}
+
+// HostPathClusterSummaryStatus is the capacity of the provisioner across the
+// cluster.
+type HostPathClusterSummaryStatus struct {
+	// Nodes is the number of nodes reporting a pool.
+	Nodes int32 `json:"nodes"`
+	// Volumes is the number of volumes on the nodes.
+	Volumes int32 `json:"volumes"`
+	// CapacitySummary is the capacity of all the nodes.
+	CapacitySummary `json:",inline"`
+	// UnschedulableClaims is the number of pending claims that no node can
+	// hold.
+	UnschedulableClaims int32 `json:"unschedulableClaims"`
+	// Pools sums up the nodes of every pool.
+	// +listType=map
+	// +listMapKey=name
+	Pools []PoolSummary `json:"pools,omitempty"`
+	// StorageClasses sums up the volumes of every storage class of the
+	// provisioner.
+	// +listType=map
+	// +listMapKey=name
+	StorageClasses []StorageClassSummary `json:"storageClasses,omitempty"`
+	// NodesByFree are the nodes, most free space first.
+	NodesByFree []NodeSummary `json:"nodesByFree,omitempty"`
+	// LastUpdateTime is when the summary last changed.
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=hpcs
+// +kubebuilder:printcolumn:name="Nodes",type=integer,JSONPath=`.status.nodes`
+// +kubebuilder:printcolumn:name="Total",type=string,JSONPath=`.status.total`
+// +kubebuilder:printcolumn:name="Requested",type=string,JSONPath=`.status.requested`
+// +kubebuilder:printcolumn:name="Free",type=string,JSONPath=`.status.free`
+// +kubebuilder:printcolumn:name="Unschedulable",type=integer,JSONPath=`.status.unschedulableClaims`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HostPathClusterSummary sums up the DiskMonitors of every node, so the
+// capacity of the cluster can be read from a single object. It is kept up to
+// date by the manager.
+type HostPathClusterSummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status HostPathClusterSummaryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HostPathClusterSummaryList contains a list of HostPathClusterSummary
+type HostPathClusterSummaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostPathClusterSummary `json:"items"`
+}
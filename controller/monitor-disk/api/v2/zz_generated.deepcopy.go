@@ -0,0 +1,460 @@
+// +build !ignore_autogenerated
+
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacitySummary) DeepCopyInto(out *CapacitySummary) {
+	*out = *in
+	out.Total = in.Total.DeepCopy()
+	out.Requested = in.Requested.DeepCopy()
+	out.Free = in.Free.DeepCopy()
+	out.Allocatable = in.Allocatable.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacitySummary.
+func (in *CapacitySummary) DeepCopy() *CapacitySummary {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacitySummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimReference) DeepCopyInto(out *ClaimReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimReference.
+func (in *ClaimReference) DeepCopy() *ClaimReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskMonitor) DeepCopyInto(out *DiskMonitor) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitor.
+func (in *DiskMonitor) DeepCopy() *DiskMonitor {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskMonitor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DiskMonitor) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskMonitorCondition) DeepCopyInto(out *DiskMonitorCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitorCondition.
+func (in *DiskMonitorCondition) DeepCopy() *DiskMonitorCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskMonitorCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskMonitorList) DeepCopyInto(out *DiskMonitorList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DiskMonitor, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitorList.
+func (in *DiskMonitorList) DeepCopy() *DiskMonitorList {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskMonitorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DiskMonitorList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskMonitorSpec) DeepCopyInto(out *DiskMonitorSpec) {
+	*out = *in
+	if in.ReservedHeadroom != nil {
+		in, out := &in.ReservedHeadroom, &out.ReservedHeadroom
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaxVolumes != nil {
+		in, out := &in.MaxVolumes, &out.MaxVolumes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowedStorageClasses != nil {
+		in, out := &in.AllowedStorageClasses, &out.AllowedStorageClasses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GCRetention != nil {
+		in, out := &in.GCRetention, &out.GCRetention
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TrashRetention != nil {
+		in, out := &in.TrashRetention, &out.TrashRetention
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitorSpec.
+func (in *DiskMonitorSpec) DeepCopy() *DiskMonitorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskMonitorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskMonitorStatus) DeepCopyInto(out *DiskMonitorStatus) {
+	*out = *in
+	if in.Total != nil {
+		in, out := &in.Total, &out.Total
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Required != nil {
+		in, out := &in.Required, &out.Required
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Free != nil {
+		in, out := &in.Free, &out.Free
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Allocatable != nil {
+		in, out := &in.Allocatable, &out.Allocatable
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]PoolStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Forecast != nil {
+		in, out := &in.Forecast, &out.Forecast
+		*out = new(PoolForecast)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]DiskMonitorCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskMonitorStatus.
+func (in *DiskMonitorStatus) DeepCopy() *DiskMonitorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskMonitorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPathClusterSummary) DeepCopyInto(out *HostPathClusterSummary) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPathClusterSummary.
+func (in *HostPathClusterSummary) DeepCopy() *HostPathClusterSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPathClusterSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostPathClusterSummary) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPathClusterSummaryList) DeepCopyInto(out *HostPathClusterSummaryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostPathClusterSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPathClusterSummaryList.
+func (in *HostPathClusterSummaryList) DeepCopy() *HostPathClusterSummaryList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPathClusterSummaryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostPathClusterSummaryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPathClusterSummaryStatus) DeepCopyInto(out *HostPathClusterSummaryStatus) {
+	*out = *in
+	in.CapacitySummary.DeepCopyInto(&out.CapacitySummary)
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]PoolSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StorageClasses != nil {
+		in, out := &in.StorageClasses, &out.StorageClasses
+		*out = make([]StorageClassSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodesByFree != nil {
+		in, out := &in.NodesByFree, &out.NodesByFree
+		*out = make([]NodeSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPathClusterSummaryStatus.
+func (in *HostPathClusterSummaryStatus) DeepCopy() *HostPathClusterSummaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPathClusterSummaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeSummary) DeepCopyInto(out *NodeSummary) {
+	*out = *in
+	in.CapacitySummary.DeepCopyInto(&out.CapacitySummary)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeSummary.
+func (in *NodeSummary) DeepCopy() *NodeSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolForecast) DeepCopyInto(out *PoolForecast) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	out.UsedGrowthPerDay = in.UsedGrowthPerDay.DeepCopy()
+	out.RequestedGrowthPerDay = in.RequestedGrowthPerDay.DeepCopy()
+	if in.FullAt != nil {
+		in, out := &in.FullAt, &out.FullAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RequestsFullAt != nil {
+		in, out := &in.RequestsFullAt, &out.RequestsFullAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolForecast.
+func (in *PoolForecast) DeepCopy() *PoolForecast {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolForecast)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolStatus) DeepCopyInto(out *PoolStatus) {
+	*out = *in
+	out.Requested = in.Requested.DeepCopy()
+	out.Used = in.Used.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolStatus.
+func (in *PoolStatus) DeepCopy() *PoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolSummary) DeepCopyInto(out *PoolSummary) {
+	*out = *in
+	in.CapacitySummary.DeepCopyInto(&out.CapacitySummary)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolSummary.
+func (in *PoolSummary) DeepCopy() *PoolSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageClassSummary) DeepCopyInto(out *StorageClassSummary) {
+	*out = *in
+	in.CapacitySummary.DeepCopyInto(&out.CapacitySummary)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageClassSummary.
+func (in *StorageClassSummary) DeepCopy() *StorageClassSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageClassSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Volume) DeepCopyInto(out *Volume) {
+	*out = *in
+	if in.Claim != nil {
+		in, out := &in.Claim, &out.Claim
+		*out = new(ClaimReference)
+		**out = **in
+	}
+	out.Requested = in.Requested.DeepCopy()
+	if in.Used != nil {
+		in, out := &in.Used, &out.Used
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Volume.
+func (in *Volume) DeepCopy() *Volume {
+	if in == nil {
+		return nil
+	}
+	out := new(Volume)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	listers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+)
+
+// NodeCapacity returns a NodeCapacityFunc reading the DiskMonitors the
+// node agents keep in namespace, one per node and named after it, from lister.
+// The allocatable capacity reported by the agent is used as is. Agents that do
+// not report it yet are evaluated with policy.
+func NodeCapacity(lister listers.DiskMonitorLister, namespace string, policy capacity.Policy) capacity.NodeCapacityFunc {
+	return func(nodeName string) (capacity.Capacity, bool) {
+		diskMonitor, err := lister.DiskMonitors(namespace).Get(nodeName)
+		if err != nil {
+			return capacity.Capacity{}, false
+		}
+		status := diskMonitor.Status
+		if status.Total == nil {
+			return capacity.Capacity{}, false
+		}
+		var requested int64
+		if status.Required != nil {
+			requested = status.Required.Value()
+		}
+		if status.Allocatable != nil {
+			nodeCapacity := capacity.Capacity{
+				Total:       status.Total.Value(),
+				Requested:   requested,
+				Allocatable: status.Allocatable.Value(),
+			}
+			if status.Free != nil {
+				nodeCapacity.Available = status.Free.Value()
+			}
+			return nodeCapacity, true
+		}
+		free := status.Total.Value() - requested
+		if status.Free != nil {
+			free = status.Free.Value()
+		}
+		stats := capacity.Stats{Total: status.Total.Value(), Free: free, Available: free}
+		return policy.Compute(stats, requested, 0), true
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	scheme "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned/scheme"
+)
+
+// DiskMonitorsGetter has a method to return a DiskMonitorInterface.
+// A group's client should implement this interface.
+type DiskMonitorsGetter interface {
+	DiskMonitors(namespace string) DiskMonitorInterface
+}
+
+// DiskMonitorInterface has methods to work with DiskMonitor resources.
+type DiskMonitorInterface interface {
+	Create(ctx context.Context, diskMonitor *v2.DiskMonitor, opts v1.CreateOptions) (*v2.DiskMonitor, error)
+	Update(ctx context.Context, diskMonitor *v2.DiskMonitor, opts v1.UpdateOptions) (*v2.DiskMonitor, error)
+	UpdateStatus(ctx context.Context, diskMonitor *v2.DiskMonitor, opts v1.UpdateOptions) (*v2.DiskMonitor, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v2.DiskMonitor, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v2.DiskMonitorList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v2.DiskMonitor, err error)
+	DiskMonitorExpansion
+}
+
+// diskMonitors implements DiskMonitorInterface
+type diskMonitors struct {
+	client rest.Interface
+	ns     string
+}
+
+// newDiskMonitors returns a DiskMonitors
+func newDiskMonitors(c *DiskMonitorV2Client, namespace string) *diskMonitors {
+	return &diskMonitors{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the diskMonitor, and returns the corresponding diskMonitor object, and an error if there is any.
+func (c *diskMonitors) Get(ctx context.Context, name string, options v1.GetOptions) (result *v2.DiskMonitor, err error) {
+	result = &v2.DiskMonitor{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of DiskMonitors that match those selectors.
+func (c *diskMonitors) List(ctx context.Context, opts v1.ListOptions) (result *v2.DiskMonitorList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v2.DiskMonitorList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested diskMonitors.
+func (c *diskMonitors) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a diskMonitor and creates it.  Returns the server's representation of the diskMonitor, and an error, if there is any.
+func (c *diskMonitors) Create(ctx context.Context, diskMonitor *v2.DiskMonitor, opts v1.CreateOptions) (result *v2.DiskMonitor, err error) {
+	result = &v2.DiskMonitor{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(diskMonitor).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a diskMonitor and updates it. Returns the server's representation of the diskMonitor, and an error, if there is any.
+func (c *diskMonitors) Update(ctx context.Context, diskMonitor *v2.DiskMonitor, opts v1.UpdateOptions) (result *v2.DiskMonitor, err error) {
+	result = &v2.DiskMonitor{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		Name(diskMonitor.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(diskMonitor).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *diskMonitors) UpdateStatus(ctx context.Context, diskMonitor *v2.DiskMonitor, opts v1.UpdateOptions) (result *v2.DiskMonitor, err error) {
+	result = &v2.DiskMonitor{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		Name(diskMonitor.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(diskMonitor).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the diskMonitor and deletes it. Returns an error if one occurs.
+func (c *diskMonitors) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *diskMonitors) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched diskMonitor.
+func (c *diskMonitors) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v2.DiskMonitor, err error) {
+	result = &v2.DiskMonitor{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("diskmonitors").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
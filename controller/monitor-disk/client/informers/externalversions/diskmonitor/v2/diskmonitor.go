@@ -0,0 +1,90 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"context"
+	time "time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+	diskmonitorv2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	versioned "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	internalinterfaces "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions/internalinterfaces"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+)
+
+// DiskMonitorInformer provides access to a shared informer and lister for
+// DiskMonitors.
+type DiskMonitorInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v2.DiskMonitorLister
+}
+
+type diskMonitorInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewDiskMonitorInformer constructs a new informer for DiskMonitor type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewDiskMonitorInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredDiskMonitorInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredDiskMonitorInformer constructs a new informer for DiskMonitor type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredDiskMonitorInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.DiskMonitorV2().DiskMonitors(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.DiskMonitorV2().DiskMonitors(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&diskmonitorv2.DiskMonitor{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *diskMonitorInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredDiskMonitorInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *diskMonitorInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&diskmonitorv2.DiskMonitor{}, f.defaultInformer)
+}
+
+func (f *diskMonitorInformer) Lister() v2.DiskMonitorLister {
+	return v2.NewDiskMonitorLister(f.Informer().GetIndexer())
+}
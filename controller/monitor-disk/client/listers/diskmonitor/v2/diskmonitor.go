@@ -0,0 +1,94 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+// DiskMonitorLister helps list DiskMonitors.
+type DiskMonitorLister interface {
+	// List lists all DiskMonitors in the indexer.
+	List(selector labels.Selector) (ret []*v2.DiskMonitor, err error)
+	// DiskMonitors returns an object that can list and get DiskMonitors.
+	DiskMonitors(namespace string) DiskMonitorNamespaceLister
+	DiskMonitorListerExpansion
+}
+
+// diskMonitorLister implements the DiskMonitorLister interface.
+type diskMonitorLister struct {
+	indexer cache.Indexer
+}
+
+// NewDiskMonitorLister returns a new DiskMonitorLister.
+func NewDiskMonitorLister(indexer cache.Indexer) DiskMonitorLister {
+	return &diskMonitorLister{indexer: indexer}
+}
+
+// List lists all DiskMonitors in the indexer.
+func (s *diskMonitorLister) List(selector labels.Selector) (ret []*v2.DiskMonitor, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v2.DiskMonitor))
+	})
+	return ret, err
+}
+
+// DiskMonitors returns an object that can list and get DiskMonitors.
+func (s *diskMonitorLister) DiskMonitors(namespace string) DiskMonitorNamespaceLister {
+	return diskMonitorNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// DiskMonitorNamespaceLister helps list and get DiskMonitors.
+type DiskMonitorNamespaceLister interface {
+	// List lists all DiskMonitors in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v2.DiskMonitor, err error)
+	// Get retrieves the DiskMonitor from the indexer for a given namespace and name.
+	Get(name string) (*v2.DiskMonitor, error)
+	DiskMonitorNamespaceListerExpansion
+}
+
+// diskMonitorNamespaceLister implements the DiskMonitorNamespaceLister
+// interface.
+type diskMonitorNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all DiskMonitors in the indexer for a given namespace.
+func (s diskMonitorNamespaceLister) List(selector labels.Selector) (ret []*v2.DiskMonitor, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v2.DiskMonitor))
+	})
+	return ret, err
+}
+
+// Get retrieves the DiskMonitor from the indexer for a given namespace and name.
+func (s diskMonitorNamespaceLister) Get(name string) (*v2.DiskMonitor, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v2.Resource("diskmonitor"), name)
+	}
+	return obj.(*v2.DiskMonitor), nil
+}
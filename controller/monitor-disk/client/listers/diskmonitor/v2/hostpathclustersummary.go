@@ -0,0 +1,65 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v2
+
+import (
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+// HostPathClusterSummaryLister helps list HostPathClusterSummaries.
+type HostPathClusterSummaryLister interface {
+	// List lists all HostPathClusterSummaries in the indexer.
+	List(selector labels.Selector) (ret []*v2.HostPathClusterSummary, err error)
+	// Get retrieves the HostPathClusterSummary from the index for a given name.
+	Get(name string) (*v2.HostPathClusterSummary, error)
+	HostPathClusterSummaryListerExpansion
+}
+
+// hostPathClusterSummaryLister implements the HostPathClusterSummaryLister interface.
+type hostPathClusterSummaryLister struct {
+	indexer cache.Indexer
+}
+
+// NewHostPathClusterSummaryLister returns a new HostPathClusterSummaryLister.
+func NewHostPathClusterSummaryLister(indexer cache.Indexer) HostPathClusterSummaryLister {
+	return &hostPathClusterSummaryLister{indexer: indexer}
+}
+
+// List lists all HostPathClusterSummaries in the indexer.
+func (s *hostPathClusterSummaryLister) List(selector labels.Selector) (ret []*v2.HostPathClusterSummary, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v2.HostPathClusterSummary))
+	})
+	return ret, err
+}
+
+// Get retrieves the HostPathClusterSummary from the index for a given name.
+func (s *hostPathClusterSummaryLister) Get(name string) (*v2.HostPathClusterSummary, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v2.Resource("hostpathclustersummary"), name)
+	}
+	return obj.(*v2.HostPathClusterSummary), nil
+}
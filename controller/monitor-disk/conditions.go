@@ -0,0 +1,47 @@
+package monitor_disk
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+// FindCondition returns the condition of the given type, or nil.
+func FindCondition(status *v2.DiskMonitorStatus, conditionType v2.DiskMonitorConditionType) *v2.DiskMonitorCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds the condition to status or updates the existing one of
+// the same type. The transition time only changes with the status.
+func SetCondition(status *v2.DiskMonitorStatus, condition v2.DiskMonitorCondition) {
+	existing := FindCondition(status, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		status.Conditions = append(status.Conditions, condition)
+		return
+	}
+	if existing.Status != condition.Status {
+		existing.Status = condition.Status
+		existing.LastTransitionTime = condition.LastTransitionTime
+		if existing.LastTransitionTime.IsZero() {
+			existing.LastTransitionTime = metav1.Now()
+		}
+	}
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+}
+
+// IsConditionTrue returns whether status has the condition of the given type
+// and it is true.
+func IsConditionTrue(status *v2.DiskMonitorStatus, conditionType v2.DiskMonitorConditionType) bool {
+	condition := FindCondition(status, conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
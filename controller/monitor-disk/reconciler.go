@@ -0,0 +1,246 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	glog "k8s.io/klog"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	informers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions/diskmonitor/v2"
+	listers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+// DefaultResyncPeriod is how often the DiskMonitor is reconciled when no
+// event arrives, so that the space used on the pool is refreshed.
+const DefaultResyncPeriod = 30 * time.Second
+
+// DefaultHeartbeatPeriod is how often lastSyncTime is refreshed when the
+// status does not change otherwise.
+const DefaultHeartbeatPeriod = time.Minute
+
+// StatusFunc returns the status the DiskMonitor of the node should have,
+// computed from a copy of the current one.
+type StatusFunc func(current *v2.DiskMonitor) (*v2.DiskMonitorStatus, error)
+
+// CreateFunc creates the missing DiskMonitor of the node.
+type CreateFunc func() error
+
+// Reconciler keeps the status of the DiskMonitor of a node in line with the
+// volumes placed on the node. It reconciles when a PV of the node or the
+// DiskMonitor changes, when Enqueue is called and every resync period, and
+// only writes the status when it changed or the heartbeat is due.
+//
+// Besides the status computed by StatusFunc, the Reconciler maintains the
+// observed generation, the heartbeat and the ReconcileFailed and Ready
+// conditions.
+type Reconciler struct {
+	client       versioned.Interface
+	namespace    string
+	nodeName     string
+	lister       listers.DiskMonitorLister
+	status       StatusFunc
+	create       CreateFunc
+	queue        workqueue.RateLimitingInterface
+	synced       []cache.InformerSynced
+	resyncPeriod time.Duration
+	// heartbeatPeriod is the longest lastSyncTime is left alone
+	heartbeatPeriod time.Duration
+	now             func() time.Time
+}
+
+// NewReconciler returns a Reconciler of the DiskMonitor named after the node
+// in namespace. Event handlers are added to the informers, which are started
+// by the caller.
+func NewReconciler(client versioned.Interface, namespace, nodeName string, diskMonitors informers.DiskMonitorInformer,
+	volumes cache.SharedIndexInformer, status StatusFunc, create CreateFunc) *Reconciler {
+	r := &Reconciler{
+		client:       client,
+		namespace:    namespace,
+		nodeName:     nodeName,
+		lister:       diskMonitors.Lister(),
+		status:       status,
+		create:       create,
+		queue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "diskmonitor"),
+		synced:       []cache.InformerSynced{diskMonitors.Informer().HasSynced, volumes.HasSynced},
+		resyncPeriod: DefaultResyncPeriod,
+		// a heartbeat shorter than the resync period would be written late
+		heartbeatPeriod: DefaultHeartbeatPeriod,
+		now:             time.Now,
+	}
+	diskMonitors.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			monitor, ok := obj.(*v2.DiskMonitor)
+			if !ok {
+				if tombstone, isTombstone := obj.(cache.DeletedFinalStateUnknown); isTombstone {
+					monitor, ok = tombstone.Obj.(*v2.DiskMonitor)
+				}
+			}
+			return ok && monitor.Namespace == namespace && monitor.Name == nodeName
+		},
+		Handler: r.handler(),
+	})
+	volumes.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			pv, ok := obj.(*corev1.PersistentVolume)
+			if !ok {
+				if tombstone, isTombstone := obj.(cache.DeletedFinalStateUnknown); isTombstone {
+					pv, ok = tombstone.Obj.(*corev1.PersistentVolume)
+				}
+			}
+			return ok && pv.Annotations[nodevolumes.AnnProvisionOnNode] == nodeName
+		},
+		Handler: r.handler(),
+	})
+	return r
+}
+
+func (r *Reconciler) handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { r.Enqueue() },
+		UpdateFunc: func(interface{}, interface{}) { r.Enqueue() },
+		DeleteFunc: func(interface{}) { r.Enqueue() },
+	}
+}
+
+// Enqueue asks for the DiskMonitor to be reconciled, e.g. after something
+// the status depends on changed outside of the watched informers.
+func (r *Reconciler) Enqueue() {
+	r.queue.Add(r.nodeName)
+}
+
+// Run reconciles the DiskMonitor until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	defer r.queue.ShutDown()
+	glog.Infof("Starting DiskMonitor reconciler of node %s", r.nodeName)
+	defer glog.Infof("Shutting down DiskMonitor reconciler of node %s", r.nodeName)
+	if !cache.WaitForCacheSync(ctx.Done(), r.synced...) {
+		return
+	}
+	go wait.Until(r.runWorker, time.Second, ctx.Done())
+	go wait.Until(r.Enqueue, r.resyncPeriod, ctx.Done())
+	<-ctx.Done()
+}
+
+func (r *Reconciler) runWorker() {
+	for r.processNextItem() {
+	}
+}
+
+func (r *Reconciler) processNextItem() bool {
+	key, quit := r.queue.Get()
+	if quit {
+		return false
+	}
+	defer r.queue.Done(key)
+	if err := r.Sync(); err != nil {
+		glog.Errorf("Failed to reconcile DiskMonitor %s/%s: %v", r.namespace, r.nodeName, err)
+		r.queue.AddRateLimited(key)
+		return true
+	}
+	r.queue.Forget(key)
+	return true
+}
+
+// Sync creates the DiskMonitor when it is missing and writes its status
+// through the status subresource when it differs from the computed one or the
+// heartbeat is due. A failure to compute the status is reported in the
+// ReconcileFailed condition, the rest of the status is left as it was.
+func (r *Reconciler) Sync() error {
+	current, err := r.lister.DiskMonitors(r.namespace).Get(r.nodeName)
+	if apierrors.IsNotFound(err) {
+		// the informer brings the created DiskMonitor back and its status is filled in then
+		if err := r.create(); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	status, statusErr := r.status(current.DeepCopy())
+	if statusErr != nil {
+		status = current.Status.DeepCopy()
+		SetCondition(status, v2.DiskMonitorCondition{
+			Type:    v2.DiskMonitorReconcileFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "StatusFailed",
+			Message: statusErr.Error(),
+		})
+	} else {
+		SetCondition(status, v2.DiskMonitorCondition{
+			Type:    v2.DiskMonitorReconcileFailed,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Reconciled",
+			Message: "status is up to date",
+		})
+	}
+	SetCondition(status, readyCondition(status))
+	status.ObservedGeneration = current.Generation
+	status.LastSyncTime = current.Status.LastSyncTime
+	now := r.now()
+	heartbeatDue := status.LastSyncTime == nil || now.Sub(status.LastSyncTime.Time) >= r.heartbeatPeriod
+	if !heartbeatDue && equality.Semantic.DeepEqual(*status, current.Status) {
+		return statusErr
+	}
+	lastSyncTime := metav1.NewTime(now)
+	status.LastSyncTime = &lastSyncTime
+	monitor := current.DeepCopy()
+	monitor.Status = *status
+	if _, err := r.client.DiskMonitorV2().DiskMonitors(r.namespace).UpdateStatus(context.TODO(), monitor, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	return statusErr
+}
+
+// readyCondition returns the Ready condition of a status the agent computed:
+// the agent is ready while it reconciles and the pool is available.
+func readyCondition(status *v2.DiskMonitorStatus) v2.DiskMonitorCondition {
+	if IsConditionTrue(status, v2.DiskMonitorReconcileFailed) {
+		return v2.DiskMonitorCondition{
+			Type:    v2.DiskMonitorReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "ReconcileFailed",
+			Message: "node agent failed to compute the status",
+		}
+	}
+	if poolMounted := FindCondition(status, v2.DiskMonitorPoolMounted); poolMounted != nil && poolMounted.Status != corev1.ConditionTrue {
+		return v2.DiskMonitorCondition{
+			Type:    v2.DiskMonitorReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "PoolUnavailable",
+			Message: poolMounted.Message,
+		}
+	}
+	return v2.DiskMonitorCondition{
+		Type:    v2.DiskMonitorReady,
+		Status:  corev1.ConditionTrue,
+		Reason:  "AgentReady",
+		Message: "node agent is reconciling the pool",
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	diskmonitorfake "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned/fake"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+)
+
+const testNamespace = "hostpath"
+
+func writes(client *diskmonitorfake.Clientset) []string {
+	var verbs []string
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" || action.GetVerb() == "watch" || action.GetVerb() == "get" {
+			continue
+		}
+		verbs = append(verbs, action.GetVerb()+"/"+action.GetSubresource())
+	}
+	return verbs
+}
+
+func Test_ReconcilerSync(t *testing.T) {
+	client := diskmonitorfake.NewSimpleClientset()
+	factory := diskmonitorinformers.NewSharedInformerFactory(client, 0)
+	diskMonitors := factory.DiskMonitor().V2().DiskMonitors()
+	volumes := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().PersistentVolumes().Informer()
+
+	required := resource.MustParse("10Gi")
+	var statusErr error
+	status := func(current *v2.DiskMonitor) (*v2.DiskMonitorStatus, error) {
+		if statusErr != nil {
+			return nil, statusErr
+		}
+		status := current.Status.DeepCopy()
+		status.Required = &required
+		return status, nil
+	}
+	create := func() error {
+		monitor := &v2.DiskMonitor{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Namespace: testNamespace}}
+		_, err := client.DiskMonitorV2().DiskMonitors(testNamespace).Create(context.TODO(), monitor, metav1.CreateOptions{})
+		if err == nil {
+			diskMonitors.Informer().GetIndexer().Add(monitor)
+		}
+		return err
+	}
+	r := NewReconciler(client, testNamespace, "node-1", diskMonitors, volumes, status, create)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	// a missing DiskMonitor is created, its status filled in by the next sync
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got, want := writes(client), []string{"create/", "update/status"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("writes = %v, want %v", got, want)
+	}
+	updated, err := client.DiskMonitorV2().DiskMonitors(testNamespace).Get(context.TODO(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if updated.Status.Required == nil || updated.Status.Required.Cmp(required) != 0 {
+		t.Errorf("required = %v, want %s", updated.Status.Required, required.String())
+	}
+	if updated.Status.LastSyncTime == nil || !updated.Status.LastSyncTime.Time.Equal(now) {
+		t.Errorf("lastSyncTime = %v, want %v", updated.Status.LastSyncTime, now)
+	}
+	if !IsConditionTrue(&updated.Status, v2.DiskMonitorReady) || IsConditionTrue(&updated.Status, v2.DiskMonitorReconcileFailed) {
+		t.Errorf("conditions = %+v, want ready", updated.Status.Conditions)
+	}
+
+	// nothing changed, nothing is written
+	diskMonitors.Informer().GetIndexer().Update(updated)
+	client.ClearActions()
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := writes(client); len(got) != 0 {
+		t.Errorf("writes of an unchanged status = %v, want none", got)
+	}
+
+	// the heartbeat is written even though nothing changed
+	now = now.Add(DefaultHeartbeatPeriod)
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got, want := writes(client), []string{"update/status"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("writes of a due heartbeat = %v, want %v", got, want)
+	}
+	updated, _ = client.DiskMonitorV2().DiskMonitors(testNamespace).Get(context.TODO(), "node-1", metav1.GetOptions{})
+	diskMonitors.Informer().GetIndexer().Update(updated)
+
+	// a failure is reported in the conditions and returned for a retry
+	statusErr = errors.New("no pool")
+	if err := r.Sync(); err != statusErr {
+		t.Fatalf("Sync() error = %v, want %v", err, statusErr)
+	}
+	updated, _ = client.DiskMonitorV2().DiskMonitors(testNamespace).Get(context.TODO(), "node-1", metav1.GetOptions{})
+	if !IsConditionTrue(&updated.Status, v2.DiskMonitorReconcileFailed) || IsConditionTrue(&updated.Status, v2.DiskMonitorReady) {
+		t.Errorf("conditions = %+v, want a failed reconcile", updated.Status.Conditions)
+	}
+	if updated.Status.Required == nil || updated.Status.Required.Cmp(required) != 0 {
+		t.Errorf("required = %v, want %s kept", updated.Status.Required, required.String())
+	}
+}
+
+func Test_ReconcilerRun(t *testing.T) {
+	client := diskmonitorfake.NewSimpleClientset()
+	factory := diskmonitorinformers.NewSharedInformerFactory(client, 0)
+	diskMonitors := factory.DiskMonitor().V2().DiskMonitors()
+	volumeFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	volumes := volumeFactory.Core().V1().PersistentVolumes().Informer()
+	r := NewReconciler(client, testNamespace, "node-1", diskMonitors, volumes,
+		func(current *v2.DiskMonitor) (*v2.DiskMonitorStatus, error) { return &current.Status, nil },
+		func() error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	factory.Start(ctx.Done())
+	volumeFactory.Start(ctx.Done())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+	if !r.queue.ShuttingDown() {
+		t.Errorf("queue is not shut down after the context was cancelled")
+	}
+}
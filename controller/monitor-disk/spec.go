@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"fmt"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+// ValidateSpec returns an error when the spec can not be applied: sizes,
+// counts and durations must not be negative.
+func ValidateSpec(spec *v2.DiskMonitorSpec) error {
+	if spec.ReservedHeadroom != nil && spec.ReservedHeadroom.Sign() < 0 {
+		return fmt.Errorf("invalid reservedHeadroom %s: must not be negative", spec.ReservedHeadroom.String())
+	}
+	if spec.MaxVolumes != nil && *spec.MaxVolumes < 0 {
+		return fmt.Errorf("invalid maxVolumes %d: must not be negative", *spec.MaxVolumes)
+	}
+	if spec.GCRetention != nil && spec.GCRetention.Duration < 0 {
+		return fmt.Errorf("invalid gcRetention %s: must not be negative", spec.GCRetention.Duration)
+	}
+	if spec.TrashRetention != nil && spec.TrashRetention.Duration < 0 {
+		return fmt.Errorf("invalid trashRetention %s: must not be negative", spec.TrashRetention.Duration)
+	}
+	return nil
+}
+
+// StorageClassAllowed returns whether the spec lets the node take claims of
+// the storage class.
+func StorageClassAllowed(spec *v2.DiskMonitorSpec, class string) bool {
+	if len(spec.AllowedStorageClasses) == 0 {
+		return true
+	}
+	for _, allowed := range spec.AllowedStorageClasses {
+		if allowed == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+func Test_ValidateSpec(t *testing.T) {
+	headroom := resource.MustParse("10Gi")
+	negativeHeadroom := resource.MustParse("-1Gi")
+	maxVolumes, negativeMaxVolumes := int32(0), int32(-1)
+	tests := []struct {
+		name    string
+		spec    v2.DiskMonitorSpec
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			spec: v2.DiskMonitorSpec{
+				Maintenance:           true,
+				ReservedHeadroom:      &headroom,
+				MaxVolumes:            &maxVolumes,
+				AllowedStorageClasses: []string{"fast"},
+				GCRetention:           &metav1.Duration{Duration: time.Hour},
+				TrashRetention:        &metav1.Duration{Duration: 0},
+			},
+		},
+		{
+			name:    "negative headroom",
+			spec:    v2.DiskMonitorSpec{ReservedHeadroom: &negativeHeadroom},
+			wantErr: true,
+		},
+		{
+			name:    "negative max volumes",
+			spec:    v2.DiskMonitorSpec{MaxVolumes: &negativeMaxVolumes},
+			wantErr: true,
+		},
+		{
+			name:    "negative gc retention",
+			spec:    v2.DiskMonitorSpec{GCRetention: &metav1.Duration{Duration: -time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:    "negative trash retention",
+			spec:    v2.DiskMonitorSpec{TrashRetention: &metav1.Duration{Duration: -time.Hour}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSpec(&tt.spec); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_StorageClassAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		class   string
+		want    bool
+	}{
+		{
+			name:  "every class allowed",
+			class: "slow",
+			want:  true,
+		},
+		{
+			name:    "listed",
+			allowed: []string{"fast", "slow"},
+			class:   "slow",
+			want:    true,
+		},
+		{
+			name:    "not listed",
+			allowed: []string{"fast"},
+			class:   "slow",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &v2.DiskMonitorSpec{AllowedStorageClasses: tt.allowed}
+			if got := StorageClassAllowed(spec, tt.class); got != tt.want {
+				t.Errorf("StorageClassAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	glog "k8s.io/klog"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	listers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+)
+
+// DefaultStaleAfter is how old the heartbeat of a DiskMonitor may get before
+// its node agent is considered gone, a few heartbeat periods.
+const DefaultStaleAfter = 3 * DefaultHeartbeatPeriod
+
+// reasonHeartbeatStale is the reason of the Ready condition of stale DiskMonitors.
+const reasonHeartbeatStale = "HeartbeatStale"
+
+// StaleChecker marks DiskMonitors whose node agent stopped sending heartbeats
+// as not ready. The agent sets Ready again once it is back.
+type StaleChecker struct {
+	client     versioned.Interface
+	namespace  string
+	lister     listers.DiskMonitorLister
+	staleAfter time.Duration
+	now        func() time.Time
+}
+
+// NewStaleChecker returns a StaleChecker of the DiskMonitors in namespace,
+// read from lister.
+func NewStaleChecker(client versioned.Interface, namespace string, lister listers.DiskMonitorLister, staleAfter time.Duration) *StaleChecker {
+	return &StaleChecker{
+		client:     client,
+		namespace:  namespace,
+		lister:     lister,
+		staleAfter: staleAfter,
+		now:        time.Now,
+	}
+}
+
+// Run checks the DiskMonitors every period until stopCh is closed.
+func (c *StaleChecker) Run(period time.Duration, stopCh <-chan struct{}) {
+	glog.Infof("Checking DiskMonitors for heartbeats older than %v", c.staleAfter)
+	wait.Until(c.Check, period, stopCh)
+}
+
+// Check marks the stale DiskMonitors that are not marked yet.
+func (c *StaleChecker) Check() {
+	monitors, err := c.lister.DiskMonitors(c.namespace).List(labels.Everything())
+	if err != nil {
+		glog.Errorf("Failed to list DiskMonitors: %v", err)
+		return
+	}
+	for _, monitor := range monitors {
+		if !c.needsMarking(monitor) {
+			continue
+		}
+		glog.Warningf("DiskMonitor %s/%s has not been reconciled since %v, its node agent is gone", monitor.Namespace, monitor.Name, lastSync(monitor).Time)
+		_, err := ModifyStatus(c.client, monitor.Namespace, monitor.Name, func(status *v2.DiskMonitorStatus) {
+			// the agent may have come back since the DiskMonitor was listed
+			current := &v2.DiskMonitor{ObjectMeta: monitor.ObjectMeta, Status: *status}
+			if c.stale(current) {
+				SetCondition(status, staleCondition(c.staleAfter))
+			}
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			glog.Errorf("Failed to mark DiskMonitor %s/%s as stale: %v", monitor.Namespace, monitor.Name, err)
+		}
+	}
+}
+
+// stale returns whether the heartbeat of the DiskMonitor is too old.
+func (c *StaleChecker) stale(monitor *v2.DiskMonitor) bool {
+	return c.now().Sub(lastSync(monitor).Time) > c.staleAfter
+}
+
+// needsMarking returns whether the DiskMonitor is stale and not marked yet.
+func (c *StaleChecker) needsMarking(monitor *v2.DiskMonitor) bool {
+	if !c.stale(monitor) {
+		return false
+	}
+	ready := FindCondition(&monitor.Status, v2.DiskMonitorReady)
+	return ready == nil || ready.Status != corev1.ConditionFalse || ready.Reason != reasonHeartbeatStale
+}
+
+// lastSync returns the heartbeat of the DiskMonitor, or when it was created
+// if the agent never sent one.
+func lastSync(monitor *v2.DiskMonitor) *metav1.Time {
+	if monitor.Status.LastSyncTime != nil {
+		return monitor.Status.LastSyncTime
+	}
+	return &monitor.CreationTimestamp
+}
+
+func staleCondition(staleAfter time.Duration) v2.DiskMonitorCondition {
+	return v2.DiskMonitorCondition{
+		Type:    v2.DiskMonitorReady,
+		Status:  corev1.ConditionFalse,
+		Reason:  reasonHeartbeatStale,
+		Message: "node agent sent no heartbeat for more than " + staleAfter.String(),
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	diskmonitorfake "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned/fake"
+	listers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+)
+
+func Test_StaleChecker(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(age time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-age))
+		return &t
+	}
+	ready := v2.DiskMonitorCondition{Type: v2.DiskMonitorReady, Status: corev1.ConditionTrue, Reason: "AgentReady"}
+	monitor := func(name string, created time.Duration, lastSync *metav1.Time, conditions ...v2.DiskMonitorCondition) *v2.DiskMonitor {
+		return &v2.DiskMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, CreationTimestamp: *at(created)},
+			Status:     v2.DiskMonitorStatus{LastSyncTime: lastSync, Conditions: conditions},
+		}
+	}
+	tests := []struct {
+		name      string
+		monitor   *v2.DiskMonitor
+		wantStale bool
+		wantWrite bool
+	}{
+		{
+			name:    "recent heartbeat",
+			monitor: monitor("node-1", time.Hour, at(time.Minute), ready),
+		},
+		{
+			name:      "old heartbeat",
+			monitor:   monitor("node-1", time.Hour, at(DefaultStaleAfter+time.Second), ready),
+			wantStale: true,
+			wantWrite: true,
+		},
+		{
+			name:      "already marked",
+			monitor:   monitor("node-1", time.Hour, at(time.Hour), staleCondition(DefaultStaleAfter)),
+			wantStale: true,
+		},
+		{
+			name:    "new without heartbeat",
+			monitor: monitor("node-1", time.Minute, nil),
+		},
+		{
+			name:      "old without heartbeat",
+			monitor:   monitor("node-1", time.Hour, nil),
+			wantStale: true,
+			wantWrite: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := diskmonitorfake.NewSimpleClientset(tt.monitor)
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			indexer.Add(tt.monitor)
+			checker := NewStaleChecker(client, testNamespace, listers.NewDiskMonitorLister(indexer), DefaultStaleAfter)
+			checker.now = func() time.Time { return now }
+
+			checker.Check()
+			if got := len(writes(client)) > 0; got != tt.wantWrite {
+				t.Errorf("written = %v, want %v", got, tt.wantWrite)
+			}
+			got, err := client.DiskMonitorV2().DiskMonitors(testNamespace).Get(context.TODO(), "node-1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			condition := FindCondition(&got.Status, v2.DiskMonitorReady)
+			stale := condition != nil && condition.Status == corev1.ConditionFalse && condition.Reason == reasonHeartbeatStale
+			if stale != tt.wantStale {
+				t.Errorf("ready = %+v, want stale %v", condition, tt.wantStale)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+)
+
+// StatusBackoff is how ModifyStatus retries on conflicts. It allows for more
+// attempts than retry.DefaultRetry, since every provisioning worker of a node
+// writes the same DiskMonitor, and spreads them out with jitter.
+var StatusBackoff = wait.Backoff{
+	Steps:    10,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.5,
+	Jitter:   0.5,
+}
+
+// ModifyStatus applies modify to the status of the DiskMonitor as currently
+// stored and writes it through the status subresource. When the DiskMonitor
+// changed in between, it is read again and modify applied again, so modify
+// must only depend on the status it is given.
+func ModifyStatus(client versioned.Interface, namespace, name string, modify func(status *v2.DiskMonitorStatus)) (*v2.DiskMonitor, error) {
+	var updated *v2.DiskMonitor
+	err := retry.RetryOnConflict(StatusBackoff, func() error {
+		monitor, err := client.DiskMonitorV2().DiskMonitors(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		modify(&monitor.Status)
+		updated, err = client.DiskMonitorV2().DiskMonitors(namespace).UpdateStatus(context.TODO(), monitor, metav1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+// AddDisk records the volume in the status and adds its request to Required
+// and to the totals of its pool. Recording a path again replaces the entry
+// instead of adding it twice.
+func AddDisk(status *v2.DiskMonitorStatus, volume v2.Volume) {
+	required := removeDisk(status, volume.Path)
+	required.Add(volume.Requested)
+	status.Required = &required
+	i := sort.Search(len(status.Volumes), func(i int) bool {
+		return status.Volumes[i].Path >= volume.Path
+	})
+	status.Volumes = append(status.Volumes, v2.Volume{})
+	copy(status.Volumes[i+1:], status.Volumes[i:])
+	status.Volumes[i] = volume
+	SetVolumes(status, status.Volumes)
+}
+
+// RemoveDisk drops the volume at path from the status and subtracts its
+// request from Required and from the totals of its pool. A path that is not
+// recorded is left alone.
+func RemoveDisk(status *v2.DiskMonitorStatus, path string) {
+	required := removeDisk(status, path)
+	status.Required = &required
+	SetVolumes(status, status.Volumes)
+}
+
+// SetVolumes replaces the volumes of the status along with their count and
+// the totals of their pools. Required is left to the caller.
+func SetVolumes(status *v2.DiskMonitorStatus, volumes []v2.Volume) {
+	status.Volumes = volumes
+	status.VolumeCount = int32(len(volumes))
+	status.Pools = SumPools(volumes)
+}
+
+// removeDisk drops the volume at path and returns Required without it.
+func removeDisk(status *v2.DiskMonitorStatus, path string) resource.Quantity {
+	var required resource.Quantity
+	if status.Required != nil {
+		required = status.Required.DeepCopy()
+	}
+	for i := range status.Volumes {
+		if status.Volumes[i].Path != path {
+			continue
+		}
+		required.Sub(status.Volumes[i].Requested)
+		status.Volumes = append(status.Volumes[:i], status.Volumes[i+1:]...)
+		break
+	}
+	if len(status.Volumes) == 0 {
+		status.Volumes = nil
+	}
+	if required.Sign() < 0 {
+		required = resource.Quantity{}
+	}
+	return required
+}
+
+// SumPools returns the totals of the pools of volumes, sorted by name.
+// Volumes without a pool are summed up under the empty name.
+func SumPools(volumes []v2.Volume) []v2.PoolStatus {
+	var pools []v2.PoolStatus
+	index := map[string]int{}
+	for _, volume := range volumes {
+		i, ok := index[volume.Pool]
+		if !ok {
+			i = len(pools)
+			index[volume.Pool] = i
+			pools = append(pools, v2.PoolStatus{Name: volume.Pool})
+		}
+		pools[i].Volumes++
+		pools[i].Requested.Add(volume.Requested)
+		if volume.Used != nil {
+			pools[i].Used.Add(*volume.Used)
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
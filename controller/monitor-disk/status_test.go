@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package monitor_disk
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+func volume(name, pool, requested string) v2.Volume {
+	return v2.Volume{PersistentVolume: name, Pool: pool, Path: "/pv/" + name, Requested: resource.MustParse(requested)}
+}
+
+func Test_SumPools(t *testing.T) {
+	used := resource.MustParse("1Gi")
+	withUsage := volume("c", "fast", "4Gi")
+	withUsage.Used = &used
+	tests := []struct {
+		name    string
+		volumes []v2.Volume
+		want    []v2.PoolStatus
+	}{
+		{
+			name: "no volumes",
+		},
+		{
+			name:    "sorted by name",
+			volumes: []v2.Volume{volume("a", "slow", "2Gi"), volume("b", "", "3Gi"), withUsage, volume("d", "fast", "1Gi")},
+			want: []v2.PoolStatus{
+				{Name: "", Volumes: 1, Requested: resource.MustParse("3Gi")},
+				{Name: "fast", Volumes: 2, Requested: resource.MustParse("5Gi"), Used: resource.MustParse("1Gi")},
+				{Name: "slow", Volumes: 1, Requested: resource.MustParse("2Gi")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SumPools(tt.volumes)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SumPools() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || got[i].Volumes != tt.want[i].Volumes ||
+					got[i].Requested.Cmp(tt.want[i].Requested) != 0 || got[i].Used.Cmp(tt.want[i].Used) != 0 {
+					t.Errorf("SumPools()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_AddRemoveDisk(t *testing.T) {
+	required := resource.MustParse("5Gi")
+	tests := []struct {
+		name         string
+		status       v2.DiskMonitorStatus
+		modify       func(status *v2.DiskMonitorStatus)
+		wantRequired string
+		wantPaths    []string
+	}{
+		{
+			name:   "add to an empty status",
+			status: v2.DiskMonitorStatus{},
+			modify: func(status *v2.DiskMonitorStatus) {
+				AddDisk(status, volume("a", "fast", "2Gi"))
+			},
+			wantRequired: "2Gi",
+			wantPaths:    []string{"/pv/a"},
+		},
+		{
+			name: "add twice",
+			status: v2.DiskMonitorStatus{
+				Required: &required,
+				Volumes:  []v2.Volume{volume("b", "", "5Gi")},
+				Pools:    []v2.PoolStatus{{Name: "", Volumes: 1, Requested: resource.MustParse("5Gi")}},
+			},
+			modify: func(status *v2.DiskMonitorStatus) {
+				AddDisk(status, volume("a", "fast", "2Gi"))
+				AddDisk(status, volume("a", "fast", "2Gi"))
+			},
+			wantRequired: "7Gi",
+			wantPaths:    []string{"/pv/a", "/pv/b"},
+		},
+		{
+			name: "remove twice",
+			status: v2.DiskMonitorStatus{
+				Required: &required,
+				Volumes:  []v2.Volume{volume("b", "", "5Gi")},
+				Pools:    []v2.PoolStatus{{Name: "", Volumes: 1, Requested: resource.MustParse("5Gi")}},
+			},
+			modify: func(status *v2.DiskMonitorStatus) {
+				RemoveDisk(status, "/pv/b")
+				RemoveDisk(status, "/pv/b")
+			},
+			wantRequired: "0",
+		},
+		{
+			name: "remove an unknown path",
+			status: v2.DiskMonitorStatus{
+				Required: &required,
+				Volumes:  []v2.Volume{volume("b", "", "5Gi")},
+				Pools:    []v2.PoolStatus{{Name: "", Volumes: 1, Requested: resource.MustParse("5Gi")}},
+			},
+			modify: func(status *v2.DiskMonitorStatus) {
+				RemoveDisk(status, "/pv/c")
+			},
+			wantRequired: "5Gi",
+			wantPaths:    []string{"/pv/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.status.DeepCopy()
+			tt.modify(status)
+			if status.Required == nil || status.Required.Cmp(resource.MustParse(tt.wantRequired)) != 0 {
+				t.Errorf("required = %v, want %s", status.Required, tt.wantRequired)
+			}
+			var paths []string
+			for _, volume := range status.Volumes {
+				paths = append(paths, volume.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("paths = %v, want %v", paths, tt.wantPaths)
+			}
+			if !reflect.DeepEqual(status.Pools, SumPools(status.Volumes)) {
+				t.Errorf("pools = %v, want the totals of %v", status.Pools, status.Volumes)
+			}
+			if int(status.VolumeCount) != len(status.Volumes) {
+				t.Errorf("volumeCount = %d, want %d", status.VolumeCount, len(status.Volumes))
+			}
+			if tt.status.Required != nil && tt.status.Required.Cmp(required) != 0 {
+				t.Errorf("the original status was modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const (
+	// DefaultPool is the name of the pool when none is configured.
+	DefaultPool = "default"
+	// ResourcePrefix prefixes the extended resource of a pool, the resource
+	// of pool "fast" is "hostpath.kubevirt.io/fast".
+	ResourcePrefix = "hostpath.kubevirt.io/"
+	// PoolLabelPrefix prefixes the label marking that a node has a pool, the
+	// label of pool "fast" is "pool.hostpath.kubevirt.io/fast=true".
+	PoolLabelPrefix = "pool.hostpath.kubevirt.io/"
+)
+
+// ResourceName returns the extended resource of the pool.
+func ResourceName(pool string) v1.ResourceName {
+	return v1.ResourceName(ResourcePrefix + pool)
+}
+
+// PoolLabel returns the node label marking the pool.
+func PoolLabel(pool string) string {
+	return PoolLabelPrefix + pool
+}
+
+// ValidatePool returns an error when the pool name can not be used in a
+// resource name or a label key.
+func ValidatePool(pool string) error {
+	if errs := validation.IsQualifiedName(string(ResourceName(pool))); len(errs) > 0 {
+		return fmt.Errorf("invalid pool name %q: %s", pool, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsQualifiedName(PoolLabel(pool)); len(errs) > 0 {
+		return fmt.Errorf("invalid pool name %q: %s", pool, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Advertiser keeps the extended resource and the label of a pool on its Node.
+// The allocatable amount of the resource is what the capacity policy still
+// hands out, the same figure ShouldProvision admits claims against, and its
+// capacity that plus what the volumes on the pool requested.
+type Advertiser struct {
+	client   kubernetes.Interface
+	nodeName string
+	pool     string
+}
+
+// NewAdvertiser returns an Advertiser for the pool on the node.
+func NewAdvertiser(client kubernetes.Interface, nodeName, pool string) *Advertiser {
+	return &Advertiser{
+		client:   client,
+		nodeName: nodeName,
+		pool:     pool,
+	}
+}
+
+// Advertise patches the Node when its label or resource amounts differ from
+// the pool. node is the last known state of the Node, nil forces the patches.
+func (a *Advertiser) Advertise(node *v1.Node, poolCapacity capacity.Capacity) error {
+	resourceName := ResourceName(a.pool)
+	total := resource.NewQuantity(poolCapacity.Requested+poolCapacity.Allocatable, resource.BinarySI)
+	allocatable := resource.NewQuantity(poolCapacity.Allocatable, resource.BinarySI)
+
+	if node == nil || node.Labels[PoolLabel(a.pool)] != "true" {
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": map[string]string{PoolLabel(a.pool): "true"},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := a.client.CoreV1().Nodes().Patch(context.TODO(), a.nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+	}
+
+	if node != nil && equal(node.Status.Capacity, resourceName, total) && equal(node.Status.Allocatable, resourceName, allocatable) {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"capacity":    map[string]string{string(resourceName): total.String()},
+			"allocatable": map[string]string{string(resourceName): allocatable.String()},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.client.CoreV1().Nodes().Patch(context.TODO(), a.nodeName, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
+func equal(list v1.ResourceList, name v1.ResourceName, value *resource.Quantity) bool {
+	current, ok := list[name]
+	return ok && current.Cmp(*value) == 0
+}
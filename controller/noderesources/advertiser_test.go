@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesources
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const GiB = 1024 * 1024 * 1024
+
+func Test_ValidatePool(t *testing.T) {
+	tests := []struct {
+		pool    string
+		wantErr bool
+	}{
+		{DefaultPool, false},
+		{"fast-pool", false},
+		{"", true},
+		{"Fast Pool", true},
+		{"a/b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pool, func(t *testing.T) {
+			if err := ValidatePool(tt.pool); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePool(%q) error = %v, wantErr %v", tt.pool, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Advertise(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"kubernetes.io/hostname": "node-1"}},
+		Status: v1.NodeStatus{
+			Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+		},
+	})
+	patches := 0
+	client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patches++
+		return false, nil, nil
+	})
+	advertiser := NewAdvertiser(client, "node-1", "fast-pool")
+	getNode := func() *v1.Node {
+		node, err := client.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return node
+	}
+	poolCapacity := capacity.Capacity{Total: 100 * GiB, Requested: 30 * GiB, Allocatable: 60 * GiB}
+
+	if err := advertiser.Advertise(getNode(), poolCapacity); err != nil {
+		t.Fatalf("Advertise() error = %v", err)
+	}
+	node := getNode()
+	if node.Labels[PoolLabel("fast-pool")] != "true" || node.Labels["kubernetes.io/hostname"] != "node-1" {
+		t.Errorf("labels = %v", node.Labels)
+	}
+	resourceName := ResourceName("fast-pool")
+	if got := node.Status.Capacity[resourceName]; got.Cmp(resource.MustParse("90Gi")) != 0 {
+		t.Errorf("capacity = %s, want 90Gi", got.String())
+	}
+	if got := node.Status.Allocatable[resourceName]; got.Cmp(resource.MustParse("60Gi")) != 0 {
+		t.Errorf("allocatable = %s, want 60Gi", got.String())
+	}
+	if _, ok := node.Status.Capacity[v1.ResourceCPU]; !ok {
+		t.Errorf("other resources must be kept, got %v", node.Status.Capacity)
+	}
+	if patches != 2 {
+		t.Errorf("Advertise() patched %d times, want 2", patches)
+	}
+
+	// nothing changed
+	if err := advertiser.Advertise(node, poolCapacity); err != nil {
+		t.Fatalf("Advertise() error = %v", err)
+	}
+	if patches != 2 {
+		t.Errorf("Advertise() of an unchanged pool should not patch, patched %d times", patches)
+	}
+
+	poolCapacity.Requested += 10 * GiB
+	poolCapacity.Allocatable -= 10 * GiB
+	if err := advertiser.Advertise(node, poolCapacity); err != nil {
+		t.Fatalf("Advertise() error = %v", err)
+	}
+	if got := getNode().Status.Allocatable[resourceName]; got.Cmp(resource.MustParse("50Gi")) != 0 {
+		t.Errorf("allocatable = %s, want 50Gi", got.String())
+	}
+	if patches != 3 {
+		t.Errorf("Advertise() should only patch the status, patched %d times", patches)
+	}
+}
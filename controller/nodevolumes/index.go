@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodevolumes
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// AnnProvisionOnNode is the annotation carrying the node a volume was
+	// provisioned on.
+	AnnProvisionOnNode = "kubevirt.io/provisionOnNode"
+	// AnnPool is the annotation of a claim naming the pool it is placed in,
+	// the provisioner copies it to the PV of the claim.
+	AnnPool = "hostpath.kubevirt.io/pool"
+	// AnnPermissions is the annotation of a claim carrying the octal mode of
+	// the directory backing its volume, such as "0770".
+	AnnPermissions = "hostpath.kubevirt.io/permissions"
+
+	// NodeIndex indexes PVs by the node they were provisioned on.
+	NodeIndex = "node"
+	// StorageClassIndex indexes PVs by storage class name.
+	StorageClassIndex = "storageClass"
+)
+
+// Index answers per node volume queries from a shared PV informer instead of
+// listing the PVs of the cluster.
+type Index struct {
+	informer cache.SharedIndexInformer
+}
+
+// New adds the node and storage class indexers to the PV informer and returns
+// an Index reading from it. It must be called before the informer is started.
+func New(informer cache.SharedIndexInformer) (*Index, error) {
+	err := informer.AddIndexers(cache.Indexers{
+		NodeIndex: func(obj interface{}) ([]string, error) {
+			if pv, ok := obj.(*v1.PersistentVolume); ok {
+				if node := pv.Annotations[AnnProvisionOnNode]; node != "" {
+					return []string{node}, nil
+				}
+			}
+			return nil, nil
+		},
+		StorageClassIndex: func(obj interface{}) ([]string, error) {
+			if pv, ok := obj.(*v1.PersistentVolume); ok {
+				return []string{pv.Spec.StorageClassName}, nil
+			}
+			return nil, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Index{informer: informer}, nil
+}
+
+// Informer returns the PV informer backing the index.
+func (i *Index) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+// HasSynced returns whether the PV informer has synced.
+func (i *Index) HasSynced() bool {
+	return i.informer.HasSynced()
+}
+
+// ByNode returns the PVs provisioned on the node.
+func (i *Index) ByNode(node string) []*v1.PersistentVolume {
+	return i.byIndex(NodeIndex, node)
+}
+
+// ByStorageClass returns the PVs of the storage class.
+func (i *Index) ByStorageClass(storageClass string) []*v1.PersistentVolume {
+	return i.byIndex(StorageClassIndex, storageClass)
+}
+
+func (i *Index) byIndex(indexName, key string) []*v1.PersistentVolume {
+	objs, err := i.informer.GetIndexer().ByIndex(indexName, key)
+	if err != nil {
+		// only possible when the indexer is missing, which New guarantees against
+		return nil
+	}
+	pvs := make([]*v1.PersistentVolume, 0, len(objs))
+	for _, obj := range objs {
+		if pv, ok := obj.(*v1.PersistentVolume); ok {
+			pvs = append(pvs, pv)
+		}
+	}
+	return pvs
+}
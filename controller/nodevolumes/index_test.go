@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodevolumes
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newVolume(name, node, class string) *v1.PersistentVolume {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.PersistentVolumeSpec{StorageClassName: class},
+	}
+	if node != "" {
+		pv.Annotations = map[string]string{AnnProvisionOnNode: node}
+	}
+	return pv
+}
+
+func names(pvs []*v1.PersistentVolume) []string {
+	var names []string
+	for _, pv := range pvs {
+		names = append(names, pv.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func Test_Index(t *testing.T) {
+	informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().PersistentVolumes().Informer()
+	index, err := New(informer)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, obj := range []*v1.PersistentVolume{
+		newVolume("pv-a", "node-1", "hostpath"),
+		newVolume("pv-b", "node-1", "hostpath-fast"),
+		newVolume("pv-c", "node-2", "hostpath"),
+		// no node annotation, such as a PV of another provisioner
+		newVolume("pv-nfs", "", "nfs"),
+		newVolume("pv-static", "", ""),
+	} {
+		if err := informer.GetIndexer().Add(obj); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		got  []*v1.PersistentVolume
+		want []string
+	}{
+		{name: "volumes of a node", got: index.ByNode("node-1"), want: []string{"pv-a", "pv-b"}},
+		{name: "volumes of another node", got: index.ByNode("node-2"), want: []string{"pv-c"}},
+		{name: "no volumes on an unknown node", got: index.ByNode("node-3")},
+		{name: "volumes without node annotation are on no node", got: index.ByNode("")},
+		{name: "volumes of a storage class", got: index.ByStorageClass("hostpath"), want: []string{"pv-a", "pv-c"}},
+		{name: "volumes of another provisioner", got: index.ByStorageClass("nfs"), want: []string{"pv-nfs"}},
+		{name: "volumes without storage class", got: index.ByStorageClass(""), want: []string{"pv-static"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(tt.got); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("volumes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_IndexFuncs(t *testing.T) {
+	informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().PersistentVolumes().Informer()
+	if _, err := New(informer); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	indexers := informer.GetIndexer().GetIndexers()
+	tests := []struct {
+		name      string
+		obj       interface{}
+		wantNode  []string
+		wantClass []string
+	}{
+		{
+			name:      "hostpath volume",
+			obj:       newVolume("pv-a", "node-1", "hostpath"),
+			wantNode:  []string{"node-1"},
+			wantClass: []string{"hostpath"},
+		},
+		{
+			name:      "volume without node annotation",
+			obj:       newVolume("pv-nfs", "", "nfs"),
+			wantClass: []string{"nfs"},
+		},
+		{
+			name: "tombstone of a volume",
+			obj:  cache.DeletedFinalStateUnknown{Key: "pv-gone", Obj: newVolume("pv-gone", "node-1", "hostpath")},
+		},
+		{
+			name: "not a volume",
+			obj:  &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "claim"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := indexers[NodeIndex](tt.obj)
+			if err != nil || !reflect.DeepEqual(node, tt.wantNode) {
+				t.Errorf("%s index = %v, %v, want %v", NodeIndex, node, err, tt.wantNode)
+			}
+			class, err := indexers[StorageClassIndex](tt.obj)
+			if err != nil || !reflect.DeepEqual(class, tt.wantClass) {
+				t.Errorf("%s index = %v, %v, want %v", StorageClassIndex, class, err, tt.wantClass)
+			}
+		})
+	}
+}
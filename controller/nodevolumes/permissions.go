@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodevolumes
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ParsePermissions parses the value of AnnPermissions, an octal mode of up to
+// four digits without setuid, setgid or sticky bits, such as "0770" or "750".
+func ParsePermissions(value string) (os.FileMode, error) {
+	if len(value) < 3 || len(value) > 4 {
+		return 0, fmt.Errorf("invalid permissions %q, must be an octal mode such as 0770", value)
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid permissions %q, must be an octal mode such as 0770", value)
+	}
+	return os.FileMode(mode), nil
+}
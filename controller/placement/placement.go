@@ -0,0 +1,424 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placement
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	glog "k8s.io/klog"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/util"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+// AnnNodeSelector is the annotation of a claim restricting the nodes it may be
+// assigned to, as a label selector such as "disktype=ssd,zone!=b".
+const AnnNodeSelector = "hostpath.kubevirt.io/node-selector"
+
+// Strategy selects which of the nodes that can hold a claim it is assigned to.
+type Strategy string
+
+const (
+	// StrategyLeastUsed picks the node with the most capacity left after
+	// placing the claim.
+	StrategyLeastUsed Strategy = "least-used"
+	// StrategyBinPack picks the node with the least capacity left after
+	// placing the claim, filling pools up before using new ones.
+	StrategyBinPack Strategy = "bin-pack"
+	// StrategySpread picks the node with the fewest hostpath volumes.
+	StrategySpread Strategy = "spread"
+)
+
+// DefaultStrategy is used when no strategy is configured.
+const DefaultStrategy = StrategyLeastUsed
+
+// ParseStrategy returns the strategy named s, or DefaultStrategy for "".
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case "":
+		return DefaultStrategy, nil
+	case StrategyLeastUsed, StrategyBinPack, StrategySpread:
+		return Strategy(s), nil
+	}
+	return "", fmt.Errorf("unknown placement strategy %q, must be %s, %s or %s", s, StrategyLeastUsed, StrategyBinPack, StrategySpread)
+}
+
+type assignment struct {
+	node string
+	size int64
+}
+
+// candidate is a node that can hold a claim.
+type candidate struct {
+	name    string
+	left    int64
+	volumes int
+}
+
+// Placer sets the kubevirt.io/provisionOnNode annotation on unbound Immediate
+// binding claims of the provisioner. Claims are assigned to a node that
+// matches their node selector and whose pool can hold them, the node agent
+// then provisions the volume. The members of a co-location group are
+// assigned together, to a node that can hold all of them.
+type Placer struct {
+	client          kubernetes.Interface
+	provisionerName string
+	strategy        Strategy
+	claims          corelisters.PersistentVolumeClaimLister
+	claimsIndexer   cache.Indexer
+	claimsSynced    cache.InformerSynced
+	groups          *claimgroups.Groups
+	classes         storagelisters.StorageClassLister
+	nodes           corelisters.NodeLister
+	volumes         *nodevolumes.Index
+	capacities      capacity.NodeCapacityFunc
+	admit           AdmitFunc
+	recorder        record.EventRecorder
+	queue           workqueue.RateLimitingInterface
+
+	mu sync.Mutex
+	// pending holds the claims assigned to a node that are not bound yet, keyed
+	// by claim key. The capacity the nodes report does not include them yet.
+	pending map[string]assignment
+}
+
+// New returns a Placer for the claims of provisionerName. groups must read
+// from claimInformer. admit may be nil.
+func New(client kubernetes.Interface, provisionerName string, strategy Strategy, claimInformer coreinformers.PersistentVolumeClaimInformer, groups *claimgroups.Groups,
+	classes storagelisters.StorageClassLister, nodes corelisters.NodeLister, volumes *nodevolumes.Index, capacities capacity.NodeCapacityFunc, admit AdmitFunc,
+	recorder record.EventRecorder) *Placer {
+	p := &Placer{
+		client:          client,
+		provisionerName: provisionerName,
+		strategy:        strategy,
+		claims:          claimInformer.Lister(),
+		claimsIndexer:   claimInformer.Informer().GetIndexer(),
+		claimsSynced:    claimInformer.Informer().HasSynced,
+		groups:          groups,
+		classes:         classes,
+		nodes:           nodes,
+		volumes:         volumes,
+		capacities:      capacities,
+		admit:           admit,
+		recorder:        recorder,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "placement"),
+		pending:         map[string]assignment{},
+	}
+	claimInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    p.enqueue,
+		UpdateFunc: func(_, newObj interface{}) { p.enqueue(newObj) },
+		DeleteFunc: p.enqueue,
+	})
+	return p
+}
+
+func (p *Placer) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	p.queue.Add(key)
+}
+
+// Run assigns claims until stopCh is closed.
+func (p *Placer) Run(threadiness int, stopCh <-chan struct{}) {
+	defer p.queue.ShutDown()
+	if !cache.WaitForCacheSync(stopCh, p.claimsSynced) {
+		return
+	}
+	for i := 0; i < threadiness; i++ {
+		go wait.Until(p.runWorker, time.Second, stopCh)
+	}
+	glog.Infof("Started placement with strategy %s", p.strategy)
+	<-stopCh
+}
+
+func (p *Placer) runWorker() {
+	for p.processNextWorkItem() {
+	}
+}
+
+func (p *Placer) processNextWorkItem() bool {
+	obj, shutdown := p.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer p.queue.Done(obj)
+	key := obj.(string)
+	if err := p.sync(key); err != nil {
+		glog.Warningf("Retrying placement of claim %q: %v", key, err)
+		p.queue.AddRateLimited(obj)
+		return true
+	}
+	p.queue.Forget(obj)
+	return true
+}
+
+// sync assigns the claim to a node if it needs one.
+func (p *Placer) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	claim, err := p.claims.PersistentVolumeClaims(namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		p.forget(key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if claim.Spec.VolumeName != "" {
+		p.forget(key)
+		return nil
+	}
+	if _, ok := claim.Annotations[nodevolumes.AnnProvisionOnNode]; ok {
+		return nil
+	}
+	if ok, err := p.isImmediateClaim(claim); err != nil || !ok {
+		return err
+	}
+
+	selector := labels.Everything()
+	if s, ok := claim.Annotations[AnnNodeSelector]; ok {
+		if selector, err = labels.Parse(s); err != nil {
+			// fixing the annotation updates the claim, there is no point in retrying
+			p.recorder.Eventf(claim, v1.EventTypeWarning, "NodeAssignmentFailed", "invalid %s annotation: %v", AnnNodeSelector, err)
+			return nil
+		}
+	}
+	constraint := p.groups.Constraint(claim)
+	if constraint.Conflict != "" {
+		// resolving the conflict updates a claim of the group
+		p.recorder.Event(claim, v1.EventTypeWarning, "NodeAssignmentFailed", constraint.Conflict)
+		return nil
+	}
+	node, err := p.selectNode(selector, util.GetPersistentVolumeClaimClass(claim), p.unaccounted(constraint), constraint)
+	if err != nil {
+		p.recorder.Event(claim, v1.EventTypeWarning, "NodeAssignmentFailed", err.Error())
+		return err
+	}
+
+	// The other members of the group waiting for a node go along, so none of
+	// them is provisioned before the group as a whole has a node.
+	for _, member := range constraint.Pending {
+		if member.UID != claim.UID {
+			if _, ok := member.Annotations[nodevolumes.AnnProvisionOnNode]; ok {
+				continue
+			}
+			if ok, err := p.isImmediateClaim(member); err != nil || !ok {
+				continue
+			}
+		}
+		if err := p.assign(member, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign sets the node annotation on the claim.
+func (p *Placer) assign(claim *v1.PersistentVolumeClaim, node string) error {
+	newClaim := claim.DeepCopy()
+	metav1.SetMetaDataAnnotation(&newClaim.ObjectMeta, nodevolumes.AnnProvisionOnNode, node)
+	updatedClaim, err := p.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Update(context.TODO(), newClaim, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	// Group and anti-affinity lookups of the next claims must see the node
+	// before the informer catches up.
+	if err := p.claimsIndexer.Update(updatedClaim); err != nil {
+		glog.Warningf("failed to update claim %s/%s in the informer cache: %v", claim.Namespace, claim.Name, err)
+	}
+	key, _ := cache.MetaNamespaceKeyFunc(claim)
+	p.mu.Lock()
+	p.pending[key] = assignment{node: node, size: claim.Spec.Resources.Requests.Storage().Value()}
+	p.mu.Unlock()
+	glog.Infof("assigned claim %s to node %s", key, node)
+	p.recorder.Eventf(claim, v1.EventTypeNormal, "NodeAssigned", "Assigned to node %s by %s placement", node, p.strategy)
+	return nil
+}
+
+// unaccounted returns the requests of the pending members of the group that
+// are not accounted for as pending assignments already.
+func (p *Placer) unaccounted(constraint *claimgroups.Constraint) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	requested := constraint.Requested
+	for _, member := range constraint.Pending {
+		key, _ := cache.MetaNamespaceKeyFunc(member)
+		if _, ok := p.pending[key]; ok {
+			requested -= member.Spec.Resources.Requests.Storage().Value()
+		}
+	}
+	return requested
+}
+
+func (p *Placer) forget(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, key)
+}
+
+// isImmediateClaim returns whether the claim belongs to the provisioner and
+// is bound immediately, WaitForFirstConsumer claims are placed by the scheduler.
+func (p *Placer) isImmediateClaim(claim *v1.PersistentVolumeClaim) (bool, error) {
+	className := util.GetPersistentVolumeClaimClass(claim)
+	if className == "" {
+		return false, nil
+	}
+	class, err := p.classes.Get(className)
+	if apierrs.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if class.Provisioner != p.provisionerName {
+		return false, nil
+	}
+	return class.VolumeBindingMode == nil || *class.VolumeBindingMode == storage.VolumeBindingImmediate, nil
+}
+
+// selectNode returns the node matching selector, allowed by constraint and
+// admitting claims of class that the strategy picks out of the nodes that can
+// hold size, taking the pending assignments into account.
+func (p *Placer) selectNode(selector labels.Selector, class string, size int64, constraint *claimgroups.Constraint) (string, error) {
+	p.mu.Lock()
+	pendingSize := map[string]int64{}
+	pendingVolumes := map[string]int{}
+	for _, a := range p.pending {
+		pendingSize[a.node] += a.size
+		pendingVolumes[a.node]++
+	}
+	p.mu.Unlock()
+	picker := &NodePicker{
+		Strategy:   p.strategy,
+		Nodes:      p.nodes,
+		Volumes:    p.volumes,
+		Capacities: p.capacities,
+		Admit:      p.admit,
+	}
+	return picker.Pick(selector, class, size, constraint, pendingSize, pendingVolumes)
+}
+
+// AdmitFunc returns why the node takes no new volume of the storage class
+// while it holds volumes volumes, or "" when it does. It is the check the
+// node agent makes before provisioning a claim.
+type AdmitFunc func(node *v1.Node, class string, volumes int) string
+
+// NodePicker picks a node for a claim out of the nodes whose pools can hold
+// it, according to a Strategy.
+type NodePicker struct {
+	Strategy   Strategy
+	Nodes      corelisters.NodeLister
+	Volumes    *nodevolumes.Index
+	Capacities capacity.NodeCapacityFunc
+	// Admit skips the nodes that would refuse the claim, only cordoned nodes
+	// are skipped when it is nil.
+	Admit AdmitFunc
+}
+
+// Pick returns the node matching selector, allowed by constraint and admitting
+// claims of class that the strategy picks out of the nodes that can hold size.
+// pendingSize and pendingVolumes hold, per node, what is assigned but not
+// reported by the node yet, either may be nil.
+func (n *NodePicker) Pick(selector labels.Selector, class string, size int64, constraint *claimgroups.Constraint, pendingSize map[string]int64, pendingVolumes map[string]int) (string, error) {
+	nodes, err := n.Nodes.List(selector)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []candidate
+	var maxAllocatable int64
+	for _, node := range nodes {
+		if constraint.Allows(node.Name) != "" {
+			continue
+		}
+		volumes := len(n.Volumes.ByNode(node.Name)) + pendingVolumes[node.Name]
+		if n.Admit == nil && node.Spec.Unschedulable || n.Admit != nil && n.Admit(node, class, volumes) != "" {
+			continue
+		}
+		nodeCapacity, ok := n.Capacities(node.Name)
+		if !ok {
+			continue
+		}
+		allocatable := nodeCapacity.Allocatable - pendingSize[node.Name]
+		if allocatable > maxAllocatable {
+			maxAllocatable = allocatable
+		}
+		if size > allocatable {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			name:    node.Name,
+			left:    allocatable - size,
+			volumes: volumes,
+		})
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no node can hold %s, the most allocatable capacity of %d matching nodes is %s",
+			resource.NewQuantity(size, resource.BinarySI).String(), len(nodes), resource.NewQuantity(maxAllocatable, resource.BinarySI).String())
+	}
+	return n.choose(candidates), nil
+}
+
+// choose returns the candidate the strategy prefers, ties are broken by name.
+func (n *NodePicker) choose(candidates []candidate) string {
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch n.Strategy {
+		case StrategyBinPack:
+			if a.left != b.left {
+				return a.left < b.left
+			}
+		case StrategySpread:
+			if a.volumes != b.volumes {
+				return a.volumes < b.volumes
+			}
+			if a.left != b.left {
+				return a.left > b.left
+			}
+		default:
+			if a.left != b.left {
+				return a.left > b.left
+			}
+		}
+		return a.name < b.name
+	})
+	return candidates[0].name
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package placement
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const (
+	testProvisioner = "kubevirt.io/hostpath-provisioner"
+	GiB             = int64(1024 * 1024 * 1024)
+)
+
+func newNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func newClaim(name, class, size string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &class,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func newTestPlacer(t *testing.T, strategy Strategy, claims ...*v1.PersistentVolumeClaim) (*Placer, *fake.Clientset, *record.FakeRecorder) {
+	immediate := storage.VolumeBindingImmediate
+	waitForFirstConsumer := storage.VolumeBindingWaitForFirstConsumer
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	claimInformer := factory.Core().V1().PersistentVolumeClaims()
+	groups, err := claimgroups.New(claimInformer.Informer(), factory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		t.Fatalf("claimgroups.New() error = %v", err)
+	}
+	for _, claim := range claims {
+		client.Tracker().Add(claim)
+		claimInformer.Informer().GetIndexer().Add(claim)
+	}
+	classes := factory.Storage().V1().StorageClasses().Informer().GetIndexer()
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "immediate"}, Provisioner: testProvisioner, VolumeBindingMode: &immediate})
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "wffc"}, Provisioner: testProvisioner, VolumeBindingMode: &waitForFirstConsumer})
+	nodes := factory.Core().V1().Nodes().Informer().GetIndexer()
+	nodes.Add(newNode("node-1", nil))
+	nodes.Add(newNode("node-2", nil))
+	nodes.Add(newNode("node-3", map[string]string{"disktype": "ssd"}))
+	volumes, err := nodevolumes.New(factory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	volumes.Informer().GetIndexer().Add(&v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{
+		Name:        "existing",
+		Annotations: map[string]string{nodevolumes.AnnProvisionOnNode: "node-1"},
+	}})
+
+	allocatable := map[string]int64{"node-1": 100 * GiB, "node-2": 50 * GiB, "node-3": 20 * GiB}
+	capacities := func(nodeName string) (capacity.Capacity, bool) {
+		a, ok := allocatable[nodeName]
+		return capacity.Capacity{Allocatable: a}, ok
+	}
+	recorder := record.NewFakeRecorder(10)
+	p := New(client, testProvisioner, strategy, claimInformer, groups, factory.Storage().V1().StorageClasses().Lister(),
+		factory.Core().V1().Nodes().Lister(), volumes, capacities, nil, recorder)
+	return p, client, recorder
+}
+
+func Test_sync(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  Strategy
+		claim     *v1.PersistentVolumeClaim
+		wantNode  string
+		wantEvent string
+		wantErr   bool
+	}{
+		{
+			name:      "least used picks the emptiest pool",
+			strategy:  StrategyLeastUsed,
+			claim:     newClaim("claim", "immediate", "10Gi", nil),
+			wantNode:  "node-1",
+			wantEvent: "NodeAssigned",
+		},
+		{
+			name:      "bin pack picks the fullest pool",
+			strategy:  StrategyBinPack,
+			claim:     newClaim("claim", "immediate", "10Gi", nil),
+			wantNode:  "node-3",
+			wantEvent: "NodeAssigned",
+		},
+		{
+			name:      "spread picks the node with the fewest volumes",
+			strategy:  StrategySpread,
+			claim:     newClaim("claim", "immediate", "10Gi", nil),
+			wantNode:  "node-2",
+			wantEvent: "NodeAssigned",
+		},
+		{
+			name:      "node selector restricts the nodes",
+			strategy:  StrategyLeastUsed,
+			claim:     newClaim("claim", "immediate", "10Gi", map[string]string{AnnNodeSelector: "disktype=ssd"}),
+			wantNode:  "node-3",
+			wantEvent: "NodeAssigned",
+		},
+		{
+			name:      "claim that fits nowhere is retried",
+			strategy:  StrategyLeastUsed,
+			claim:     newClaim("claim", "immediate", "200Gi", nil),
+			wantEvent: "NodeAssignmentFailed",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid node selector is reported",
+			strategy:  StrategyLeastUsed,
+			claim:     newClaim("claim", "immediate", "10Gi", map[string]string{AnnNodeSelector: "disktype in ssd"}),
+			wantEvent: "NodeAssignmentFailed",
+		},
+		{
+			name:     "wait for first consumer claim is left to the scheduler",
+			strategy: StrategyLeastUsed,
+			claim:    newClaim("claim", "wffc", "10Gi", nil),
+		},
+		{
+			name:     "claim with a node is left alone",
+			strategy: StrategyLeastUsed,
+			claim:    newClaim("claim", "immediate", "10Gi", map[string]string{nodevolumes.AnnProvisionOnNode: "node-2"}),
+			wantNode: "node-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client, recorder := newTestPlacer(t, tt.strategy, tt.claim)
+			if err := p.sync("default/claim"); (err != nil) != tt.wantErr {
+				t.Errorf("sync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			claim, err := client.CoreV1().PersistentVolumeClaims("default").Get(context.TODO(), "claim", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get claim: %v", err)
+			}
+			if got := claim.Annotations[nodevolumes.AnnProvisionOnNode]; got != tt.wantNode {
+				t.Errorf("sync() assigned node %q, want %q", got, tt.wantNode)
+			}
+			select {
+			case event := <-recorder.Events:
+				if tt.wantEvent == "" || !strings.Contains(event, tt.wantEvent) {
+					t.Errorf("sync() event = %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("sync() recorded no event, want %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func Test_syncAccountsPendingAssignments(t *testing.T) {
+	p, _, _ := newTestPlacer(t, StrategyLeastUsed, newClaim("claim", "immediate", "60Gi", nil))
+	p.pending["default/other"] = assignment{node: "node-1", size: 50 * GiB}
+	if err := p.sync("default/claim"); err == nil {
+		t.Errorf("sync() should not place the claim on node-1 while other claims are pending there")
+	}
+}
+
+func Test_syncSkipsNodesRefusingTheClaim(t *testing.T) {
+	p, client, _ := newTestPlacer(t, StrategyLeastUsed, newClaim("claim", "immediate", "10Gi", nil))
+	p.admit = func(node *v1.Node, class string, volumes int) string {
+		if node.Name == "node-1" && class == "immediate" && volumes == 1 {
+			return "DiskMonitor does not allow storage class immediate"
+		}
+		return ""
+	}
+	if err := p.sync("default/claim"); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	claim, err := client.CoreV1().PersistentVolumeClaims("default").Get(context.TODO(), "claim", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get claim: %v", err)
+	}
+	if got := claim.Annotations[nodevolumes.AnnProvisionOnNode]; got != "node-2" {
+		t.Errorf("sync() assigned node %q, want node-2", got)
+	}
+}
+
+func Test_ParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy(""); err != nil || s != DefaultStrategy {
+		t.Errorf("ParseStrategy(\"\") = %v, %v", s, err)
+	}
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Errorf("ParseStrategy() should reject unknown strategies")
+	}
+}
+
+func Test_syncGroup(t *testing.T) {
+	group := map[string]string{claimgroups.AnnGroup: "vm"}
+	tests := []struct {
+		name     string
+		strategy Strategy
+		claims   []*v1.PersistentVolumeClaim
+		want     map[string]string
+	}{
+		{
+			name:     "group is assigned as a whole to a node that holds all members",
+			strategy: StrategyBinPack,
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "immediate", "15Gi", group),
+				newClaim("b", "immediate", "15Gi", group),
+				newClaim("c", "wffc", "15Gi", group),
+			},
+			// node-3 holds each member but not the group, the scheduler follows with c
+			want: map[string]string{"a": "node-2", "b": "node-2", "c": ""},
+		},
+		{
+			name:     "group follows a member that has a node",
+			strategy: StrategyLeastUsed,
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "immediate", "10Gi", group),
+				newClaim("b", "immediate", "10Gi", map[string]string{claimgroups.AnnGroup: "vm", nodevolumes.AnnProvisionOnNode: "node-3"}),
+			},
+			want: map[string]string{"a": "node-3", "b": "node-3"},
+		},
+		{
+			name:     "anti-affinity avoids the nodes of the other replicas",
+			strategy: StrategyLeastUsed,
+			claims: []*v1.PersistentVolumeClaim{
+				newClaim("a", "immediate", "10Gi", map[string]string{claimgroups.AnnAntiAffinity: "db"}),
+				newClaim("b", "immediate", "10Gi", map[string]string{claimgroups.AnnAntiAffinity: "db", nodevolumes.AnnProvisionOnNode: "node-1"}),
+			},
+			want: map[string]string{"a": "node-2", "b": "node-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client, _ := newTestPlacer(t, tt.strategy, tt.claims...)
+			if err := p.sync("default/a"); err != nil {
+				t.Fatalf("sync() error = %v", err)
+			}
+			for name, want := range tt.want {
+				claim, err := client.CoreV1().PersistentVolumeClaims("default").Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed to get claim: %v", err)
+				}
+				if got := claim.Annotations[nodevolumes.AnnProvisionOnNode]; got != want {
+					t.Errorf("claim %s assigned to %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolwatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Mount is the entry of the mount table a path lives on.
+type Mount struct {
+	// ID is unique to the mount, a filesystem mounted again gets a new one.
+	ID string
+	// Device is the major:minor of the filesystem.
+	Device     string
+	MountPoint string
+	FSType     string
+	Source     string
+}
+
+// MountOf returns the mount the path lives on, read from a mountinfo table
+// such as /proc/self/mountinfo: the last mounted of the mounts with the
+// longest mount point holding the path.
+func MountOf(mountinfo io.Reader, path string) (Mount, error) {
+	path = filepath.Clean(path)
+	var found Mount
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		mount, err := parseMountInfo(scanner.Text())
+		if err != nil {
+			return Mount{}, err
+		}
+		if !holds(mount.MountPoint, path) || len(mount.MountPoint) < len(found.MountPoint) {
+			continue
+		}
+		found = mount
+	}
+	if err := scanner.Err(); err != nil {
+		return Mount{}, err
+	}
+	if found.MountPoint == "" {
+		return Mount{}, fmt.Errorf("no mount holds %s", path)
+	}
+	return found, nil
+}
+
+// parseMountInfo parses a line of mountinfo, see proc(5):
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(line string) (Mount, error) {
+	fields := strings.Fields(line)
+	separator := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			separator = i
+			break
+		}
+	}
+	if len(fields) < 5 || separator < 0 || separator+2 >= len(fields) {
+		return Mount{}, fmt.Errorf("invalid mountinfo line %q", line)
+	}
+	return Mount{
+		ID:         fields[0],
+		Device:     fields[2],
+		MountPoint: unescape(fields[4]),
+		FSType:     fields[separator+1],
+		Source:     unescape(fields[separator+2]),
+	}, nil
+}
+
+// holds returns whether path is mountPoint or lives below it.
+func holds(mountPoint, path string) bool {
+	return mountPoint == "/" || path == mountPoint || strings.HasPrefix(path, mountPoint+"/")
+}
+
+// unescape decodes the octal escapes of spaces, tabs, newlines and
+// backslashes in mountinfo.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
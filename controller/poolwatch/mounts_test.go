@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolwatch
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMountInfo = `21 1 253:0 / / rw,relatime shared:1 - xfs /dev/mapper/root rw
+22 21 0:20 / /proc rw,nosuid shared:2 - proc proc rw
+30 21 253:2 / /var/hpvolumes rw,relatime shared:10 - xfs /dev/mapper/vg-pool rw
+31 21 253:3 / /var/hpvolumes-old rw,relatime shared:11 - ext4 /dev/sdb1 rw
+32 21 253:4 / /mnt/my\040disk rw,relatime shared:12 - ext4 /dev/sdc1 rw
+`
+
+func Test_MountOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		mountInfo string
+		path      string
+		want      Mount
+		wantErr   bool
+	}{
+		{
+			name:      "pool mount",
+			mountInfo: testMountInfo,
+			path:      "/var/hpvolumes/",
+			want:      Mount{ID: "30", Device: "253:2", MountPoint: "/var/hpvolumes", FSType: "xfs", Source: "/dev/mapper/vg-pool"},
+		},
+		{
+			name:      "directory below the pool mount",
+			mountInfo: testMountInfo,
+			path:      "/var/hpvolumes/pvc-1",
+			want:      Mount{ID: "30", Device: "253:2", MountPoint: "/var/hpvolumes", FSType: "xfs", Source: "/dev/mapper/vg-pool"},
+		},
+		{
+			name:      "directory of the root filesystem",
+			mountInfo: testMountInfo,
+			path:      "/var/hpvolumes-new",
+			want:      Mount{ID: "21", Device: "253:0", MountPoint: "/", FSType: "xfs", Source: "/dev/mapper/root"},
+		},
+		{
+			name:      "escaped mount point",
+			mountInfo: testMountInfo,
+			path:      "/mnt/my disk",
+			want:      Mount{ID: "32", Device: "253:4", MountPoint: "/mnt/my disk", FSType: "ext4", Source: "/dev/sdc1"},
+		},
+		{
+			name:      "filesystem mounted over the pool",
+			mountInfo: testMountInfo + "40 30 253:5 / /var/hpvolumes rw shared:20 - xfs /dev/sdd1 rw\n",
+			path:      "/var/hpvolumes",
+			want:      Mount{ID: "40", Device: "253:5", MountPoint: "/var/hpvolumes", FSType: "xfs", Source: "/dev/sdd1"},
+		},
+		{
+			name:      "no mount",
+			mountInfo: "30 21 253:2 / /var/hpvolumes rw shared:10 - xfs /dev/mapper/vg-pool rw\n",
+			path:      "/mnt",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid line",
+			mountInfo: "30 21 253:2 / /var/hpvolumes rw shared:10\n",
+			path:      "/var/hpvolumes",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MountOf(strings.NewReader(tt.mountInfo), tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MountOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MountOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
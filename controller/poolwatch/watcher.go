@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolwatch
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const (
+	// DefaultPeriod is how often the filesystem of the pool is checked when no
+	// mount change was noticed.
+	DefaultPeriod = 30 * time.Second
+	// MountInfo is the mount table of the process.
+	MountInfo = "/proc/self/mountinfo"
+)
+
+// Watcher calls onChange when the size of the filesystem of a pool or the
+// mount it lives on changes, e.g. after the filesystem was grown or another
+// one was mounted in its place.
+type Watcher struct {
+	path      string
+	period    time.Duration
+	mountInfo string
+	statfs    func(path string) (capacity.Stats, error)
+	onChange  func()
+	trigger   chan struct{}
+
+	// last is what the previous check saw, nil before the first check
+	last *state
+}
+
+type state struct {
+	mount Mount
+	total int64
+	// err is set when the pool could not be read, its recovery is a change
+	err bool
+}
+
+// New returns a Watcher of the pool at path calling onChange after it
+// changed. onChange is not called for the first check.
+func New(path string, period time.Duration, onChange func()) *Watcher {
+	return &Watcher{
+		path:      path,
+		period:    period,
+		mountInfo: MountInfo,
+		statfs:    capacity.Statfs,
+		onChange:  onChange,
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// Trigger asks for the pool to be checked as soon as possible.
+func (w *Watcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run checks the pool every period and after the mount table changed until
+// stopCh is closed.
+func (w *Watcher) Run(stopCh <-chan struct{}) {
+	go w.watchMounts()
+	ticker := time.NewTicker(w.period)
+	defer ticker.Stop()
+	for {
+		w.Check()
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-w.trigger:
+		}
+	}
+}
+
+// Check reads the filesystem and the mount of the pool and calls onChange
+// when either changed since the previous check. It returns whether they did.
+func (w *Watcher) Check() bool {
+	current := w.read()
+	last := w.last
+	w.last = &current
+	if last == nil || *last == current {
+		return false
+	}
+	glog.Infof("Pool at %s changed: mount %s of %s on %s, %d bytes", w.path, current.mount.ID, current.mount.Source, current.mount.MountPoint, current.total)
+	w.onChange()
+	return true
+}
+
+func (w *Watcher) read() state {
+	stats, err := w.statfs(w.path)
+	if err != nil {
+		glog.Errorf("Failed to read the filesystem of pool %s: %v", w.path, err)
+		return state{err: true}
+	}
+	current := state{total: stats.Total}
+	file, err := os.Open(w.mountInfo)
+	if err != nil {
+		glog.Errorf("Failed to read the mount table: %v", err)
+		return current
+	}
+	defer file.Close()
+	if current.mount, err = MountOf(file, w.path); err != nil {
+		glog.Errorf("Failed to find the mount of pool %s: %v", w.path, err)
+	}
+	return current
+}
+
+// watchMounts triggers a check every time the mount table changes until the
+// process exits. The
+// kernel flags the mount table with POLLPRI when a filesystem is mounted or
+// unmounted; without it changes are only noticed by the periodic check.
+func (w *Watcher) watchMounts() {
+	file, err := os.Open(w.mountInfo)
+	if err != nil {
+		glog.Errorf("Failed to watch the mount table, mount changes are noticed every %v: %v", w.period, err)
+		return
+	}
+	defer file.Close()
+	fds := []unix.PollFd{{Fd: int32(file.Fd()), Events: unix.POLLPRI}}
+	for {
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			glog.Errorf("Failed to watch the mount table, mount changes are noticed every %v: %v", w.period, err)
+			return
+		}
+		if fds[0].Revents&unix.POLLNVAL != 0 {
+			return
+		}
+		if fds[0].Revents&(unix.POLLPRI|unix.POLLERR) != 0 {
+			w.Trigger()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolwatch
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+func Test_WatcherCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poolwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mountInfo := filepath.Join(dir, "mountinfo")
+	writeMountInfo := func(content string) {
+		if err := ioutil.WriteFile(mountInfo, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var total, free int64 = 100, 50
+	var statErr error
+	changes := 0
+	w := New("/var/hpvolumes", DefaultPeriod, func() { changes++ })
+	w.mountInfo = mountInfo
+	w.statfs = func(string) (capacity.Stats, error) {
+		return capacity.Stats{Total: total, Free: free, Available: free}, statErr
+	}
+	writeMountInfo(testMountInfo)
+
+	steps := []struct {
+		name   string
+		change func()
+		want   bool
+	}{
+		{name: "first check", change: func() {}, want: false},
+		{name: "nothing changed", change: func() {}, want: false},
+		{name: "only the usage changed", change: func() { free = 10 }, want: false},
+		{name: "filesystem grown", change: func() { total = 200 }, want: true},
+		{name: "filesystem replaced", change: func() {
+			writeMountInfo(testMountInfo + "40 30 253:5 / /var/hpvolumes rw shared:20 - xfs /dev/sdd1 rw\n")
+		}, want: true},
+		{name: "pool unreadable", change: func() { statErr = errors.New("no such file or directory") }, want: true},
+		{name: "pool still unreadable", change: func() {}, want: false},
+		{name: "pool back", change: func() { statErr = nil }, want: true},
+	}
+	wantChanges := 0
+	for _, step := range steps {
+		step.change()
+		if got := w.Check(); got != step.want {
+			t.Errorf("%s: Check() = %v, want %v", step.name, got, step.want)
+		}
+		if step.want {
+			wantChanges++
+		}
+		if changes != wantChanges {
+			t.Errorf("%s: onChange called %d times, want %d", step.name, changes, wantChanges)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preemption
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const (
+	// AnnEvictable marks a claim whose volume may be deleted to make room for
+	// claims of higher priority, when set to "true".
+	AnnEvictable = "hostpath.kubevirt.io/evictable"
+	// AnnPriority is the priority of a claim, an integer. Claims without it
+	// have priority 0.
+	AnnPriority = "hostpath.kubevirt.io/priority"
+)
+
+// Priority returns the priority of the claim.
+func Priority(claim *v1.PersistentVolumeClaim) (int64, error) {
+	value, ok := claim.Annotations[AnnPriority]
+	if !ok {
+		return 0, nil
+	}
+	priority, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q, must be an integer", AnnPriority, value)
+	}
+	return priority, nil
+}
+
+// IsEvictable returns whether the claim may be preempted.
+func IsEvictable(claim *v1.PersistentVolumeClaim) bool {
+	return claim.Annotations[AnnEvictable] == "true"
+}
+
+// Victim is a bound claim that may be preempted.
+type Victim struct {
+	Claim    *v1.PersistentVolumeClaim
+	Priority int64
+	// Size is the capacity of the volume of the claim.
+	Size int64
+}
+
+// SelectVictims returns the victims to delete so that at least needed bytes
+// are freed, taking the lowest priorities first and, within a priority, the
+// largest volumes so as few claims as possible are deleted. It returns nil
+// when the victims together can not free needed.
+func SelectVictims(victims []Victim, needed int64) []Victim {
+	sorted := make([]Victim, len(victims))
+	copy(sorted, victims)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority < sorted[j].Priority
+		}
+		return sorted[i].Size > sorted[j].Size
+	})
+	var selected []Victim
+	var freed int64
+	for _, victim := range sorted {
+		if freed >= needed {
+			break
+		}
+		selected = append(selected, victim)
+		freed += victim.Size
+	}
+	if freed < needed {
+		return nil
+	}
+	return selected
+}
+
+// Preemptor deletes evictable claims on a node to make room for claims of
+// higher priority.
+type Preemptor struct {
+	client   kubernetes.Interface
+	nodeName string
+	claims   corelisters.PersistentVolumeClaimLister
+	volumes  *nodevolumes.Index
+	recorder record.EventRecorder
+}
+
+// New returns a Preemptor for the node.
+func New(client kubernetes.Interface, nodeName string, claims corelisters.PersistentVolumeClaimLister, volumes *nodevolumes.Index, recorder record.EventRecorder) *Preemptor {
+	return &Preemptor{
+		client:   client,
+		nodeName: nodeName,
+		claims:   claims,
+		volumes:  volumes,
+		recorder: recorder,
+	}
+}
+
+// candidates returns the evictable claims bound to volumes on the node whose
+// priority is below priority, and the space of the volumes on the node that
+// is being freed: volumes of claims that are being deleted or are gone and
+// that are deleted with them. Claims whose volume is retained are no
+// candidates, deleting them frees nothing.
+func (p *Preemptor) candidates(priority int64) ([]Victim, int64) {
+	var victims []Victim
+	var freeing int64
+	for _, pv := range p.volumes.ByNode(p.nodeName) {
+		size := pv.Spec.Capacity.Storage().Value()
+		deletedWithClaim := pv.Spec.PersistentVolumeReclaimPolicy == v1.PersistentVolumeReclaimDelete
+		if pv.DeletionTimestamp != nil || pv.Status.Phase == v1.VolumeReleased {
+			if deletedWithClaim {
+				freeing += size
+			}
+			continue
+		}
+		if pv.Spec.ClaimRef == nil {
+			continue
+		}
+		claim, err := p.claims.PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+		if apierrs.IsNotFound(err) || err == nil && claim.UID != pv.Spec.ClaimRef.UID {
+			if deletedWithClaim {
+				freeing += size
+			}
+			continue
+		}
+		if err != nil || !IsEvictable(claim) || !deletedWithClaim {
+			continue
+		}
+		if claim.DeletionTimestamp != nil {
+			if deletedWithClaim {
+				freeing += size
+			}
+			continue
+		}
+		claimPriority, err := Priority(claim)
+		if err != nil || claimPriority >= priority {
+			continue
+		}
+		victims = append(victims, Victim{Claim: claim, Priority: claimPriority, Size: size})
+	}
+	return victims, freeing
+}
+
+// CanPreempt returns whether deleting evictable claims of lower priority than
+// the claim, on top of the space being freed already, frees needed bytes on
+// the node.
+func (p *Preemptor) CanPreempt(claim *v1.PersistentVolumeClaim, needed int64) bool {
+	priority, err := Priority(claim)
+	if err != nil {
+		return false
+	}
+	victims, freeing := p.candidates(priority)
+	return freeing >= needed || SelectVictims(victims, needed-freeing) != nil
+}
+
+// Preempt deletes the evictable claims of lower priority needed to free
+// needed bytes for the claim, on top of the space being freed already. It
+// returns false when that is not enough.
+// Once it returned true the claim is to be retried until the deleted volumes
+// are gone.
+func (p *Preemptor) Preempt(claim *v1.PersistentVolumeClaim, needed int64) (bool, error) {
+	priority, err := Priority(claim)
+	if err != nil {
+		return false, err
+	}
+	victims, freeing := p.candidates(priority)
+	if freeing >= needed {
+		glog.V(2).Infof("claim %s/%s waits for volumes to be deleted", claim.Namespace, claim.Name)
+		return true, nil
+	}
+	selected := SelectVictims(victims, needed-freeing)
+	if selected == nil {
+		return false, nil
+	}
+
+	names := make([]string, 0, len(selected))
+	for _, victim := range selected {
+		names = append(names, victim.Claim.Namespace+"/"+victim.Claim.Name)
+	}
+	p.recorder.Eventf(claim, v1.EventTypeNormal, "Preempting", "Deleting evictable claims %s of lower priority to free %s on node %s",
+		strings.Join(names, ", "), resource.NewQuantity(needed, resource.BinarySI).String(), p.nodeName)
+	for _, victim := range selected {
+		uid := victim.Claim.UID
+		err := p.client.CoreV1().PersistentVolumeClaims(victim.Claim.Namespace).Delete(context.TODO(), victim.Claim.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &uid},
+		})
+		if err != nil && !apierrs.IsNotFound(err) {
+			return false, err
+		}
+		glog.Infof("preempted claim %s/%s with priority %d for claim %s/%s with priority %d on node %s",
+			victim.Claim.Namespace, victim.Claim.Name, victim.Priority, claim.Namespace, claim.Name, priority, p.nodeName)
+		p.recorder.Eventf(victim.Claim, v1.EventTypeWarning, "Preempted", "Deleted to make room on node %s for claim %s/%s with priority %d, this claim is evictable with priority %d",
+			p.nodeName, claim.Namespace, claim.Name, priority, victim.Priority)
+	}
+	return true, nil
+}
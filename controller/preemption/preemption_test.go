@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package preemption
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const GiB = 1024 * 1024 * 1024
+
+func Test_SelectVictims(t *testing.T) {
+	victim := func(name string, priority, size int64) Victim {
+		return Victim{Claim: &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name}}, Priority: priority, Size: size}
+	}
+	victims := []Victim{
+		victim("a", 5, 10*GiB),
+		victim("b", 1, 5*GiB),
+		victim("c", 1, 20*GiB),
+		victim("d", 3, 30*GiB),
+	}
+	tests := []struct {
+		name   string
+		needed int64
+		want   []string
+	}{
+		{"largest of the lowest priority", 15 * GiB, []string{"c"}},
+		{"whole lowest priority", 25 * GiB, []string{"c", "b"}},
+		{"next priority", 40 * GiB, []string{"c", "b", "d"}},
+		{"all", 65 * GiB, []string{"c", "b", "d", "a"}},
+		{"not enough", 66 * GiB, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range SelectVictims(victims, tt.needed) {
+				got = append(got, v.Claim.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectVictims() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newClaim(name string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ci", UID: types.UID(name), Annotations: annotations},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-" + name},
+	}
+}
+
+func newVolume(claim *v1.PersistentVolumeClaim, node, size string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-" + claim.Name, Annotations: map[string]string{nodevolumes.AnnProvisionOnNode: node}},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			Capacity:                      v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			ClaimRef:                      &v1.ObjectReference{Namespace: claim.Namespace, Name: claim.Name, UID: claim.UID},
+		},
+	}
+}
+
+func Test_Preempt(t *testing.T) {
+	evictable := func(priority string) map[string]string {
+		return map[string]string{AnnEvictable: "true", AnnPriority: priority}
+	}
+	scratch := newClaim("scratch", evictable("0"))
+	cache := newClaim("cache", evictable("5"))
+	pinned := newClaim("pinned", map[string]string{AnnPriority: "0"})
+	elsewhere := newClaim("elsewhere", evictable("0"))
+	deleting := newClaim("deleting", evictable("0"))
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	retained := newClaim("retained", evictable("0"))
+
+	claims := []*v1.PersistentVolumeClaim{scratch, cache, pinned, elsewhere, deleting, retained}
+	objects := []runtime.Object{}
+	for _, claim := range claims {
+		objects = append(objects, claim)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	claimIndexer := factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer()
+	for _, claim := range claims {
+		claimIndexer.Add(claim)
+	}
+	volumeInformer := factory.Core().V1().PersistentVolumes().Informer()
+	volumes, err := nodevolumes.New(volumeInformer)
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	volumeInformer.GetIndexer().Add(newVolume(scratch, "node-1", "10Gi"))
+	volumeInformer.GetIndexer().Add(newVolume(cache, "node-1", "20Gi"))
+	volumeInformer.GetIndexer().Add(newVolume(pinned, "node-1", "50Gi"))
+	volumeInformer.GetIndexer().Add(newVolume(elsewhere, "node-2", "50Gi"))
+	volumeInformer.GetIndexer().Add(newVolume(deleting, "node-1", "3Gi"))
+	// deleting the claim of a retained volume frees nothing
+	retainedVolume := newVolume(retained, "node-1", "100Gi")
+	retainedVolume.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimRetain
+	volumeInformer.GetIndexer().Add(retainedVolume)
+	released := newVolume(newClaim("released", nil), "node-1", "2Gi")
+	released.Status.Phase = v1.VolumeReleased
+	volumeInformer.GetIndexer().Add(released)
+
+	recorder := record.NewFakeRecorder(10)
+	p := New(client, "node-1", factory.Core().V1().PersistentVolumeClaims().Lister(), volumes, recorder)
+	blocked := newClaim("blocked", map[string]string{AnnPriority: "10"})
+
+	canPreempt := []struct {
+		name   string
+		claim  *v1.PersistentVolumeClaim
+		needed int64
+		want   bool
+	}{
+		{"space being freed", blocked, 5 * GiB, true},
+		{"space being freed and victims", blocked, 35 * GiB, true},
+		{"victims do not cover the shortfall", blocked, 36 * GiB, false},
+		{"no victims of lower priority", newClaim("low", nil), 6 * GiB, false},
+	}
+	for _, tt := range canPreempt {
+		if got := p.CanPreempt(tt.claim, tt.needed); got != tt.want {
+			t.Errorf("CanPreempt() %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 5Gi are being freed already, by a claim being deleted and a released volume
+	if started, err := p.Preempt(blocked, 5*GiB); err != nil || !started {
+		t.Errorf("Preempt(5Gi) = %v, %v, want true", started, err)
+	}
+	// 40Gi would need the pinned claim
+	if started, err := p.Preempt(blocked, 40*GiB); err != nil || started {
+		t.Errorf("Preempt(40Gi) = %v, %v, want false", started, err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("no claim should have been preempted yet, got %d events", len(recorder.Events))
+	}
+
+	// 5Gi being freed plus scratch
+	if started, err := p.Preempt(blocked, 12*GiB); err != nil || !started {
+		t.Fatalf("Preempt(12Gi) = %v, %v, want true", started, err)
+	}
+	remaining, err := client.CoreV1().PersistentVolumeClaims("ci").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var names []string
+	for _, claim := range remaining.Items {
+		names = append(names, claim.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"cache", "deleting", "elsewhere", "pinned", "retained"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("remaining claims = %v, want %v", names, want)
+	}
+	if len(recorder.Events) != 2 {
+		t.Errorf("expected a Preempting and a Preempted event, got %d events", len(recorder.Events))
+	}
+}
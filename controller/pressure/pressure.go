@@ -0,0 +1,305 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pressure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const (
+	// ConditionType is the Node condition set while the pool is under pressure.
+	ConditionType v1.NodeConditionType = "HostPathStoragePressure"
+	// TaintKey is the NoSchedule taint added while the pool is under pressure,
+	// when tainting is enabled.
+	TaintKey = "hostpath.kubevirt.io/storage-pressure"
+
+	reasonPressure   = "HostPathStoragePressure"
+	reasonNoPressure = "HostPathStorageAvailable"
+)
+
+// Threshold is an amount of space, either absolute or relative to the size of
+// the filesystem.
+type Threshold struct {
+	Bytes   int64
+	Percent float64
+}
+
+// ParseThreshold parses a quantity ("10Gi") or a percentage ("5%").
+func ParseThreshold(s string) (Threshold, error) {
+	if strings.HasSuffix(s, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return Threshold{}, fmt.Errorf("invalid percentage %q", s)
+		}
+		return Threshold{Percent: percent}, nil
+	}
+	quantity, err := resource.ParseQuantity(s)
+	if err != nil || quantity.Sign() < 0 {
+		return Threshold{}, fmt.Errorf("invalid size %q", s)
+	}
+	return Threshold{Bytes: quantity.Value()}, nil
+}
+
+// Value returns the threshold in bytes for a filesystem of the given size.
+func (t Threshold) Value(total int64) int64 {
+	if t.Percent > 0 {
+		return int64(float64(total) * t.Percent / 100)
+	}
+	return t.Bytes
+}
+
+// Config says when a pool is under pressure. A pool enters pressure when its
+// free space or its allocatable capacity drops below the threshold, and leaves
+// it once both are back above the threshold plus the recovery margin, so a
+// pool hovering around a threshold does not flap.
+type Config struct {
+	// Free is the threshold of the space actually free on the filesystem, nil
+	// disables the check.
+	Free *Threshold
+	// Allocatable is the threshold of the capacity not yet handed out to
+	// volumes, nil disables the check.
+	Allocatable *Threshold
+	// RecoveryMargin is added to the thresholds to leave pressure.
+	RecoveryMargin Threshold
+	// Taint enables the NoSchedule taint.
+	Taint bool
+}
+
+// DefaultRecoveryMargin is used when no recovery margin is configured.
+var DefaultRecoveryMargin = Threshold{Percent: 5}
+
+// ParseConfig builds a Config from its string form, empty values keep the
+// defaults. Pressure is only reported when at least one threshold is set.
+func ParseConfig(free, allocatable, recoveryMargin, taint string) (Config, error) {
+	config := Config{RecoveryMargin: DefaultRecoveryMargin}
+	if free != "" {
+		t, err := ParseThreshold(free)
+		if err != nil {
+			return config, fmt.Errorf("invalid free space threshold: %v", err)
+		}
+		config.Free = &t
+	}
+	if allocatable != "" {
+		t, err := ParseThreshold(allocatable)
+		if err != nil {
+			return config, fmt.Errorf("invalid allocatable threshold: %v", err)
+		}
+		config.Allocatable = &t
+	}
+	if recoveryMargin != "" {
+		t, err := ParseThreshold(recoveryMargin)
+		if err != nil {
+			return config, fmt.Errorf("invalid recovery margin: %v", err)
+		}
+		config.RecoveryMargin = t
+	}
+	if taint != "" {
+		enabled, err := strconv.ParseBool(taint)
+		if err != nil {
+			return config, fmt.Errorf("invalid taint setting %q", taint)
+		}
+		config.Taint = enabled
+	}
+	return config, nil
+}
+
+// Enabled returns whether any threshold is set.
+func (c Config) Enabled() bool {
+	return c.Free != nil || c.Allocatable != nil
+}
+
+// Evaluate returns whether the pool is under pressure, given whether it was
+// before, and a message describing the pool.
+func (c Config) Evaluate(poolCapacity capacity.Capacity, underPressure bool) (bool, string) {
+	margin := int64(0)
+	if underPressure {
+		margin = c.RecoveryMargin.Value(poolCapacity.Total)
+	}
+	var low []string
+	if c.Free != nil {
+		if threshold := c.Free.Value(poolCapacity.Total); poolCapacity.Available < threshold+margin {
+			low = append(low, fmt.Sprintf("free space %s below %s", quantity(poolCapacity.Available), quantity(threshold+margin)))
+		}
+	}
+	if c.Allocatable != nil {
+		if threshold := c.Allocatable.Value(poolCapacity.Total); poolCapacity.Allocatable < threshold+margin {
+			low = append(low, fmt.Sprintf("allocatable capacity %s below %s", quantity(poolCapacity.Allocatable), quantity(threshold+margin)))
+		}
+	}
+	if len(low) > 0 {
+		return true, "hostpath pool is low: " + strings.Join(low, ", ")
+	}
+	return false, fmt.Sprintf("hostpath pool has %s free and %s allocatable", quantity(poolCapacity.Available), quantity(poolCapacity.Allocatable))
+}
+
+func quantity(bytes int64) string {
+	return resource.NewQuantity(bytes, resource.BinarySI).String()
+}
+
+// Reporter keeps the condition and the taint of a Node in line with the
+// pressure of its pool.
+type Reporter struct {
+	client   kubernetes.Interface
+	nodeName string
+	config   Config
+	now      func() time.Time
+}
+
+// NewReporter returns a Reporter for the node.
+func NewReporter(client kubernetes.Interface, nodeName string, config Config) *Reporter {
+	return &Reporter{
+		client:   client,
+		nodeName: nodeName,
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+// UnderPressure returns whether the node currently reports pressure.
+func UnderPressure(node *v1.Node) bool {
+	if node == nil {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == ConditionType {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Report evaluates the pool against the thresholds and updates the Node when
+// its condition or taint needs to change. node is the last known state of the
+// Node, used to apply hysteresis and to skip needless writes. It returns
+// whether the pool is under pressure.
+func (r *Reporter) Report(node *v1.Node, poolCapacity capacity.Capacity) (bool, error) {
+	underPressure, message := r.config.Evaluate(poolCapacity, UnderPressure(node))
+	if node != nil && !r.conditionChanged(node, underPressure) && !r.taintChanged(node, underPressure) {
+		return underPressure, nil
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := r.client.CoreV1().Nodes().Get(context.TODO(), r.nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if r.conditionChanged(current, underPressure) {
+			current = current.DeepCopy()
+			r.setCondition(current, underPressure, message)
+			if current, err = r.client.CoreV1().Nodes().UpdateStatus(context.TODO(), current, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		if r.taintChanged(current, underPressure) {
+			current = current.DeepCopy()
+			setTaint(current, underPressure)
+			if _, err = r.client.CoreV1().Nodes().Update(context.TODO(), current, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return underPressure, err
+}
+
+// conditionChanged returns whether the condition is missing or has another
+// status. The message alone does not cause a write, it changes with every
+// volume.
+func (r *Reporter) conditionChanged(node *v1.Node, underPressure bool) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == ConditionType {
+			return condition.Status != conditionStatus(underPressure)
+		}
+	}
+	return true
+}
+
+// taintChanged returns whether the taint must be added or removed. The taint
+// is removed when tainting was disabled after it had been added.
+func (r *Reporter) taintChanged(node *v1.Node, underPressure bool) bool {
+	return hasTaint(node) != (r.config.Taint && underPressure)
+}
+
+func (r *Reporter) setCondition(node *v1.Node, underPressure bool, message string) {
+	now := metav1.NewTime(r.now())
+	reason := reasonNoPressure
+	if underPressure {
+		reason = reasonPressure
+	}
+	for i := range node.Status.Conditions {
+		condition := &node.Status.Conditions[i]
+		if condition.Type != ConditionType {
+			continue
+		}
+		if condition.Status != conditionStatus(underPressure) {
+			condition.LastTransitionTime = now
+		}
+		condition.Status = conditionStatus(underPressure)
+		condition.LastHeartbeatTime = now
+		condition.Reason = reason
+		condition.Message = message
+		return
+	}
+	node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{
+		Type:               ConditionType,
+		Status:             conditionStatus(underPressure),
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func conditionStatus(underPressure bool) v1.ConditionStatus {
+	if underPressure {
+		return v1.ConditionTrue
+	}
+	return v1.ConditionFalse
+}
+
+func hasTaint(node *v1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == TaintKey {
+			return true
+		}
+	}
+	return false
+}
+
+func setTaint(node *v1.Node, tainted bool) {
+	taints := make([]v1.Taint, 0, len(node.Spec.Taints)+1)
+	for _, taint := range node.Spec.Taints {
+		if taint.Key != TaintKey {
+			taints = append(taints, taint)
+		}
+	}
+	if tainted {
+		taints = append(taints, v1.Taint{Key: TaintKey, Effect: v1.TaintEffectNoSchedule})
+	}
+	node.Spec.Taints = taints
+}
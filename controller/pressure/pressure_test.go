@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pressure
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const GiB = 1024 * 1024 * 1024
+
+func Test_ParseConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		free           string
+		allocatable    string
+		recoveryMargin string
+		taint          string
+		want           Config
+		wantEnabled    bool
+		wantErr        bool
+	}{
+		{
+			name: "defaults are disabled",
+			want: Config{RecoveryMargin: DefaultRecoveryMargin},
+		},
+		{
+			name:           "all set",
+			free:           "10%",
+			allocatable:    "20Gi",
+			recoveryMargin: "1Gi",
+			taint:          "true",
+			want: Config{
+				Free:           &Threshold{Percent: 10},
+				Allocatable:    &Threshold{Bytes: 20 * GiB},
+				RecoveryMargin: Threshold{Bytes: GiB},
+				Taint:          true,
+			},
+			wantEnabled: true,
+		},
+		{
+			name:    "invalid percentage",
+			free:    "110%",
+			wantErr: true,
+		},
+		{
+			name:        "negative size",
+			allocatable: "-1Gi",
+			wantErr:     true,
+		},
+		{
+			name:    "invalid taint",
+			free:    "1Gi",
+			taint:   "sometimes",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(tt.free, tt.allocatable, tt.recoveryMargin, tt.taint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Enabled() != tt.wantEnabled {
+				t.Errorf("Enabled() = %v, want %v", got.Enabled(), tt.wantEnabled)
+			}
+			if (got.Free == nil) != (tt.want.Free == nil) || got.Free != nil && *got.Free != *tt.want.Free {
+				t.Errorf("Free = %v, want %v", got.Free, tt.want.Free)
+			}
+			if (got.Allocatable == nil) != (tt.want.Allocatable == nil) || got.Allocatable != nil && *got.Allocatable != *tt.want.Allocatable {
+				t.Errorf("Allocatable = %v, want %v", got.Allocatable, tt.want.Allocatable)
+			}
+			if got.RecoveryMargin != tt.want.RecoveryMargin || got.Taint != tt.want.Taint {
+				t.Errorf("ParseConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Evaluate(t *testing.T) {
+	config := Config{
+		Free:           &Threshold{Percent: 10},
+		Allocatable:    &Threshold{Bytes: 5 * GiB},
+		RecoveryMargin: Threshold{Percent: 5},
+	}
+	tests := []struct {
+		name          string
+		available     int64
+		allocatable   int64
+		underPressure bool
+		want          bool
+	}{
+		{"plenty of space", 50 * GiB, 50 * GiB, false, false},
+		{"free space low", 9 * GiB, 50 * GiB, false, true},
+		{"allocatable low", 50 * GiB, 4 * GiB, false, true},
+		{"recovered within margin stays", 12 * GiB, 50 * GiB, true, true},
+		{"recovered beyond margin clears", 16 * GiB, 50 * GiB, true, false},
+		{"allocatable within margin stays", 50 * GiB, 6 * GiB, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poolCapacity := capacity.Capacity{Total: 100 * GiB, Available: tt.available, Allocatable: tt.allocatable}
+			if got, _ := config.Evaluate(poolCapacity, tt.underPressure); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Report(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: "other", Effect: v1.TaintEffectNoExecute}},
+		},
+	})
+	reporter := NewReporter(client, "node-1", Config{
+		Free:           &Threshold{Bytes: 10 * GiB},
+		RecoveryMargin: Threshold{Bytes: 5 * GiB},
+		Taint:          true,
+	})
+	getNode := func() *v1.Node {
+		node, err := client.CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return node
+	}
+	steps := []struct {
+		available int64
+		want      bool
+	}{
+		{50 * GiB, false},
+		{5 * GiB, true},
+		{12 * GiB, true},
+		{20 * GiB, false},
+	}
+	for _, step := range steps {
+		poolCapacity := capacity.Capacity{Total: 100 * GiB, Available: step.available}
+		got, err := reporter.Report(getNode(), poolCapacity)
+		if err != nil {
+			t.Fatalf("Report() error = %v", err)
+		}
+		node := getNode()
+		if got != step.want || UnderPressure(node) != step.want {
+			t.Errorf("available %d: Report() = %v, condition %v, want %v", step.available, got, UnderPressure(node), step.want)
+		}
+		if hasTaint(node) != step.want {
+			t.Errorf("available %d: tainted = %v, want %v", step.available, hasTaint(node), step.want)
+		}
+		if len(node.Spec.Taints) == 0 || node.Spec.Taints[0].Key != "other" {
+			t.Errorf("available %d: other taints must be kept, got %v", step.available, node.Spec.Taints)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagecapacity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	storage "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const (
+	// DefaultVersion is the storage.k8s.io version the objects are written with.
+	// Older clusters serve CSIStorageCapacity as v1beta1 (1.21-1.23) or
+	// v1alpha1 (1.19-1.20).
+	DefaultVersion = "v1"
+	// DefaultSyncPeriod is how often the objects are refreshed when nothing
+	// triggers an earlier update.
+	DefaultSyncPeriod = time.Minute
+
+	// TopologyKey is the node label the published topology segment matches,
+	// the same key the provisioned PVs use for their node affinity.
+	TopologyKey = "kubernetes.io/hostname"
+
+	// LabelNode is set on the objects of a node, so every node only watches its
+	// own objects.
+	LabelNode = "hostpath.kubevirt.io/capacity-node"
+
+	labelManagedBy = "app.kubernetes.io/managed-by"
+	managedBy      = "hostpath-provisioner"
+	kind           = "CSIStorageCapacity"
+)
+
+// CapacityFunc returns the capacity of the node's pool.
+type CapacityFunc func() (capacity.Capacity, error)
+
+// Publisher keeps one CSIStorageCapacity object per storage class of the
+// provisioner up to date with the allocatable capacity of a node.
+type Publisher struct {
+	client          dynamic.Interface
+	resource        schema.GroupVersionResource
+	namespace       string
+	nodeName        string
+	provisionerName string
+	owner           *metav1.OwnerReference
+	classes         cache.Store
+	objects         cache.SharedIndexInformer
+	capacity        CapacityFunc
+	syncPeriod      time.Duration
+	trigger         chan struct{}
+}
+
+// NewPublisher returns a Publisher writing CSIStorageCapacity objects of the
+// given storage.k8s.io version to namespace. classes is a StorageClass store,
+// owner is optional and set on the created objects.
+func NewPublisher(client dynamic.Interface, version, namespace, nodeName, provisionerName string, owner *metav1.OwnerReference, classes cache.Store, capacityFunc CapacityFunc) *Publisher {
+	if version == "" {
+		version = DefaultVersion
+	}
+	gvr := schema.GroupVersionResource{
+		Group:    storage.GroupName,
+		Version:  version,
+		Resource: "csistoragecapacities",
+	}
+	selector := labelManagedBy + "=" + managedBy + "," + LabelNode + "=" + nodeLabel(nodeName)
+	objects := client.Resource(gvr).Namespace(namespace)
+	return &Publisher{
+		client:          client,
+		resource:        gvr,
+		namespace:       namespace,
+		nodeName:        nodeName,
+		provisionerName: provisionerName,
+		owner:           owner,
+		classes:         classes,
+		objects: cache.NewSharedIndexInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				return objects.List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return objects.Watch(context.TODO(), options)
+			},
+		}, &unstructured.Unstructured{}, 0, cache.Indexers{}),
+		capacity:   capacityFunc,
+		syncPeriod: DefaultSyncPeriod,
+		trigger:    make(chan struct{}, 1),
+	}
+}
+
+// Trigger asks for the objects to be refreshed as soon as possible, e.g.
+// after a volume was provisioned or deleted.
+func (p *Publisher) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run refreshes the objects until stopCh is closed.
+func (p *Publisher) Run(stopCh <-chan struct{}) {
+	go p.objects.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, p.objects.HasSynced) {
+		return
+	}
+	ticker := time.NewTicker(p.syncPeriod)
+	defer ticker.Stop()
+	for {
+		if err := p.Sync(); err != nil {
+			glog.Errorf("failed to publish storage capacity of node %s: %v", p.nodeName, err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-p.trigger:
+		}
+	}
+}
+
+// Sync creates or updates the object of every storage class of the
+// provisioner and removes the objects of classes that are gone. The existing
+// objects are read from the informer Run starts.
+func (p *Publisher) Sync() error {
+	poolCapacity, err := p.capacity()
+	if err != nil {
+		return err
+	}
+	allocatable := resource.NewQuantity(poolCapacity.Allocatable, resource.BinarySI).String()
+
+	client := p.client.Resource(p.resource).Namespace(p.namespace)
+	// the informer only watches the objects of this node
+	existing := map[string]*unstructured.Unstructured{}
+	label := nodeLabel(p.nodeName)
+	for _, item := range p.objects.GetStore().List() {
+		if obj, ok := item.(*unstructured.Unstructured); ok && obj.GetLabels()[LabelNode] == label {
+			existing[obj.GetName()] = obj.DeepCopy()
+		}
+	}
+
+	for _, class := range p.storageClasses() {
+		name := objectName(p.nodeName, class)
+		obj, found := existing[name]
+		delete(existing, name)
+		if !found {
+			if _, err := client.Create(context.TODO(), p.newObject(name, class, allocatable), metav1.CreateOptions{}); err != nil && !apierrs.IsAlreadyExists(err) {
+				return err
+			}
+			glog.V(2).Infof("published capacity %s of node %s for storage class %s", allocatable, p.nodeName, class)
+			continue
+		}
+		if current, _, _ := unstructured.NestedString(obj.Object, "capacity"); current == allocatable {
+			continue
+		}
+		obj.Object["capacity"] = allocatable
+		obj.Object["maximumVolumeSize"] = allocatable
+		if _, err := client.Update(context.TODO(), obj, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		glog.V(2).Infof("updated capacity of node %s for storage class %s to %s", p.nodeName, class, allocatable)
+	}
+
+	for name := range existing {
+		if err := client.Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// storageClasses returns the names of the storage classes of the provisioner.
+func (p *Publisher) storageClasses() []string {
+	var classes []string
+	for _, obj := range p.classes.List() {
+		if class, ok := obj.(*storage.StorageClass); ok && class.Provisioner == p.provisionerName {
+			classes = append(classes, class.Name)
+		}
+	}
+	return classes
+}
+
+func (p *Publisher) newObject(name, class, allocatable string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": p.resource.GroupVersion().String(),
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": p.namespace,
+			"labels": map[string]interface{}{
+				labelManagedBy: managedBy,
+				LabelNode:      nodeLabel(p.nodeName),
+			},
+		},
+		"nodeTopology": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				TopologyKey: p.nodeName,
+			},
+		},
+		"storageClassName": class,
+		// a single hostpath volume can take the whole pool
+		"capacity":          allocatable,
+		"maximumVolumeSize": allocatable,
+	}}
+	if p.owner != nil {
+		obj.SetOwnerReferences([]metav1.OwnerReference{*p.owner})
+	}
+	return obj
+}
+
+// objectName derives a stable, valid name from the node and storage class.
+func objectName(nodeName, class string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s", nodeName, class)))
+	return "hpp-" + hex.EncodeToString(sum[:])[:20]
+}
+
+// nodeLabel returns the value of LabelNode for a node. Node names longer than
+// a label value allows are hashed.
+func nodeLabel(nodeName string) string {
+	if len(validation.IsValidLabelValue(nodeName)) == 0 {
+		return nodeName
+	}
+	sum := sha256.Sum256([]byte(nodeName))
+	return "hpp-" + hex.EncodeToString(sum[:])[:20]
+}
+
+// ValidateDriverName returns an error when the provisioner name can not be the
+// name of the CSIDriver object the scheduler looks up for the storage classes.
+func ValidateDriverName(provisionerName string) error {
+	if errs := validation.IsDNS1123Subdomain(provisionerName); len(errs) != 0 {
+		return fmt.Errorf("%q is not a valid CSIDriver name: %s", provisionerName, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagecapacity
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	storage "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+)
+
+const testProvisioner = "kubevirt.io/hostpath-provisioner"
+
+func newClass(name, provisioner string) *storage.StorageClass {
+	return &storage.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: provisioner,
+	}
+}
+
+// listCapacities returns the capacity per storage class the informer of the
+// publisher holds.
+func listCapacities(p *Publisher) map[string]string {
+	capacities := map[string]string{}
+	for _, item := range p.objects.GetStore().List() {
+		obj := item.(*unstructured.Unstructured)
+		if obj.GetLabels()[LabelNode] != nodeLabel(p.nodeName) {
+			continue
+		}
+		class, _, _ := unstructured.NestedString(obj.Object, "storageClassName")
+		value, _, _ := unstructured.NestedString(obj.Object, "capacity")
+		capacities[class] = value
+	}
+	return capacities
+}
+
+// waitForObjects waits until the informer of the publisher saw count objects
+// of its node. The fake client does not filter watches by label.
+func waitForObjects(t *testing.T, p *Publisher, count int) {
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(listCapacities(p)) == count, nil
+	})
+	if err != nil {
+		t.Fatalf("informer holds %d objects of the node, want %d", len(listCapacities(p)), count)
+	}
+}
+
+func Test_PublisherSync(t *testing.T) {
+	classes := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	classes.Add(newClass("hpp-a", testProvisioner))
+	classes.Add(newClass("hpp-b", testProvisioner))
+	classes.Add(newClass("other", "example.com/other"))
+
+	allocatable := int64(10 * 1024 * 1024 * 1024)
+	capacityFunc := func() (capacity.Capacity, error) {
+		return capacity.Capacity{Allocatable: allocatable}, nil
+	}
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	other := NewPublisher(client, "", "hpp", "node-2", testProvisioner, nil, classes, capacityFunc)
+	p := NewPublisher(client, "", "hpp", "node-1", testProvisioner, nil, classes, capacityFunc)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go other.objects.Run(stopCh)
+	go p.objects.Run(stopCh)
+	cache.WaitForCacheSync(stopCh, other.objects.HasSynced, p.objects.HasSynced)
+
+	if err := other.Sync(); err != nil {
+		t.Fatalf("Sync() of node-2 error = %v", err)
+	}
+	if err := p.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	waitForObjects(t, p, 2)
+	if got := listCapacities(p); len(got) != 2 || got["hpp-a"] != "10Gi" || got["hpp-b"] != "10Gi" {
+		t.Errorf("after first Sync() capacities of node-1 = %v", got)
+	}
+	list, err := client.Resource(p.resource).Namespace("hpp").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list.Items) != 4 {
+		t.Errorf("after first Sync() there are %d objects, want 4", len(list.Items))
+	}
+
+	// node-1 leaves the objects of node-2 alone
+	allocatable = 4 * 1024 * 1024 * 1024
+	classes.Delete(newClass("hpp-b", testProvisioner))
+	if err := p.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	waitForObjects(t, p, 1)
+	// the update of hpp-a reaches the informer after the deletion of hpp-b
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return listCapacities(p)["hpp-a"] == "4Gi", nil
+	})
+	if got := listCapacities(p); err != nil || len(got) != 1 {
+		t.Errorf("after second Sync() capacities of node-1 = %v", got)
+	}
+	if got := listCapacities(other); len(got) != 2 {
+		t.Errorf("after second Sync() capacities of node-2 = %v", got)
+	}
+}
+
+func Test_nodeLabel(t *testing.T) {
+	long := strings.Repeat("n", 64)
+	tests := []struct {
+		name     string
+		nodeName string
+		want     string
+	}{
+		{name: "keeps a short node name", nodeName: "node-1", want: "node-1"},
+		{name: "hashes a node name longer than a label value", nodeName: long, want: nodeLabel(long)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nodeLabel(tt.nodeName)
+			if got != tt.want || len(validation.IsValidLabelValue(got)) != 0 {
+				t.Errorf("nodeLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ValidateDriverName(t *testing.T) {
+	tests := []struct {
+		name            string
+		provisionerName string
+		wantErr         bool
+	}{
+		{name: "accepts a domain name", provisionerName: "hostpath.kubevirt.io"},
+		{name: "refuses a name with a path", provisionerName: "kubevirt.io/hostpath-provisioner", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateDriverName(tt.provisionerName); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDriverName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
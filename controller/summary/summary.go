@@ -0,0 +1,349 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package summary
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	glog "k8s.io/klog"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/util"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	monitor_disk "kubevirt.io/hostpath-provisioner/controller/monitor-disk"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	"kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned"
+	listers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/listers/diskmonitor/v2"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+	"kubevirt.io/hostpath-provisioner/controller/webhook"
+)
+
+const (
+	// DefaultName is the name of the HostPathClusterSummary.
+	DefaultName = "cluster"
+	// DefaultPeriod is how often the summary is refreshed when nothing
+	// triggers an earlier refresh.
+	DefaultPeriod = time.Minute
+
+	annSelectedNode = "volume.kubernetes.io/selected-node"
+)
+
+// Aggregator sums up the DiskMonitors of every node into the
+// HostPathClusterSummary. Only one aggregator must run in the cluster, it is
+// run by the elected manager.
+type Aggregator struct {
+	client          versioned.Interface
+	name            string
+	namespace       string
+	provisionerName string
+	diskMonitors    listers.DiskMonitorLister
+	claims          corelisters.PersistentVolumeClaimLister
+	classes         storagelisters.StorageClassLister
+	volumes         *nodevolumes.Index
+	groups          *claimgroups.Groups
+	// picker finds out whether a pending claim fits on any node, the same way
+	// the placement of claims does
+	picker  *placement.NodePicker
+	period  time.Duration
+	trigger chan struct{}
+	now     func() time.Time
+}
+
+// NewAggregator returns an Aggregator of the DiskMonitors in namespace, read
+// from diskMonitors. capacities returns the capacity of the pool of a node,
+// admit whether the node takes a claim and may be nil.
+func NewAggregator(client versioned.Interface, namespace, provisionerName string, diskMonitors listers.DiskMonitorLister,
+	claims corelisters.PersistentVolumeClaimLister, classes storagelisters.StorageClassLister, nodes corelisters.NodeLister,
+	volumes *nodevolumes.Index, groups *claimgroups.Groups, capacities capacity.NodeCapacityFunc, admit placement.AdmitFunc) *Aggregator {
+	return &Aggregator{
+		client:          client,
+		name:            DefaultName,
+		namespace:       namespace,
+		provisionerName: provisionerName,
+		diskMonitors:    diskMonitors,
+		claims:          claims,
+		classes:         classes,
+		volumes:         volumes,
+		groups:          groups,
+		picker:          &placement.NodePicker{Nodes: nodes, Volumes: volumes, Capacities: capacities, Admit: admit},
+		period:          DefaultPeriod,
+		trigger:         make(chan struct{}, 1),
+		now:             time.Now,
+	}
+}
+
+// Trigger asks for the summary to be refreshed as soon as possible.
+func (a *Aggregator) Trigger() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// EventHandler returns a handler triggering a refresh on every change, to be
+// added to the informers the summary is computed from.
+func (a *Aggregator) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { a.Trigger() },
+		UpdateFunc: func(interface{}, interface{}) { a.Trigger() },
+		DeleteFunc: func(interface{}) { a.Trigger() },
+	}
+}
+
+// Run refreshes the summary until stopCh is closed.
+func (a *Aggregator) Run(stopCh <-chan struct{}) {
+	glog.Infof("Starting HostPathClusterSummary %s aggregator", a.name)
+	ticker := time.NewTicker(a.period)
+	defer ticker.Stop()
+	for {
+		if err := a.Sync(); err != nil {
+			glog.Errorf("Failed to update HostPathClusterSummary %s: %v", a.name, err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-a.trigger:
+		}
+	}
+}
+
+// Sync creates the summary when it is missing and writes its status when it
+// differs from the computed one.
+func (a *Aggregator) Sync() error {
+	status, err := a.Compute()
+	if err != nil {
+		return err
+	}
+	summaries := a.client.DiskMonitorV2().HostPathClusterSummaries()
+	current, err := summaries.Get(context.TODO(), a.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		current, err = summaries.Create(context.TODO(), &v2.HostPathClusterSummary{ObjectMeta: metav1.ObjectMeta{Name: a.name}}, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	status.LastUpdateTime = current.Status.LastUpdateTime
+	if equality.Semantic.DeepEqual(*status, current.Status) {
+		return nil
+	}
+	now := metav1.NewTime(a.now())
+	status.LastUpdateTime = &now
+	summary := current.DeepCopy()
+	summary.Status = *status
+	_, err = summaries.UpdateStatus(context.TODO(), summary, metav1.UpdateOptions{})
+	return err
+}
+
+// Compute returns the summary of the DiskMonitors, the storage classes of the
+// provisioner and their pending claims. The capacity of a node is counted in
+// the pool of the node; DiskMonitors that report no capacity yet are skipped.
+func (a *Aggregator) Compute() (*v2.HostPathClusterSummaryStatus, error) {
+	monitors, err := a.diskMonitors.DiskMonitors(a.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	classes, err := a.classes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	claims, err := a.claims.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &v2.HostPathClusterSummaryStatus{}
+	pools := map[string]*v2.PoolSummary{}
+	pool := func(name string) *v2.PoolSummary {
+		if _, ok := pools[name]; !ok {
+			pools[name] = &v2.PoolSummary{Name: name}
+		}
+		return pools[name]
+	}
+	for _, monitor := range monitors {
+		if monitor.Status.Total == nil {
+			continue
+		}
+		node := nodeSummary(monitor)
+		status.NodesByFree = append(status.NodesByFree, node)
+		status.Nodes++
+		status.Volumes += node.Volumes
+		add(&status.CapacitySummary, &node.CapacitySummary)
+		nodePool := pool(node.Pool)
+		nodePool.Nodes++
+		nodePool.Volumes += node.Volumes
+		add(&nodePool.CapacitySummary, &node.CapacitySummary)
+	}
+	sort.Slice(status.NodesByFree, func(i, j int) bool {
+		a, b := status.NodesByFree[i], status.NodesByFree[j]
+		if c := a.Free.Cmp(b.Free); c != 0 {
+			return c > 0
+		}
+		return a.Name < b.Name
+	})
+
+	classSummaries := map[string]*v2.StorageClassSummary{}
+	for _, class := range classes {
+		if class.Provisioner != a.provisionerName {
+			continue
+		}
+		classPool := pool(classPool(class))
+		classSummary := &v2.StorageClassSummary{
+			Name:            class.Name,
+			Pool:            classPool.Name,
+			CapacitySummary: *classPool.CapacitySummary.DeepCopy(),
+		}
+		classSummary.Requested = resource.Quantity{}
+		for _, pv := range a.volumes.ByStorageClass(class.Name) {
+			classSummary.Volumes++
+			classSummary.Requested.Add(*pv.Spec.Capacity.Storage())
+		}
+		classSummaries[class.Name] = classSummary
+	}
+	// claims are placed oldest first, each one in the room the claims placed
+	// before it left, like the manager accounts for its pending assignments
+	sort.Slice(claims, func(i, j int) bool {
+		if !claims[i].CreationTimestamp.Equal(&claims[j].CreationTimestamp) {
+			return claims[i].CreationTimestamp.Before(&claims[j].CreationTimestamp)
+		}
+		return claims[i].Namespace+"/"+claims[i].Name < claims[j].Namespace+"/"+claims[j].Name
+	})
+	placed := newPlacements()
+	for _, claim := range claims {
+		classSummary, ok := classSummaries[util.GetPersistentVolumeClaimClass(claim)]
+		if !ok || !a.unschedulable(claim, placed) {
+			continue
+		}
+		status.UnschedulableClaims++
+		classSummary.UnschedulableClaims++
+		claimPool := classSummary.Pool
+		if name, ok := claim.Annotations[nodevolumes.AnnPool]; ok {
+			claimPool = name
+		}
+		pool(claimPool).UnschedulableClaims++
+	}
+
+	for _, poolSummary := range pools {
+		status.Pools = append(status.Pools, *poolSummary)
+	}
+	sort.Slice(status.Pools, func(i, j int) bool { return status.Pools[i].Name < status.Pools[j].Name })
+	for _, classSummary := range classSummaries {
+		status.StorageClasses = append(status.StorageClasses, *classSummary)
+	}
+	sort.Slice(status.StorageClasses, func(i, j int) bool { return status.StorageClasses[i].Name < status.StorageClasses[j].Name })
+	return status, nil
+}
+
+// placements holds, per node, the size and number of the pending claims
+// placed on the node so far.
+type placements struct {
+	size    map[string]int64
+	volumes map[string]int
+}
+
+func newPlacements() *placements {
+	return &placements{size: map[string]int64{}, volumes: map[string]int{}}
+}
+
+// unschedulable returns whether the claim waits for a volume and no node can
+// hold it next to the claims placed already, the same way the manager places
+// claims. A claim that fits is added to placed. Claims that have a node
+// already are left to the provisioner of that node.
+func (a *Aggregator) unschedulable(claim *v1.PersistentVolumeClaim, placed *placements) bool {
+	if claim.Spec.VolumeName != "" || claim.Status.Phase == v1.ClaimBound || claim.Status.Phase == v1.ClaimLost {
+		return false
+	}
+	if _, ok := claim.Annotations[nodevolumes.AnnProvisionOnNode]; ok {
+		return false
+	}
+	if _, ok := claim.Annotations[annSelectedNode]; ok {
+		return false
+	}
+	selector := labels.Everything()
+	if s, ok := claim.Annotations[placement.AnnNodeSelector]; ok {
+		var err error
+		if selector, err = labels.Parse(s); err != nil {
+			return true
+		}
+	}
+	constraint := &claimgroups.Constraint{Requested: claim.Spec.Resources.Requests.Storage().Value()}
+	if a.groups != nil {
+		constraint = a.groups.Constraint(claim)
+	}
+	node, err := a.picker.Pick(selector, util.GetPersistentVolumeClaimClass(claim), constraint.Requested, constraint, placed.size, placed.volumes)
+	if err != nil {
+		return true
+	}
+	placed.size[node] += claim.Spec.Resources.Requests.Storage().Value()
+	placed.volumes[node]++
+	return false
+}
+
+// nodeSummary returns the summary of the pool of the node of the DiskMonitor.
+func nodeSummary(monitor *v2.DiskMonitor) v2.NodeSummary {
+	status := &monitor.Status
+	node := v2.NodeSummary{
+		Name:    monitor.Name,
+		Pool:    status.Pool,
+		Ready:   monitor_disk.IsConditionTrue(status, v2.DiskMonitorReady),
+		Volumes: int32(len(status.Volumes)),
+	}
+	// agents that do not report their pool yet are in the default one
+	if node.Pool == "" {
+		node.Pool = noderesources.DefaultPool
+	}
+	node.Total = status.Total.DeepCopy()
+	if status.Required != nil {
+		node.Requested = status.Required.DeepCopy()
+	}
+	if status.Free != nil {
+		node.Free = status.Free.DeepCopy()
+	}
+	if status.Allocatable != nil {
+		node.Allocatable = status.Allocatable.DeepCopy()
+	}
+	return node
+}
+
+// classPool returns the pool of the claims of the class that name none.
+func classPool(class *storage.StorageClass) string {
+	if pool, ok := class.Parameters[webhook.ParameterPool]; ok && pool != "" {
+		return pool
+	}
+	return noderesources.DefaultPool
+}
+
+func add(sum, summary *v2.CapacitySummary) {
+	sum.Total.Add(summary.Total)
+	sum.Requested.Add(summary.Requested)
+	sum.Free.Add(summary.Free)
+	sum.Allocatable.Add(summary.Allocatable)
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package summary
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	v2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+	diskmonitorfake "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/clientset/versioned/fake"
+	diskmonitorinformers "kubevirt.io/hostpath-provisioner/controller/monitor-disk/client/informers/externalversions"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+)
+
+const (
+	testNamespace   = "hostpath"
+	testProvisioner = "kubevirt.io/hostpath-provisioner"
+)
+
+func quantity(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func newDiskMonitor(name, pool string, total, required, free string, volumes int, ready bool) *v2.DiskMonitor {
+	monitor := &v2.DiskMonitor{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+	monitor.Status.Pool = pool
+	if total != "" {
+		monitor.Status.Total = quantity(total)
+		monitor.Status.Required = quantity(required)
+		monitor.Status.Free = quantity(free)
+		monitor.Status.Allocatable = quantity(free)
+	}
+	for i := 0; i < volumes; i++ {
+		monitor.Status.Volumes = append(monitor.Status.Volumes, v2.Volume{})
+	}
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	monitor.Status.Conditions = []v2.DiskMonitorCondition{{Type: v2.DiskMonitorReady, Status: status}}
+	return monitor
+}
+
+func newClaim(name, class, size string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &class,
+			Resources:        v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)}},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+}
+
+func newTestAggregator(t *testing.T) (*Aggregator, *diskmonitorfake.Clientset) {
+	client := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	claimInformer := factory.Core().V1().PersistentVolumeClaims()
+	volumes, err := nodevolumes.New(factory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	groups, err := claimgroups.New(claimInformer.Informer(), volumes.Informer())
+	if err != nil {
+		t.Fatalf("claimgroups.New() error = %v", err)
+	}
+
+	classes := factory.Storage().V1().StorageClasses().Informer().GetIndexer()
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, Provisioner: testProvisioner})
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Provisioner: testProvisioner,
+		Parameters: map[string]string{"pool": "fast"}})
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Provisioner: "other"})
+
+	nodes := factory.Core().V1().Nodes().Informer().GetIndexer()
+	for _, name := range []string{"node-1", "node-2", "node-3"} {
+		nodes.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	volumes.Informer().GetIndexer().Add(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1", Annotations: map[string]string{nodevolumes.AnnProvisionOnNode: "node-1"}},
+		Spec: v1.PersistentVolumeSpec{
+			StorageClassName: "standard",
+			Capacity:         v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+		},
+	})
+
+	for _, claim := range []*v1.PersistentVolumeClaim{
+		newClaim("fits", "standard", "1Gi", nil),
+		newClaim("too-big", "standard", "200Gi", nil),
+		newClaim("too-big-fast", "standard", "200Gi", map[string]string{nodevolumes.AnnPool: "fast"}),
+		newClaim("no-matching-node", "fast", "1Gi", map[string]string{placement.AnnNodeSelector: "disktype=ssd"}),
+		newClaim("selected", "standard", "200Gi", map[string]string{annSelectedNode: "node-1"}),
+		newClaim("other", "other", "200Gi", nil),
+	} {
+		claimInformer.Informer().GetIndexer().Add(claim)
+	}
+	bound := newClaim("bound", "standard", "200Gi", nil)
+	bound.Spec.VolumeName = "pv-1"
+	bound.Status.Phase = v1.ClaimBound
+	claimInformer.Informer().GetIndexer().Add(bound)
+
+	diskMonitorClient := diskmonitorfake.NewSimpleClientset()
+	diskMonitorFactory := diskmonitorinformers.NewSharedInformerFactory(diskMonitorClient, 0)
+	diskMonitors := diskMonitorFactory.DiskMonitor().V2().DiskMonitors().Informer().GetIndexer()
+	diskMonitors.Add(newDiskMonitor("node-1", "", "100Gi", "10Gi", "60Gi", 1, true))
+	diskMonitors.Add(newDiskMonitor("node-2", "fast", "50Gi", "0", "40Gi", 0, false))
+	// the agent of node-3 did not report yet
+	diskMonitors.Add(newDiskMonitor("node-3", "", "", "", "", 0, false))
+
+	allocatable := map[string]int64{"node-1": 60 << 30, "node-2": 40 << 30}
+	capacities := func(nodeName string) (capacity.Capacity, bool) {
+		a, ok := allocatable[nodeName]
+		return capacity.Capacity{Allocatable: a}, ok
+	}
+	a := NewAggregator(diskMonitorClient, testNamespace, testProvisioner, diskMonitorFactory.DiskMonitor().V2().DiskMonitors().Lister(),
+		claimInformer.Lister(), factory.Storage().V1().StorageClasses().Lister(), factory.Core().V1().Nodes().Lister(), volumes, groups, capacities, nil)
+	return a, diskMonitorClient
+}
+
+func capacitySummary(total, requested, free string) v2.CapacitySummary {
+	return v2.CapacitySummary{
+		Total:       resource.MustParse(total),
+		Requested:   resource.MustParse(requested),
+		Free:        resource.MustParse(free),
+		Allocatable: resource.MustParse(free),
+	}
+}
+
+func Test_Compute(t *testing.T) {
+	a, _ := newTestAggregator(t)
+	got, err := a.Compute()
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+
+	if got.Nodes != 2 || got.Volumes != 1 || got.UnschedulableClaims != 3 {
+		t.Errorf("Compute() nodes, volumes, unschedulable = %d, %d, %d, want 2, 1, 3", got.Nodes, got.Volumes, got.UnschedulableClaims)
+	}
+	want := capacitySummary("150Gi", "10Gi", "100Gi")
+	if got.Total.Cmp(want.Total) != 0 || got.Requested.Cmp(want.Requested) != 0 || got.Free.Cmp(want.Free) != 0 {
+		t.Errorf("Compute() capacity = %v, want %v", got.CapacitySummary, want)
+	}
+
+	if len(got.NodesByFree) != 2 || got.NodesByFree[0].Name != "node-1" || got.NodesByFree[1].Name != "node-2" {
+		t.Fatalf("Compute() nodesByFree = %v, want node-1, node-2", got.NodesByFree)
+	}
+	if node := got.NodesByFree[0]; node.Pool != "default" || !node.Ready || node.Volumes != 1 {
+		t.Errorf("Compute() node-1 = %+v, want pool default, ready, 1 volume", node)
+	}
+	if node := got.NodesByFree[1]; node.Pool != "fast" || node.Ready {
+		t.Errorf("Compute() node-2 = %+v, want pool fast, not ready", node)
+	}
+
+	wantPools := []struct {
+		name          string
+		nodes         int32
+		total         string
+		unschedulable int32
+	}{
+		{name: "default", nodes: 1, total: "100Gi", unschedulable: 1},
+		{name: "fast", nodes: 1, total: "50Gi", unschedulable: 2},
+	}
+	if len(got.Pools) != len(wantPools) {
+		t.Fatalf("Compute() pools = %v, want %d pools", got.Pools, len(wantPools))
+	}
+	for i, tt := range wantPools {
+		pool := got.Pools[i]
+		if pool.Name != tt.name || pool.Nodes != tt.nodes || pool.Total.Cmp(resource.MustParse(tt.total)) != 0 || pool.UnschedulableClaims != tt.unschedulable {
+			t.Errorf("Compute() pool %d = %+v, want %s with %d nodes, total %s and %d unschedulable", i, pool, tt.name, tt.nodes, tt.total, tt.unschedulable)
+		}
+	}
+
+	wantClasses := []struct {
+		name          string
+		pool          string
+		volumes       int32
+		requested     string
+		total         string
+		unschedulable int32
+	}{
+		{name: "fast", pool: "fast", total: "50Gi", requested: "0", unschedulable: 1},
+		{name: "standard", pool: "default", volumes: 1, total: "100Gi", requested: "10Gi", unschedulable: 2},
+	}
+	if len(got.StorageClasses) != len(wantClasses) {
+		t.Fatalf("Compute() storageClasses = %v, want %d classes", got.StorageClasses, len(wantClasses))
+	}
+	for i, tt := range wantClasses {
+		class := got.StorageClasses[i]
+		if class.Name != tt.name || class.Pool != tt.pool || class.Volumes != tt.volumes || class.UnschedulableClaims != tt.unschedulable ||
+			class.Total.Cmp(resource.MustParse(tt.total)) != 0 || class.Requested.Cmp(resource.MustParse(tt.requested)) != 0 {
+			t.Errorf("Compute() storage class %d = %+v, want %+v", i, class, tt)
+		}
+	}
+}
+
+func Test_unschedulable(t *testing.T) {
+	a, _ := newTestAggregator(t)
+	placed := newPlacements()
+	// node-1 has room for one of the claims, node-2 for none
+	if a.unschedulable(newClaim("burst-1", "standard", "50Gi", nil), placed) {
+		t.Errorf("unschedulable() of the first claim = true, want false")
+	}
+	if placed.size["node-1"] != 50<<30 || placed.volumes["node-1"] != 1 {
+		t.Errorf("unschedulable() placed = %+v, want the first claim on node-1", placed)
+	}
+	if !a.unschedulable(newClaim("burst-2", "standard", "50Gi", nil), placed) {
+		t.Errorf("unschedulable() of the second claim = false, want true")
+	}
+}
+
+func Test_Sync(t *testing.T) {
+	a, client := newTestAggregator(t)
+	now := time.Unix(1600000000, 0)
+	a.now = func() time.Time { return now }
+
+	if err := a.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	summary, err := client.DiskMonitorV2().HostPathClusterSummaries().Get(context.TODO(), DefaultName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Sync() should create the summary, error = %v", err)
+	}
+	if summary.Status.Nodes != 2 || summary.Status.LastUpdateTime == nil || !summary.Status.LastUpdateTime.Time.Equal(now) {
+		t.Errorf("Sync() status = %+v, want 2 nodes updated at %v", summary.Status, now)
+	}
+
+	// nothing changed, the status is left alone
+	now = now.Add(time.Minute)
+	if err := a.Sync(); err != nil {
+		t.Fatalf("Sync() again error = %v", err)
+	}
+	summary, _ = client.DiskMonitorV2().HostPathClusterSummaries().Get(context.TODO(), DefaultName, metav1.GetOptions{})
+	if !summary.Status.LastUpdateTime.Time.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Sync() without change updated the status at %v", summary.Status.LastUpdateTime)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directory is the directory of the trash, below the directory of the volumes.
+// Being hidden, it is not taken for a volume or an orphan.
+const Directory = ".trash"
+
+// Trash moves directories of a pool into its trash and removes them once their
+// retention is over. A directory is moved to "<name>-<unix time>", the time it
+// was trashed.
+type Trash struct {
+	dir string
+	now func() time.Time
+}
+
+// New returns the Trash of the pool at pvDir.
+func New(pvDir string) *Trash {
+	return &Trash{
+		dir: filepath.Join(pvDir, Directory),
+		now: time.Now,
+	}
+}
+
+// Move moves the directory at path into the trash. path must be on the
+// filesystem of the pool.
+func (t *Trash) Move(path string) error {
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return err
+	}
+	target := filepath.Join(t.dir, fmt.Sprintf("%s-%d", filepath.Base(path), t.now().Unix()))
+	return os.Rename(path, target)
+}
+
+// Purge removes the entries trashed more than retention ago and returns their
+// paths. Entries not named by Move are left alone.
+func (t *Trash) Purge(retention time.Duration) ([]string, error) {
+	infos, err := ioutil.ReadDir(t.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var purged []string
+	for _, info := range infos {
+		trashedAt, ok := trashTime(info.Name())
+		if !ok || t.now().Sub(trashedAt) < retention {
+			continue
+		}
+		path := filepath.Join(t.dir, info.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return purged, err
+		}
+		purged = append(purged, path)
+	}
+	return purged, nil
+}
+
+// trashTime returns when the entry was trashed, from its name.
+func trashTime(name string) (time.Time, bool) {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
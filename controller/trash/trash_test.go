@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_MovePurge(t *testing.T) {
+	pvDir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	defer os.RemoveAll(pvDir)
+	now := time.Unix(1600000000, 0)
+	trash := New(pvDir)
+	trash.now = func() time.Time { return now }
+
+	for _, name := range []string{"pvc-old", "pvc-new"} {
+		if err := os.MkdirAll(filepath.Join(pvDir, name, "data"), 0777); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+	}
+	if err := trash.Move(filepath.Join(pvDir, "pvc-old")); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := trash.Move(filepath.Join(pvDir, "pvc-new")); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pvDir, "pvc-old")); !os.IsNotExist(err) {
+		t.Errorf("Move() left the directory in place")
+	}
+	if err := os.MkdirAll(filepath.Join(pvDir, Directory, "unknown"), 0777); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		retention time.Duration
+		want      []string
+	}{
+		{
+			name:      "nothing expired",
+			retention: 2 * time.Hour,
+		},
+		{
+			name:      "oldest expired",
+			retention: 30 * time.Minute,
+			want:      []string{filepath.Join(pvDir, Directory, "pvc-old-1600000000")},
+		},
+		{
+			name:      "everything expired",
+			retention: 0,
+			want:      []string{filepath.Join(pvDir, Directory, "pvc-new-1600003600")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := trash.Purge(tt.retention)
+			if err != nil {
+				t.Fatalf("Purge() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Purge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(pvDir, Directory, "unknown")); err != nil {
+		t.Errorf("Purge() removed an entry it did not trash: %v", err)
+	}
+}
+
+func Test_PurgeWithoutTrash(t *testing.T) {
+	pvDir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	defer os.RemoveAll(pvDir)
+	if got, err := New(pvDir).Purge(0); err != nil || got != nil {
+		t.Errorf("Purge() = %v, %v, want nil, nil", got, err)
+	}
+}
@@ -80,6 +80,19 @@ type ProvisionerExt interface {
 	ProvisionExt(options ProvisionOptions) (*v1.PersistentVolume, ProvisioningState, error)
 }
 
+// CapacityReserver is an optional interface implemented by provisioners that
+// reserve capacity for a claim when provisioning starts. The controller tells
+// the provisioner when the reservation became a stored PV and when it is no
+// longer needed because provisioning failed or was given up.
+type CapacityReserver interface {
+	// ConfirmReservation is called once the PV provisioned for the claim has
+	// been stored.
+	ConfirmReservation(claim *v1.PersistentVolumeClaim, volume *v1.PersistentVolume)
+	// ReleaseReservation is called when provisioning of the claim with the
+	// given UID ended without a stored PV.
+	ReleaseReservation(claimUID string)
+}
+
 // ProvisioningState is state of volume provisioning. It tells the controller if
 // provisioning could be in progress in the background after ProvisionExt() call
 // returns or the provisioning is 100% finished (either with success or error).
@@ -100,6 +113,12 @@ const (
 	// first ProvisionExt call, ProvisioningFinished is assumed (the provisioning
 	// could not even start).
 	ProvisioningNoChange ProvisioningState = "NoChange"
+	// ProvisioningReschedule tells the controller that provisioning failed on
+	// the selected node and will not succeed there, e.g. because the node does
+	// not have enough capacity. The controller stops provisioning and removes
+	// the selected node from the claim, so the scheduler picks another node.
+	// For claims without a selected node it is the same as ProvisioningFinished.
+	ProvisioningReschedule ProvisioningState = "Reschedule"
 )
 
 // IgnoredError is the value for Delete to return to indicate that the call has
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package warmpool
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// ParameterDirectories is the StorageClass parameter holding the number of
+	// directories kept ready on every node.
+	ParameterDirectories = "warmPoolDirectories"
+	// ParameterVolumes is the StorageClass parameter listing the standard sizes
+	// of the persistent volumes kept ready on every node, e.g. "10Gi:2,50Gi:1".
+	ParameterVolumes = "warmPoolVolumes"
+)
+
+// Size is a standard volume size and how many volumes of it are kept ready.
+type Size struct {
+	Size  resource.Quantity
+	Count int
+}
+
+// Config is the warm pool of a storage class on a node.
+type Config struct {
+	Directories int
+	// Volumes is sorted by size
+	Volumes []Size
+}
+
+// Enabled returns whether anything is kept ready.
+func (c Config) Enabled() bool {
+	return c.Directories > 0 || len(c.Volumes) > 0
+}
+
+// ParseConfig reads the warm pool from the parameters of a storage class.
+func ParseConfig(parameters map[string]string) (Config, error) {
+	var config Config
+	if value := strings.TrimSpace(parameters[ParameterDirectories]); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return Config{}, fmt.Errorf("invalid %s %q: must be a non-negative integer", ParameterDirectories, value)
+		}
+		config.Directories = count
+	}
+	value := strings.TrimSpace(parameters[ParameterVolumes])
+	if value == "" {
+		return config, nil
+	}
+	seen := map[int64]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 2 {
+			return Config{}, fmt.Errorf("invalid %s entry %q: must be <size>:<count>", ParameterVolumes, entry)
+		}
+		size, err := resource.ParseQuantity(strings.TrimSpace(fields[0]))
+		if err != nil || size.Sign() <= 0 {
+			return Config{}, fmt.Errorf("invalid %s entry %q: size must be a positive quantity", ParameterVolumes, entry)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || count < 0 {
+			return Config{}, fmt.Errorf("invalid %s entry %q: count must be a non-negative integer", ParameterVolumes, entry)
+		}
+		if seen[size.Value()] {
+			return Config{}, fmt.Errorf("invalid %s entry %q: size listed twice", ParameterVolumes, entry)
+		}
+		seen[size.Value()] = true
+		if count > 0 {
+			config.Volumes = append(config.Volumes, Size{Size: size, Count: count})
+		}
+	}
+	sort.Slice(config.Volumes, func(i, j int) bool {
+		return config.Volumes[i].Size.Cmp(config.Volumes[j].Size) < 0
+	})
+	return config, nil
+}
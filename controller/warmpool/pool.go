@@ -0,0 +1,348 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package warmpool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/cache"
+	glog "k8s.io/klog"
+	"kubevirt.io/hostpath-provisioner/controller/metrics"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const (
+	// LabelWarmPool marks the persistent volumes created ahead of a claim.
+	LabelWarmPool = "hostpath.kubevirt.io/warm-pool"
+	// AnnClaimed is set on a warm volume to the UID of the claim it was handed
+	// over to.
+	AnnClaimed = "hostpath.kubevirt.io/warm-pool-claim"
+	// Directory is the directory of the pool, below the directory of the volumes,
+	// holding one directory of ready directories per storage class.
+	Directory = ".warm"
+	// DefaultRefillPeriod is how often the pool is refilled when nothing
+	// triggers an earlier refill.
+	DefaultRefillPeriod = 30 * time.Second
+
+	// KindDirectory and KindVolume break the metrics down by what was pre-created.
+	KindDirectory = "directory"
+	KindVolume    = "volume"
+)
+
+// CreateVolumeFunc creates a persistent volume of the storage class and size on
+// the node and accounts for it in the capacity of the pool.
+type CreateVolumeFunc func(class *storage.StorageClass, size resource.Quantity) (*v1.PersistentVolume, error)
+
+// HandOverFunc applies to a warm volume the PV controller bound to a claim
+// what the claim asks of its volume, such as the permissions of its directory
+// and its pool, and sets AnnClaimed. It is called again until it succeeds.
+type HandOverFunc func(pv *v1.PersistentVolume) error
+
+// Pool keeps the warm pool of every storage class of the provisioner filled on
+// a node. Directories are taken by the provisioner and renamed into the
+// volume; persistent volumes are bound by the PV controller like any other
+// available volume, and handed over to their claim afterwards.
+type Pool struct {
+	dir             string
+	nodeName        string
+	provisionerName string
+	classes         cache.Store
+	volumes         *nodevolumes.Index
+	createVolume    CreateVolumeFunc
+	handOver        HandOverFunc
+	refillPeriod    time.Duration
+	trigger         chan struct{}
+
+	// mutex serializes the refills and guards available
+	mutex sync.Mutex
+	// available holds the storage class of the unbound warm volumes by name,
+	// so that a hit is counted when the PV controller binds one
+	available map[string]string
+}
+
+// New returns a Pool below pvDir. classes is a StorageClass store.
+func New(pvDir, nodeName, provisionerName string, classes cache.Store, volumes *nodevolumes.Index, createVolume CreateVolumeFunc, handOver HandOverFunc) *Pool {
+	return &Pool{
+		dir:             filepath.Join(pvDir, Directory),
+		nodeName:        nodeName,
+		provisionerName: provisionerName,
+		classes:         classes,
+		volumes:         volumes,
+		createVolume:    createVolume,
+		handOver:        handOver,
+		refillPeriod:    DefaultRefillPeriod,
+		trigger:         make(chan struct{}, 1),
+		available:       map[string]string{},
+	}
+}
+
+// Trigger asks for the pool to be refilled as soon as possible.
+func (p *Pool) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// EventHandler returns a PV event handler triggering a refill as soon as a
+// warm volume is bound, so it is handed over before its claim is used.
+func (p *Pool) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) {
+			if pv, ok := obj.(*v1.PersistentVolume); ok && pv.Labels[LabelWarmPool] == "true" && needsHandOver(pv) {
+				p.Trigger()
+			}
+		},
+	}
+}
+
+// Run refills the pool until stopCh is closed.
+func (p *Pool) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.refillPeriod)
+	defer ticker.Stop()
+	for {
+		if err := p.Refill(); err != nil {
+			glog.Errorf("failed to refill the warm pool of node %s: %v", p.nodeName, err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-p.trigger:
+		}
+	}
+}
+
+// Take moves a ready directory of the storage class to path and returns
+// whether it did. The caller creates the directory itself when it did not. A
+// miss is only counted for storage classes with a warm pool.
+func (p *Pool) Take(class *storage.StorageClass, path string) bool {
+	if class == nil {
+		return false
+	}
+	config, err := ParseConfig(class.Parameters)
+	if err != nil || !config.Enabled() {
+		return false
+	}
+	if _, err := os.Stat(path); err == nil {
+		// a retry of a volume that was created already
+		return false
+	}
+	if config.Directories > 0 {
+		for _, name := range p.directories(class.Name) {
+			if err := os.Rename(filepath.Join(p.dir, class.Name, name), path); err != nil {
+				glog.Errorf("unable to take warm directory %s of storage class %s: %v", name, class.Name, err)
+				continue
+			}
+			metrics.WarmPoolHitsTotal.WithLabelValues(p.nodeName, class.Name, KindDirectory).Inc()
+			p.Trigger()
+			return true
+		}
+	}
+	metrics.WarmPoolMissesTotal.WithLabelValues(p.nodeName, class.Name).Inc()
+	p.Trigger()
+	return false
+}
+
+// Volumes returns the warm volumes created on the node, bound or not.
+func (p *Pool) Volumes() []*v1.PersistentVolume {
+	var pvs []*v1.PersistentVolume
+	for _, pv := range p.volumes.ByNode(p.nodeName) {
+		if pv.Labels[LabelWarmPool] == "true" {
+			pvs = append(pvs, pv)
+		}
+	}
+	return pvs
+}
+
+// Refill tops up the directories and volumes of every storage class of the
+// provisioner, removes the directories of storage classes without a warm pool,
+// counts the warm volumes bound since the last refill and hands them over.
+func (p *Pool) Refill() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var errs []error
+	unbound := map[string][]*v1.PersistentVolume{}
+	seen := map[string]bool{}
+	for _, pv := range p.Volumes() {
+		if needsHandOver(pv) {
+			if err := p.handOver(pv); err != nil {
+				errs = append(errs, fmt.Errorf("hand over of volume %s: %v", pv.Name, err))
+			}
+		}
+		seen[pv.Name] = true
+		if isUnbound(pv) {
+			unbound[pv.Spec.StorageClassName] = append(unbound[pv.Spec.StorageClassName], pv)
+			if _, ok := p.available[pv.Name]; !ok {
+				p.available[pv.Name] = pv.Spec.StorageClassName
+			}
+		} else if class, ok := p.available[pv.Name]; ok && pv.Spec.ClaimRef != nil {
+			metrics.WarmPoolHitsTotal.WithLabelValues(p.nodeName, class, KindVolume).Inc()
+			delete(p.available, pv.Name)
+		}
+	}
+	for name := range p.available {
+		if !seen[name] {
+			delete(p.available, name)
+		}
+	}
+
+	classes := map[string]bool{}
+	for _, obj := range p.classes.List() {
+		class, ok := obj.(*storage.StorageClass)
+		if !ok || class.Provisioner != p.provisionerName {
+			continue
+		}
+		config, err := ParseConfig(class.Parameters)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("storage class %s: %v", class.Name, err))
+			continue
+		}
+		if !config.Enabled() {
+			continue
+		}
+		classes[class.Name] = true
+		if err := p.refillDirectories(class.Name, config.Directories); err != nil {
+			errs = append(errs, fmt.Errorf("storage class %s: %v", class.Name, err))
+		}
+		if err := p.refillVolumes(class, config.Volumes, unbound[class.Name]); err != nil {
+			errs = append(errs, fmt.Errorf("storage class %s: %v", class.Name, err))
+		}
+	}
+	if err := p.removeDirectories(classes); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// refillDirectories keeps count directories ready for the storage class.
+func (p *Pool) refillDirectories(class string, count int) error {
+	dir := filepath.Join(p.dir, class)
+	names := p.directories(class)
+	for i := len(names); i < count; i++ {
+		name := filepath.Join(dir, utilrand.String(10))
+		if err := os.MkdirAll(name, 0777); err != nil {
+			return err
+		}
+		glog.V(2).Infof("created warm directory %s", name)
+	}
+	for i := count; i < len(names); i++ {
+		if err := os.RemoveAll(filepath.Join(dir, names[i])); err != nil {
+			return err
+		}
+	}
+	if count == 0 {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	metrics.WarmPoolSize.WithLabelValues(p.nodeName, class, KindDirectory).Set(float64(count))
+	return nil
+}
+
+// refillVolumes creates the missing volumes of every standard size of the
+// storage class. Volumes are only created for WaitForFirstConsumer storage
+// classes: the PV controller binds an Immediate claim to an available volume
+// of any node, ignoring the node the claim asks for.
+func (p *Pool) refillVolumes(class *storage.StorageClass, sizes []Size, unbound []*v1.PersistentVolume) error {
+	defer func() {
+		metrics.WarmPoolSize.WithLabelValues(p.nodeName, class.Name, KindVolume).Set(float64(len(unbound)))
+	}()
+	if len(sizes) == 0 {
+		return nil
+	}
+	if class.VolumeBindingMode == nil || *class.VolumeBindingMode != storage.VolumeBindingWaitForFirstConsumer {
+		return fmt.Errorf("%s requires volumeBindingMode WaitForFirstConsumer", ParameterVolumes)
+	}
+	ready := map[int64]int{}
+	for _, pv := range unbound {
+		ready[pv.Spec.Capacity.Storage().Value()]++
+	}
+	for _, size := range sizes {
+		for i := ready[size.Size.Value()]; i < size.Count; i++ {
+			pv, err := p.createVolume(class, size.Size)
+			if err != nil {
+				return err
+			}
+			glog.V(2).Infof("created warm volume %s of %s for storage class %s", pv.Name, size.Size.String(), class.Name)
+			p.available[pv.Name] = class.Name
+			unbound = append(unbound, pv)
+		}
+	}
+	return nil
+}
+
+// removeDirectories removes the ready directories of the storage classes
+// that are gone or have no warm pool anymore.
+func (p *Pool) removeDirectories(classes map[string]bool) error {
+	infos, err := ioutil.ReadDir(p.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, info := range infos {
+		if classes[info.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(p.dir, info.Name())); err != nil {
+			return err
+		}
+		metrics.WarmPoolSize.WithLabelValues(p.nodeName, info.Name(), KindDirectory).Set(0)
+	}
+	return nil
+}
+
+// directories returns the names of the ready directories of the storage class.
+func (p *Pool) directories(class string) []string {
+	infos, err := ioutil.ReadDir(filepath.Join(p.dir, class))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, info := range infos {
+		if info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	return names
+}
+
+// needsHandOver returns whether the warm volume is bound to a claim it was not
+// handed over to yet.
+func needsHandOver(pv *v1.PersistentVolume) bool {
+	return pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID != "" && pv.DeletionTimestamp == nil && pv.Status.Phase != v1.VolumeReleased &&
+		pv.Annotations[AnnClaimed] != string(pv.Spec.ClaimRef.UID)
+}
+
+func isUnbound(pv *v1.PersistentVolume) bool {
+	return pv.Spec.ClaimRef == nil && pv.DeletionTimestamp == nil && pv.Status.Phase != v1.VolumeReleased
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package warmpool
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+)
+
+const testProvisioner = "kubevirt.io/hostpath-provisioner"
+
+func Test_ParseConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		want       Config
+		wantErr    bool
+	}{
+		{
+			name: "no warm pool",
+			want: Config{},
+		},
+		{
+			name:       "directories",
+			parameters: map[string]string{ParameterDirectories: "3"},
+			want:       Config{Directories: 3},
+		},
+		{
+			name:       "volumes sorted by size",
+			parameters: map[string]string{ParameterVolumes: "50Gi:1, 10Gi:2,20Gi:0"},
+			want: Config{Volumes: []Size{
+				{Size: resource.MustParse("10Gi"), Count: 2},
+				{Size: resource.MustParse("50Gi"), Count: 1},
+			}},
+		},
+		{
+			name:       "negative directories",
+			parameters: map[string]string{ParameterDirectories: "-1"},
+			wantErr:    true,
+		},
+		{
+			name:       "missing count",
+			parameters: map[string]string{ParameterVolumes: "10Gi"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid size",
+			parameters: map[string]string{ParameterVolumes: "ten:1"},
+			wantErr:    true,
+		},
+		{
+			name:       "size listed twice",
+			parameters: map[string]string{ParameterVolumes: "10Gi:1,10240Mi:2"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(tt.parameters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Directories != tt.want.Directories || len(got.Volumes) != len(tt.want.Volumes) {
+				t.Fatalf("ParseConfig() = %v, want %v", got, tt.want)
+			}
+			for i := range got.Volumes {
+				if got.Volumes[i].Size.Cmp(tt.want.Volumes[i].Size) != 0 || got.Volumes[i].Count != tt.want.Volumes[i].Count {
+					t.Errorf("ParseConfig() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func newClass(name string, mode storage.VolumeBindingMode, parameters map[string]string) *storage.StorageClass {
+	return &storage.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: name},
+		Provisioner:       testProvisioner,
+		Parameters:        parameters,
+		VolumeBindingMode: &mode,
+	}
+}
+
+type testEnv struct {
+	pool    *Pool
+	pvDir   string
+	indexer cache.Indexer
+	created []string
+	// handedOver lists the volumes handed over, failHandOver makes it fail
+	handedOver   []string
+	failHandOver bool
+}
+
+func newTestEnv(t *testing.T, classes ...*storage.StorageClass) *testEnv {
+	pvDir, err := ioutil.TempDir("", "warmpool")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, class := range classes {
+		store.Add(class)
+	}
+	volumeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().PersistentVolumes().Informer()
+	volumes, err := nodevolumes.New(volumeInformer)
+	if err != nil {
+		t.Fatalf("nodevolumes.New() error = %v", err)
+	}
+	env := &testEnv{pvDir: pvDir, indexer: volumeInformer.GetIndexer()}
+	createVolume := func(class *storage.StorageClass, size resource.Quantity) (*v1.PersistentVolume, error) {
+		pv := &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        class.Name + "-" + size.String() + "-" + string(rune('a'+len(env.created))),
+				Labels:      map[string]string{LabelWarmPool: "true"},
+				Annotations: map[string]string{nodevolumes.AnnProvisionOnNode: "node-1"},
+			},
+			Spec: v1.PersistentVolumeSpec{
+				StorageClassName: class.Name,
+				Capacity:         v1.ResourceList{v1.ResourceStorage: size},
+			},
+		}
+		env.created = append(env.created, pv.Name)
+		env.indexer.Add(pv)
+		return pv, nil
+	}
+	handOver := func(pv *v1.PersistentVolume) error {
+		if env.failHandOver {
+			return errors.New("claim not reachable")
+		}
+		env.handedOver = append(env.handedOver, pv.Name)
+		claimed := pv.DeepCopy()
+		claimed.Annotations[AnnClaimed] = string(pv.Spec.ClaimRef.UID)
+		return env.indexer.Update(claimed)
+	}
+	env.pool = New(pvDir, "node-1", testProvisioner, store, volumes, createVolume, handOver)
+	return env
+}
+
+func (env *testEnv) directories(class string) int {
+	return len(env.pool.directories(class))
+}
+
+func Test_RefillDirectories(t *testing.T) {
+	env := newTestEnv(t,
+		newClass("hpp", storage.VolumeBindingImmediate, map[string]string{ParameterDirectories: "2"}),
+		newClass("plain", storage.VolumeBindingImmediate, nil))
+	defer os.RemoveAll(env.pvDir)
+	if err := os.MkdirAll(filepath.Join(env.pvDir, Directory, "gone", "leftover"), 0777); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if got := env.directories("hpp"); got != 2 {
+		t.Errorf("directories of hpp = %d, want 2", got)
+	}
+	if _, err := os.Stat(filepath.Join(env.pvDir, Directory, "gone")); !os.IsNotExist(err) {
+		t.Errorf("directories of a storage class without warm pool were kept")
+	}
+
+	hpp, _, _ := env.pool.classes.GetByKey("hpp")
+	target := filepath.Join(env.pvDir, "pvc-1")
+	if !env.pool.Take(hpp.(*storage.StorageClass), target) {
+		t.Fatalf("Take() = false, want true")
+	}
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		t.Errorf("Take() did not move a directory to %s: %v", target, err)
+	}
+	if got := env.directories("hpp"); got != 1 {
+		t.Errorf("directories of hpp after Take() = %d, want 1", got)
+	}
+	if env.pool.Take(hpp.(*storage.StorageClass), target) {
+		t.Errorf("Take() of an existing directory = true, want false")
+	}
+	plain, _, _ := env.pool.classes.GetByKey("plain")
+	if env.pool.Take(plain.(*storage.StorageClass), filepath.Join(env.pvDir, "pvc-2")) {
+		t.Errorf("Take() without warm pool = true, want false")
+	}
+
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if got := env.directories("hpp"); got != 2 {
+		t.Errorf("directories of hpp after refill = %d, want 2", got)
+	}
+}
+
+func Test_RefillVolumes(t *testing.T) {
+	env := newTestEnv(t,
+		newClass("hpp", storage.VolumeBindingWaitForFirstConsumer, map[string]string{ParameterVolumes: "10Gi:2,50Gi:1"}))
+	defer os.RemoveAll(env.pvDir)
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if len(env.created) != 3 {
+		t.Fatalf("created volumes = %v, want 3", env.created)
+	}
+
+	// the PV controller binds one of them
+	obj, _, _ := env.indexer.GetByKey(env.created[0])
+	bound := obj.(*v1.PersistentVolume).DeepCopy()
+	bound.Spec.ClaimRef = &v1.ObjectReference{Namespace: "default", Name: "vm-disk"}
+	env.indexer.Update(bound)
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if len(env.created) != 4 {
+		t.Fatalf("created volumes = %v, want 4", env.created)
+	}
+	var names []string
+	for _, pv := range env.pool.Volumes() {
+		if isUnbound(pv) {
+			names = append(names, pv.Name)
+		}
+	}
+	sort.Strings(names)
+	want := append([]string{}, env.created[1:]...)
+	sort.Strings(want)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("unbound warm volumes = %v, want %v", names, want)
+	}
+	if _, ok := env.pool.available[bound.Name]; ok {
+		t.Errorf("bound volume %s is still available", bound.Name)
+	}
+}
+
+func Test_RefillHandOver(t *testing.T) {
+	env := newTestEnv(t,
+		newClass("hpp", storage.VolumeBindingWaitForFirstConsumer, map[string]string{ParameterVolumes: "10Gi:2"}))
+	defer os.RemoveAll(env.pvDir)
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if len(env.handedOver) != 0 {
+		t.Fatalf("handed over volumes = %v, want none", env.handedOver)
+	}
+
+	// the PV controller binds one of them, the other is pre-bound by name only
+	bind := func(name string, uid types.UID) {
+		obj, _, _ := env.indexer.GetByKey(name)
+		bound := obj.(*v1.PersistentVolume).DeepCopy()
+		bound.Spec.ClaimRef = &v1.ObjectReference{Namespace: "default", Name: "claim-" + name, UID: uid}
+		env.indexer.Update(bound)
+	}
+	bind(env.created[0], "uid-1")
+	bind(env.created[1], "")
+	env.failHandOver = true
+	if err := env.pool.Refill(); err == nil {
+		t.Errorf("Refill() error = nil, want the hand over error")
+	}
+	env.failHandOver = false
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if want := []string{env.created[0]}; !reflect.DeepEqual(env.handedOver, want) {
+		t.Errorf("handed over volumes = %v, want %v", env.handedOver, want)
+	}
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if len(env.handedOver) != 1 {
+		t.Errorf("handed over volumes after refill = %v, want %s once", env.handedOver, env.created[0])
+	}
+
+	// a claim bound again after the volume was released and recycled
+	bind(env.created[0], "uid-2")
+	if err := env.pool.Refill(); err != nil {
+		t.Fatalf("Refill() error = %v", err)
+	}
+	if want := []string{env.created[0], env.created[0]}; !reflect.DeepEqual(env.handedOver, want) {
+		t.Errorf("handed over volumes = %v, want %v", env.handedOver, want)
+	}
+}
+
+func Test_RefillVolumesImmediate(t *testing.T) {
+	env := newTestEnv(t,
+		newClass("hpp", storage.VolumeBindingImmediate, map[string]string{ParameterVolumes: "10Gi:2"}))
+	defer os.RemoveAll(env.pvDir)
+	if err := env.pool.Refill(); err == nil {
+		t.Errorf("Refill() error = nil, want an error for an Immediate storage class")
+	}
+	if len(env.created) != 0 {
+		t.Errorf("created volumes = %v, want none", env.created)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	glog "k8s.io/klog"
+
+	diskv1 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v1"
+	diskv2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+// conversionReview is the wire format of the ConversionReviews of
+// apiextensions.k8s.io v1 and v1beta1, which share the same layout.
+type conversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *conversionRequest  `json:"request,omitempty"`
+	Response        *conversionResponse `json:"response,omitempty"`
+}
+
+type conversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+type conversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           metav1.Status          `json:"result"`
+}
+
+// ServeConversion returns a handler answering the ConversionReviews of the
+// DiskMonitor CRD, converting between v1 and the v2 storage version.
+func ServeConversion() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		review := &conversionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, "invalid ConversionReview", http.StatusBadRequest)
+			return
+		}
+		review.Response = convertDiskMonitors(review.Request)
+		review.Request = nil
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			glog.Errorf("failed to encode ConversionReview: %v", err)
+		}
+	})
+}
+
+func convertDiskMonitors(req *conversionRequest) *conversionResponse {
+	response := &conversionResponse{UID: req.UID}
+	for _, object := range req.Objects {
+		converted, err := convertDiskMonitor(object.Raw, req.DesiredAPIVersion)
+		if err != nil {
+			glog.Errorf("failed to convert DiskMonitor to %s: %v", req.DesiredAPIVersion, err)
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return response
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+// convertDiskMonitor converts the serialized DiskMonitor to the desired API
+// version, going through v2 as the hub.
+func convertDiskMonitor(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	hub := &diskv2.DiskMonitor{}
+	switch typeMeta.APIVersion {
+	case diskv2.SchemeGroupVersion.String():
+		if err := json.Unmarshal(raw, hub); err != nil {
+			return nil, err
+		}
+	case diskv1.SchemeGroupVersion.String():
+		spoke := &diskv1.DiskMonitor{}
+		if err := json.Unmarshal(raw, spoke); err != nil {
+			return nil, err
+		}
+		if err := spoke.ConvertTo(hub); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported API version %q", typeMeta.APIVersion)
+	}
+
+	var converted interface{}
+	switch desiredAPIVersion {
+	case diskv2.SchemeGroupVersion.String():
+		hub.TypeMeta = metav1.TypeMeta{APIVersion: desiredAPIVersion, Kind: typeMeta.Kind}
+		converted = hub
+	case diskv1.SchemeGroupVersion.String():
+		spoke := &diskv1.DiskMonitor{}
+		if err := spoke.ConvertFrom(hub); err != nil {
+			return nil, err
+		}
+		spoke.TypeMeta = metav1.TypeMeta{APIVersion: desiredAPIVersion, Kind: typeMeta.Kind}
+		converted = spoke
+	default:
+		return nil, fmt.Errorf("unsupported API version %q", desiredAPIVersion)
+	}
+	return json.Marshal(converted)
+}
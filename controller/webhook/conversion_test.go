@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	diskv1 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v1"
+	diskv2 "kubevirt.io/hostpath-provisioner/controller/monitor-disk/api/v2"
+)
+
+func convert(t *testing.T, fixture string) *conversionReview {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", fixture, err)
+	}
+	recorder := httptest.NewRecorder()
+	ServeConversion().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	review := &conversionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), review); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if review.Response == nil || string(review.Response.UID) != fixture[:len(fixture)-len(".json")] {
+		t.Fatalf("unexpected response %+v", review.Response)
+	}
+	return review
+}
+
+func Test_ConvertToV2(t *testing.T) {
+	review := convert(t, "convert-v1-to-v2.json")
+	if review.Response.Result.Status != metav1.StatusSuccess || len(review.Response.ConvertedObjects) != 1 {
+		t.Fatalf("unexpected response %+v", review.Response)
+	}
+	monitor := &diskv2.DiskMonitor{}
+	if err := json.Unmarshal(review.Response.ConvertedObjects[0].Raw, monitor); err != nil {
+		t.Fatalf("failed to decode DiskMonitor: %v", err)
+	}
+	if monitor.APIVersion != "diskmonitor.domain/v2" || monitor.Kind != "DiskMonitor" || monitor.Name != "node-1" {
+		t.Errorf("unexpected object meta %+v %+v", monitor.TypeMeta, monitor.ObjectMeta)
+	}
+	var got []string
+	for _, volume := range monitor.Status.Volumes {
+		got = append(got, volume.PersistentVolume+"="+volume.Requested.String())
+	}
+	if want := []string{"pvc-a=5Gi", "pvc-b=10Gi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("volumes = %v, want %v", got, want)
+	}
+}
+
+func Test_ConvertToV1(t *testing.T) {
+	review := convert(t, "convert-v2-to-v1.json")
+	if review.Response.Result.Status != metav1.StatusSuccess || len(review.Response.ConvertedObjects) != 1 {
+		t.Fatalf("unexpected response %+v", review.Response)
+	}
+	monitor := &diskv1.DiskMonitor{}
+	if err := json.Unmarshal(review.Response.ConvertedObjects[0].Raw, monitor); err != nil {
+		t.Fatalf("failed to decode DiskMonitor: %v", err)
+	}
+	if monitor.APIVersion != "diskmonitor.domain/v1" || monitor.Status.Total.String() != "100Gi" {
+		t.Errorf("unexpected DiskMonitor %+v", monitor)
+	}
+	want := map[diskv1.PVPath]diskv1.DiskDetail{
+		"/var/hpvolumes/pvc-a": {Detail: diskv1.Detail{"pvName": "pvc-a", "require": "5Gi", "claim": "default/a", "pool": "fast", "used": "1Gi", "phase": "Bound"}},
+		"/var/hpvolumes/pvc-b": {Detail: diskv1.Detail{"pvName": "pvc-b", "require": "10Gi", "phase": "Available"}},
+	}
+	if !reflect.DeepEqual(monitor.Status.DiskInfo, want) {
+		t.Errorf("disk_info = %v, want %v", monitor.Status.DiskInfo, want)
+	}
+}
+
+func Test_ConvertUnknownVersion(t *testing.T) {
+	review := convert(t, "convert-unknown-version.json")
+	if review.Response.Result.Status != metav1.StatusFailure || review.Response.ConvertedObjects != nil {
+		t.Errorf("unexpected response %+v", review.Response)
+	}
+}
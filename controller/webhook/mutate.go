@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+)
+
+const (
+	// AnnDefaultPool on a namespace is the pool of its claims that name none.
+	AnnDefaultPool = "hostpath.kubevirt.io/default-pool"
+	// AnnDefaultPermissions on a namespace is the mode of the volume
+	// directories of its claims that name none.
+	AnnDefaultPermissions = "hostpath.kubevirt.io/default-permissions"
+
+	// ParameterPool is the StorageClass parameter with the pool of its
+	// claims, used when neither the claim nor its namespace name one.
+	ParameterPool = "pool"
+	// ParameterPermissions is the StorageClass parameter with the mode of the
+	// volume directories of its claims, used when neither the claim nor its
+	// namespace name one.
+	ParameterPermissions = "permissions"
+)
+
+// Mutator fills in the annotations of new claims of the provisioner: the
+// pool and the directory permissions, from the namespace defaults or the
+// StorageClass parameters, and for Immediate binding claims of a group bound
+// to a node that node. Other claims are placed by the manager, which accounts
+// for the claims it placed that are not provisioned yet.
+type Mutator struct {
+	provisionerName string
+	classes         storagelisters.StorageClassLister
+	namespaces      corelisters.NamespaceLister
+	groups          *claimgroups.Groups
+}
+
+// NewMutator returns a Mutator for the claims of provisionerName.
+func NewMutator(provisionerName string, classes storagelisters.StorageClassLister, namespaces corelisters.NamespaceLister, groups *claimgroups.Groups) *Mutator {
+	return &Mutator{
+		provisionerName: provisionerName,
+		classes:         classes,
+		namespaces:      namespaces,
+		groups:          groups,
+	}
+}
+
+// Handler returns the http handler of the webhook.
+func (m *Mutator) Handler() http.Handler {
+	return Serve(m.Admit)
+}
+
+// Admit answers the admission request of a claim with a patch of its
+// annotations. Claims are never refused, when the annotations can not be
+// filled in the claim is left alone.
+func (m *Mutator) Admit(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create {
+		return allowed()
+	}
+	claim, err := decodeClaim(req)
+	if err != nil {
+		return denied(http.StatusBadRequest, err.Error())
+	}
+	if claim == nil {
+		return allowed()
+	}
+	class, err := hostpathClass(m.classes, m.provisionerName, claim)
+	if err != nil {
+		glog.Errorf("failed to get the storage class of claim %s/%s: %v", claim.Namespace, claim.Name, err)
+		return allowed()
+	}
+	if class == nil {
+		return allowed()
+	}
+	annotations := m.annotations(claim, class)
+	if equalAnnotations(annotations, claim.Annotations) {
+		return allowed()
+	}
+	patch, err := json.Marshal([]map[string]interface{}{{
+		"op":    "add",
+		"path":  "/metadata/annotations",
+		"value": annotations,
+	}})
+	if err != nil {
+		return denied(http.StatusInternalServerError, err.Error())
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	return &admissionv1.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
+	}
+}
+
+// annotations returns the annotations the claim should have.
+func (m *Mutator) annotations(claim *v1.PersistentVolumeClaim, class *storage.StorageClass) map[string]string {
+	annotations := map[string]string{}
+	for key, value := range claim.Annotations {
+		annotations[key] = value
+	}
+	var namespaceAnnotations map[string]string
+	if namespace, err := m.namespaces.Get(claim.Namespace); err == nil {
+		namespaceAnnotations = namespace.Annotations
+	}
+
+	pool := firstOf(annotations[nodevolumes.AnnPool], namespaceAnnotations[AnnDefaultPool], class.Parameters[ParameterPool], noderesources.DefaultPool)
+	annotations[nodevolumes.AnnPool] = pool
+	if permissions := firstOf(annotations[nodevolumes.AnnPermissions], namespaceAnnotations[AnnDefaultPermissions], class.Parameters[ParameterPermissions]); permissions != "" {
+		annotations[nodevolumes.AnnPermissions] = permissions
+	}
+
+	// Nodes of other pools carry the label of their pool. Claims of the default
+	// pool may be placed on any node, the node agents of other pools take them
+	// as well.
+	selector := labels.Everything()
+	if s, ok := annotations[placement.AnnNodeSelector]; ok {
+		var err error
+		if selector, err = labels.Parse(s); err != nil {
+			glog.Warningf("claim %s/%s has an invalid %s annotation: %v", claim.Namespace, claim.Name, placement.AnnNodeSelector, err)
+			return annotations
+		}
+	}
+	if pool != noderesources.DefaultPool {
+		requirement, err := labels.NewRequirement(noderesources.PoolLabel(pool), selection.Equals, []string{"true"})
+		if err != nil {
+			glog.Warningf("claim %s/%s names an invalid pool %q: %v", claim.Namespace, claim.Name, pool, err)
+			return annotations
+		}
+		if !selectorHas(selector, *requirement) {
+			selector = selector.Add(*requirement)
+			annotations[placement.AnnNodeSelector] = selector.String()
+		}
+	}
+
+	if claim.Spec.VolumeName != "" || annotations[nodevolumes.AnnProvisionOnNode] != "" {
+		return annotations
+	}
+	if class.VolumeBindingMode != nil && *class.VolumeBindingMode != storage.VolumeBindingImmediate {
+		return annotations
+	}
+	constraint := m.groups.Constraint(claim)
+	if constraint.Conflict != "" {
+		glog.Warningf("claim %s/%s is not assigned a node: %s", claim.Namespace, claim.Name, constraint.Conflict)
+		return annotations
+	}
+	if constraint.Node != "" {
+		annotations[nodevolumes.AnnProvisionOnNode] = constraint.Node
+	}
+	return annotations
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func selectorHas(selector labels.Selector, requirement labels.Requirement) bool {
+	requirements, _ := selector.Requirements()
+	for _, r := range requirements {
+		if r.String() == requirement.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func equalAnnotations(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
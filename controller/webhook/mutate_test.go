@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+)
+
+const (
+	testProvisioner = "kubevirt.io/hostpath-provisioner"
+	GiB             = 1024 * 1024 * 1024
+)
+
+type testEnv struct {
+	factory    informers.SharedInformerFactory
+	groups     *claimgroups.Groups
+	capacities map[string]int64
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	immediate := storage.VolumeBindingImmediate
+	waitForFirstConsumer := storage.VolumeBindingWaitForFirstConsumer
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	classes := factory.Storage().V1().StorageClasses().Informer().GetIndexer()
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "hostpath-immediate"}, Provisioner: testProvisioner, VolumeBindingMode: &immediate})
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "hostpath-wffc"}, Provisioner: testProvisioner, VolumeBindingMode: &waitForFirstConsumer,
+		Parameters: map[string]string{ParameterPermissions: "0750"}})
+	classes.Add(&storage.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, Provisioner: "example.com/other"})
+	namespaces := factory.Core().V1().Namespaces().Informer().GetIndexer()
+	namespaces.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: map[string]string{
+		AnnDefaultPool:        "fast",
+		AnnDefaultPermissions: "0770",
+	}}})
+	namespaces.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	nodes := factory.Core().V1().Nodes().Informer().GetIndexer()
+	fast := map[string]string{noderesources.PoolLabel("fast"): "true"}
+	nodes.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: fast}})
+	nodes.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}})
+	nodes.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3", Labels: fast}})
+
+	groups, err := claimgroups.New(factory.Core().V1().PersistentVolumeClaims().Informer(), factory.Core().V1().PersistentVolumes().Informer())
+	if err != nil {
+		t.Fatalf("claimgroups.New() error = %v", err)
+	}
+	env := &testEnv{
+		factory:    factory,
+		groups:     groups,
+		capacities: map[string]int64{"node-1": 50 * GiB, "node-2": 100 * GiB, "node-3": 20 * GiB},
+	}
+	return env
+}
+
+// nodeCapacity reports the capacities of the test environment.
+func (env *testEnv) nodeCapacity(nodeName string) (capacity.Capacity, bool) {
+	allocatable, ok := env.capacities[nodeName]
+	return capacity.Capacity{Allocatable: allocatable}, ok
+}
+
+// review posts the AdmissionReview fixture to handler and returns the response.
+func review(t *testing.T, handler http.Handler, fixture string) *admissionv1.AdmissionResponse {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", fixture, err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	result := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+		t.Fatalf("invalid AdmissionReview: %v", err)
+	}
+	if result.Response == nil {
+		t.Fatalf("AdmissionReview has no response")
+	}
+	return result.Response
+}
+
+func Test_Mutate(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    map[string]string
+	}{
+		{
+			fixture: "mutate-immediate.json",
+			want: map[string]string{
+				nodevolumes.AnnPool:        "fast",
+				nodevolumes.AnnPermissions: "0770",
+				placement.AnnNodeSelector:  "pool.hostpath.kubevirt.io/fast=true",
+			},
+		},
+		{
+			// annotations the claim names itself are kept
+			fixture: "mutate-annotated.json",
+		},
+		{
+			fixture: "mutate-wffc.json",
+			want: map[string]string{
+				nodevolumes.AnnPool:        "default",
+				nodevolumes.AnnPermissions: "0750",
+			},
+		},
+		{
+			fixture: "mutate-too-large.json",
+			want: map[string]string{
+				nodevolumes.AnnPool: "default",
+			},
+		},
+		{
+			fixture: "mutate-other-class.json",
+		},
+	}
+	env := newTestEnv(t)
+	mutator := NewMutator(testProvisioner, env.factory.Storage().V1().StorageClasses().Lister(), env.factory.Core().V1().Namespaces().Lister(), env.groups)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			response := review(t, mutator.Handler(), tt.fixture)
+			if !response.Allowed {
+				t.Fatalf("claim should be allowed, got %v", response.Result)
+			}
+			if response.UID == "" {
+				t.Errorf("response has no uid")
+			}
+			if tt.want == nil {
+				if response.Patch != nil {
+					t.Errorf("unexpected patch %s", response.Patch)
+				}
+				return
+			}
+			var patch []struct {
+				Op    string            `json:"op"`
+				Path  string            `json:"path"`
+				Value map[string]string `json:"value"`
+			}
+			if err := json.Unmarshal(response.Patch, &patch); err != nil {
+				t.Fatalf("invalid patch %s: %v", response.Patch, err)
+			}
+			if len(patch) != 1 || patch[0].Op != "add" || patch[0].Path != "/metadata/annotations" {
+				t.Fatalf("unexpected patch %s", response.Patch)
+			}
+			if !reflect.DeepEqual(patch[0].Value, tt.want) {
+				t.Errorf("annotations = %v, want %v", patch[0].Value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storage "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	glog "k8s.io/klog"
+	"sigs.k8s.io/sig-storage-lib-external-provisioner/v6/util"
+)
+
+// AdmitFunc decides on an admission request.
+type AdmitFunc func(*admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// Serve returns a handler answering AdmissionReviews with admit. Reviews of
+// admission.k8s.io v1 and v1beta1 are accepted, both have the same layout and
+// the response carries the version of the request.
+func Serve(admit AdmitFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+			return
+		}
+		response := admit(review.Request)
+		response.UID = review.Request.UID
+		review.Response = response
+		review.Request = nil
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			glog.Errorf("failed to encode AdmissionReview: %v", err)
+		}
+	})
+}
+
+// decodeClaim returns the claim of the request, or nil when the request is
+// not about claims.
+func decodeClaim(req *admissionv1.AdmissionRequest) (*v1.PersistentVolumeClaim, error) {
+	if req.Resource.Group != "" || req.Resource.Resource != "persistentvolumeclaims" {
+		return nil, nil
+	}
+	claim := &v1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(req.Object.Raw, claim); err != nil {
+		return nil, fmt.Errorf("invalid PersistentVolumeClaim: %v", err)
+	}
+	if claim.Namespace == "" {
+		claim.Namespace = req.Namespace
+	}
+	return claim, nil
+}
+
+// hostpathClass returns the storage class of the claim, or nil when it is not
+// a class of the provisioner.
+func hostpathClass(classes storagelisters.StorageClassLister, provisionerName string, claim *v1.PersistentVolumeClaim) (*storage.StorageClass, error) {
+	className := util.GetPersistentVolumeClaimClass(claim)
+	if className == "" {
+		return nil, nil
+	}
+	class, err := classes.Get(className)
+	if apierrs.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if class.Provisioner != provisionerName {
+		return nil, nil
+	}
+	return class, nil
+}
+
+func allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func denied(code int32, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	glog "k8s.io/klog"
+
+	"kubevirt.io/hostpath-provisioner/controller/capacity"
+	"kubevirt.io/hostpath-provisioner/controller/claimgroups"
+	"kubevirt.io/hostpath-provisioner/controller/noderesources"
+	"kubevirt.io/hostpath-provisioner/controller/nodevolumes"
+	"kubevirt.io/hostpath-provisioner/controller/placement"
+	"kubevirt.io/hostpath-provisioner/controller/preemption"
+)
+
+// Validator refuses claims of the provisioner that can never be provisioned:
+// claims with invalid hostpath annotations, claims naming a node that does
+// not exist and claims larger than every pool they may be placed in.
+type Validator struct {
+	provisionerName string
+	classes         storagelisters.StorageClassLister
+	nodes           corelisters.NodeLister
+	groups          *claimgroups.Groups
+	capacities      capacity.NodeCapacityFunc
+}
+
+// NewValidator returns a Validator for the claims of provisionerName.
+func NewValidator(provisionerName string, classes storagelisters.StorageClassLister, nodes corelisters.NodeLister, groups *claimgroups.Groups, capacities capacity.NodeCapacityFunc) *Validator {
+	return &Validator{
+		provisionerName: provisionerName,
+		classes:         classes,
+		nodes:           nodes,
+		groups:          groups,
+		capacities:      capacities,
+	}
+}
+
+// Handler returns the http handler of the webhook.
+func (v *Validator) Handler() http.Handler {
+	return Serve(v.Admit)
+}
+
+// Admit answers the admission request of a claim. New claims are checked as
+// a whole, updates of unbound claims only for their annotations, since the
+// size of a claim can not change before it is bound.
+func (v *Validator) Admit(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return allowed()
+	}
+	claim, err := decodeClaim(req)
+	if err != nil {
+		return denied(http.StatusBadRequest, err.Error())
+	}
+	if claim == nil || (req.Operation == admissionv1.Update && claim.Spec.VolumeName != "") {
+		return allowed()
+	}
+	class, err := hostpathClass(v.classes, v.provisionerName, claim)
+	if err != nil {
+		glog.Errorf("failed to get the storage class of claim %s/%s: %v", claim.Namespace, claim.Name, err)
+		return allowed()
+	}
+	if class == nil {
+		return allowed()
+	}
+
+	problems, selector := v.validateAnnotations(claim)
+	if len(problems) == 0 && req.Operation == admissionv1.Create {
+		if problem := v.validateSize(claim, selector); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return denied(http.StatusUnprocessableEntity, fmt.Sprintf("hostpath claim %s/%s is invalid: %s", claim.Namespace, claim.Name, strings.Join(problems, "; ")))
+	}
+	return allowed()
+}
+
+// validateAnnotations returns the problems with the hostpath annotations of
+// the claim and the selector of the nodes the claim may be placed on.
+func (v *Validator) validateAnnotations(claim *v1.PersistentVolumeClaim) ([]string, labels.Selector) {
+	var problems []string
+	annotations := claim.Annotations
+	selector := labels.Everything()
+
+	pool := noderesources.DefaultPool
+	if value, ok := annotations[nodevolumes.AnnPool]; ok {
+		if err := noderesources.ValidatePool(value); err != nil {
+			problems = append(problems, fmt.Sprintf("annotation %s: %v", nodevolumes.AnnPool, err))
+		} else {
+			pool = value
+		}
+	}
+	if value, ok := annotations[nodevolumes.AnnPermissions]; ok {
+		if _, err := nodevolumes.ParsePermissions(value); err != nil {
+			problems = append(problems, fmt.Sprintf("annotation %s: %v", nodevolumes.AnnPermissions, err))
+		}
+	}
+	if value, ok := annotations[placement.AnnNodeSelector]; ok {
+		s, err := labels.Parse(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("annotation %s: invalid label selector %q: %v", placement.AnnNodeSelector, value, err))
+		} else {
+			selector = s
+		}
+	}
+	if _, err := preemption.Priority(claim); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if value, ok := annotations[preemption.AnnEvictable]; ok && value != "true" && value != "false" {
+		problems = append(problems, fmt.Sprintf("annotation %s: must be \"true\" or \"false\", got %q", preemption.AnnEvictable, value))
+	}
+	for _, key := range []string{claimgroups.AnnGroup, claimgroups.AnnAntiAffinity} {
+		if value, ok := annotations[key]; ok && value == "" {
+			problems = append(problems, fmt.Sprintf("annotation %s: must not be empty", key))
+		}
+	}
+
+	if nodeName, ok := annotations[nodevolumes.AnnProvisionOnNode]; ok {
+		node, err := v.nodes.Get(nodeName)
+		switch {
+		case apierrs.IsNotFound(err):
+			problems = append(problems, fmt.Sprintf("annotation %s: node %q does not exist", nodevolumes.AnnProvisionOnNode, nodeName))
+		case err != nil:
+			glog.Errorf("failed to get node %s: %v", nodeName, err)
+		case pool != noderesources.DefaultPool && node.Labels[noderesources.PoolLabel(pool)] != "true":
+			problems = append(problems, fmt.Sprintf("annotation %s: node %q has no pool %q, it is not labeled %s=true",
+				nodevolumes.AnnProvisionOnNode, nodeName, pool, noderesources.PoolLabel(pool)))
+		case !selector.Matches(labels.Set(node.Labels)):
+			problems = append(problems, fmt.Sprintf("annotation %s: node %q does not match annotation %s %q",
+				nodevolumes.AnnProvisionOnNode, nodeName, placement.AnnNodeSelector, selector.String()))
+		}
+	}
+	return problems, selector
+}
+
+// validateSize returns a problem when no pool the claim may be placed in can
+// ever hold it. A pool can at most hold what is requested from it plus what
+// is still allocatable. Nodes that do not report their capacity are given
+// the benefit of the doubt.
+func (v *Validator) validateSize(claim *v1.PersistentVolumeClaim, selector labels.Selector) string {
+	size := claim.Spec.Resources.Requests.Storage()
+	var candidates []string
+	if nodeName := claim.Annotations[nodevolumes.AnnProvisionOnNode]; nodeName != "" {
+		candidates = []string{nodeName}
+	} else if nodeName := v.groups.Constraint(claim).Node; nodeName != "" {
+		candidates = []string{nodeName}
+	} else {
+		pool := claim.Annotations[nodevolumes.AnnPool]
+		nodes, err := v.nodes.List(selector)
+		if err != nil {
+			glog.Errorf("failed to list nodes: %v", err)
+			return ""
+		}
+		for _, node := range nodes {
+			if pool == "" || pool == noderesources.DefaultPool || node.Labels[noderesources.PoolLabel(pool)] == "true" {
+				candidates = append(candidates, node.Name)
+			}
+		}
+		if len(candidates) == 0 {
+			if pool != "" && pool != noderesources.DefaultPool {
+				return fmt.Sprintf("no node matching annotation %s %q has pool %q", placement.AnnNodeSelector, selector.String(), pool)
+			}
+			return fmt.Sprintf("no node matches annotation %s %q", placement.AnnNodeSelector, selector.String())
+		}
+	}
+
+	var largest int64
+	largestNode := ""
+	for _, nodeName := range candidates {
+		nodeCapacity, ok := v.capacities(nodeName)
+		if !ok {
+			return ""
+		}
+		if poolSize := nodeCapacity.Requested + nodeCapacity.Allocatable; largestNode == "" || poolSize > largest {
+			largest, largestNode = poolSize, nodeName
+		}
+	}
+	if size.Value() <= largest {
+		return ""
+	}
+	if len(candidates) == 1 {
+		return fmt.Sprintf("requested size %s is larger than the hostpath pool of node %q, which can hold at most %s",
+			size.String(), largestNode, resource.NewQuantity(largest, resource.BinarySI).String())
+	}
+	return fmt.Sprintf("requested size %s is larger than every eligible hostpath pool, the largest is on node %q and can hold at most %s",
+		size.String(), largestNode, resource.NewQuantity(largest, resource.BinarySI).String())
+}
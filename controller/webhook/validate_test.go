@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+)
+
+// matches returns whether message matches pattern, in which * stands for any
+// text without a semicolon.
+func matches(message, pattern string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(message, parts[0]) {
+		return false
+	}
+	message = message[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(message, part)
+		if i < 0 || strings.Contains(message[:i], ";") {
+			return false
+		}
+		message = message[i+len(part):]
+	}
+	return message == ""
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantAllowed bool
+		// wantMessage is the message, * matches the error text of apimachinery
+		wantMessage string
+	}{
+		{
+			fixture:     "validate-fits.json",
+			wantAllowed: true,
+		},
+		{
+			fixture:     "validate-other-class.json",
+			wantAllowed: true,
+		},
+		{
+			fixture:     "validate-too-large.json",
+			wantMessage: `hostpath claim default/validate-too-large is invalid: requested size 1Ti is larger than every eligible hostpath pool, the largest is on node "node-2" and can hold at most 100Gi`,
+		},
+		{
+			fixture:     "validate-missing-node.json",
+			wantMessage: `hostpath claim default/validate-missing-node is invalid: annotation kubevirt.io/provisionOnNode: node "node-9" does not exist`,
+		},
+		{
+			fixture:     "validate-node-too-small.json",
+			wantMessage: `hostpath claim default/validate-node-too-small is invalid: requested size 30Gi is larger than the hostpath pool of node "node-3", which can hold at most 20Gi`,
+		},
+		{
+			fixture: "validate-invalid-annotations.json",
+			wantMessage: `hostpath claim default/validate-invalid-annotations is invalid: ` +
+				`annotation hostpath.kubevirt.io/pool: invalid pool name "Fast Pool": *; ` +
+				`annotation hostpath.kubevirt.io/permissions: invalid permissions "999", must be an octal mode such as 0770; ` +
+				`annotation hostpath.kubevirt.io/node-selector: invalid label selector "disk in (ssd": *; ` +
+				`invalid hostpath.kubevirt.io/priority annotation "high", must be an integer; ` +
+				`annotation hostpath.kubevirt.io/evictable: must be "true" or "false", got "yes"; ` +
+				`annotation hostpath.kubevirt.io/group: must not be empty`,
+		},
+		{
+			fixture:     "validate-pool-mismatch.json",
+			wantMessage: `hostpath claim team-a/validate-pool-mismatch is invalid: annotation kubevirt.io/provisionOnNode: node "node-2" has no pool "fast", it is not labeled pool.hostpath.kubevirt.io/fast=true`,
+		},
+		{
+			fixture:     "validate-pool-too-large.json",
+			wantMessage: `hostpath claim team-a/validate-pool-too-large is invalid: requested size 60Gi is larger than every eligible hostpath pool, the largest is on node "node-1" and can hold at most 50Gi`,
+		},
+		{
+			fixture:     "validate-update-invalid.json",
+			wantMessage: `hostpath claim default/validate-update-invalid is invalid: annotation hostpath.kubevirt.io/permissions: invalid permissions "0799", must be an octal mode such as 0770`,
+		},
+	}
+	env := newTestEnv(t)
+	validator := NewValidator(testProvisioner, env.factory.Storage().V1().StorageClasses().Lister(), env.factory.Core().V1().Nodes().Lister(), env.groups, env.nodeCapacity)
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			response := review(t, validator.Handler(), tt.fixture)
+			if response.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v, result %v", response.Allowed, tt.wantAllowed, response.Result)
+			}
+			if tt.wantAllowed {
+				return
+			}
+			if response.Result == nil || !matches(response.Result.Message, tt.wantMessage) {
+				t.Errorf("message = %q\nwant %q", response.Result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
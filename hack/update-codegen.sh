@@ -0,0 +1,60 @@
+#!/bin/bash
+#
+# Copyright 2021 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Generates the typed clientset, listers and informers of the DiskMonitor API
+# into controller/monitor-disk/client. The client-gen, lister-gen and
+# informer-gen binaries of k8s.io/code-generator v0.18.6 must be in PATH.
+
+set -e
+
+readonly MODULE=kubevirt.io/hostpath-provisioner
+readonly SCRIPT_ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+readonly API_BASE=${MODULE}/controller/monitor-disk
+readonly CLIENT=${API_BASE}/client
+readonly HEADER=${SCRIPT_ROOT}/hack/boilerplate.go.txt
+
+# The generators take the group from the directory above the version, so the
+# API is staged as api/diskmonitor/<version> and the import paths rewritten
+# afterwards.
+readonly STAGE=${SCRIPT_ROOT}/controller/monitor-disk/api/diskmonitor
+readonly VERSIONS="v1 v2"
+OUTPUT_BASE=$(mktemp -d)
+trap 'rm -rf "${OUTPUT_BASE}" "${STAGE}"' EXIT
+mkdir -p "${STAGE}"
+INPUTS=""
+INPUT_DIRS=""
+for version in ${VERSIONS}; do
+  cp -r "${SCRIPT_ROOT}/controller/monitor-disk/api/${version}" "${STAGE}/${version}"
+  INPUTS="${INPUTS:+${INPUTS},}diskmonitor/${version}"
+  INPUT_DIRS="${INPUT_DIRS:+${INPUT_DIRS},}${API_BASE}/api/diskmonitor/${version}"
+done
+
+client-gen --go-header-file "${HEADER}" --output-base "${OUTPUT_BASE}" \
+  --clientset-name versioned --input-base "${API_BASE}/api" --input "${INPUTS}" \
+  --output-package "${CLIENT}/clientset"
+lister-gen --go-header-file "${HEADER}" --output-base "${OUTPUT_BASE}" \
+  --input-dirs "${INPUT_DIRS}" --output-package "${CLIENT}/listers"
+informer-gen --go-header-file "${HEADER}" --output-base "${OUTPUT_BASE}" \
+  --input-dirs "${INPUT_DIRS}" \
+  --versioned-clientset-package "${CLIENT}/clientset/versioned" \
+  --listers-package "${CLIENT}/listers" \
+  --output-package "${CLIENT}/informers"
+
+rm -rf "${SCRIPT_ROOT}/controller/monitor-disk/client"
+cp -r "${OUTPUT_BASE}/${CLIENT}" "${SCRIPT_ROOT}/controller/monitor-disk/client"
+grep -rl "${API_BASE}/api/diskmonitor/" "${SCRIPT_ROOT}/controller/monitor-disk/client" |
+  xargs sed -i "s|${API_BASE}/api/diskmonitor/|${API_BASE}/api/|g"
+gofmt -w "${SCRIPT_ROOT}/controller/monitor-disk/client"
@@ -0,0 +1,55 @@
+#!/bin/bash
+#
+# Copyright 2021 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#    http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Generates the CRDs of the DiskMonitor API in deploy/ from its Go types. The
+# controller-gen binary of sigs.k8s.io/controller-tools must be in PATH.
+# controller-gen does not know about the conversion webhook of DiskMonitors,
+# its settings are taken from hack/diskmonitor-conversion.yaml. With --verify
+# the CRDs in deploy/ are only compared with the generated ones.
+
+set -e
+
+readonly SCRIPT_ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+readonly CONVERTED_CRD=diskmonitor.domain_diskmonitors.yaml
+OUTPUT=$(mktemp -d)
+trap 'rm -rf "${OUTPUT}"' EXIT
+
+cd "${SCRIPT_ROOT}"
+controller-gen crd:crdVersions=v1 paths=./controller/monitor-disk/api/... output:crd:dir="${OUTPUT}/generated"
+mkdir "${OUTPUT}/deploy"
+for crd in "${OUTPUT}"/generated/*.yaml; do
+  name=$(basename "${crd}")
+  if [ "${name}" != "${CONVERTED_CRD}" ]; then
+    cp "${crd}" "${OUTPUT}/deploy/${name}"
+    continue
+  fi
+  # the conversion goes first in the spec
+  awk -v conversion=hack/diskmonitor-conversion.yaml '
+    { print }
+    /^spec:$/ && !done { while ((getline line < conversion) > 0) print line; done = 1 }
+  ' "${crd}" > "${OUTPUT}/deploy/${name}"
+done
+
+if [ "$1" == "--verify" ]; then
+  for crd in "${OUTPUT}"/deploy/*.yaml; do
+    if ! diff -u "deploy/$(basename "${crd}")" "${crd}"; then
+      echo "deploy/$(basename "${crd}") is out of date, run make generate" >&2
+      exit 1
+    fi
+  done
+  exit 0
+fi
+cp "${OUTPUT}"/deploy/*.yaml deploy/